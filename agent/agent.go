@@ -8,8 +8,11 @@ import (
 	"net/netip"
 
 	"github.com/SyntropyNet/syntropy-agent/agent/autoping"
+	"github.com/SyntropyNet/syntropy-agent/agent/bwprobe"
+	"github.com/SyntropyNet/syntropy-agent/agent/capabilities"
 	"github.com/SyntropyNet/syntropy-agent/agent/common"
 	"github.com/SyntropyNet/syntropy-agent/agent/configinfo"
+	"github.com/SyntropyNet/syntropy-agent/agent/ctlserver"
 	"github.com/SyntropyNet/syntropy-agent/agent/docker"
 	"github.com/SyntropyNet/syntropy-agent/agent/exporter"
 	"github.com/SyntropyNet/syntropy-agent/agent/getinfo"
@@ -18,15 +21,22 @@ import (
 	"github.com/SyntropyNet/syntropy-agent/agent/kubernetes"
 	"github.com/SyntropyNet/syntropy-agent/agent/mole"
 	"github.com/SyntropyNet/syntropy-agent/agent/peerwatch"
+	"github.com/SyntropyNet/syntropy-agent/agent/scheduler"
 	"github.com/SyntropyNet/syntropy-agent/agent/settings"
+	"github.com/SyntropyNet/syntropy-agent/agent/snapshot"
 	"github.com/SyntropyNet/syntropy-agent/agent/supportinfo"
 	"github.com/SyntropyNet/syntropy-agent/agent/supportinfo/shellcmd"
 	"github.com/SyntropyNet/syntropy-agent/agent/wgconf"
 	"github.com/SyntropyNet/syntropy-agent/controller"
 	"github.com/SyntropyNet/syntropy-agent/controller/blockchain"
+	"github.com/SyntropyNet/syntropy-agent/controller/local"
+	"github.com/SyntropyNet/syntropy-agent/controller/recorder"
+	"github.com/SyntropyNet/syntropy-agent/controller/replay"
 	"github.com/SyntropyNet/syntropy-agent/controller/saas"
 	"github.com/SyntropyNet/syntropy-agent/controller/script"
 	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 	"github.com/SyntropyNet/syntropy-agent/pkg/multiping"
 	"github.com/SyntropyNet/syntropy-agent/pkg/netcfg"
@@ -49,6 +59,18 @@ type Agent struct {
 	pinger *multiping.MultiPing
 	mole   *mole.Mole
 
+	// local control socket server
+	ctlServer *ctlserver.ControlServer
+
+	// agent capabilities and negotiated protocol version
+	capabilities *capabilities.Capabilities
+
+	// received messages processing lanes
+	scheduler *scheduler.Scheduler
+
+	// metrics exporter. Is always created, so it can be enabled by configuration reload
+	exporter *exporter.PeersMetrics
+
 	// services and commands slice/map
 	commands map[string]common.Command
 	services []common.Service
@@ -69,6 +91,12 @@ func New(contype int) (*Agent, error) {
 		controller, err = script.New()
 	case config.ControllerBlockchain:
 		controller, err = blockchain.New()
+	case config.ControllerReplay:
+		controller, err = replay.New(config.JournalFile(), config.ReplaySpeed())
+	case config.ControllerLocal:
+		certFile, keyFile := config.GetLocalControllerTLS()
+		controller, err = local.New(config.GetLocalControllerAddress(), config.GetLocalControllerToken(),
+			certFile, keyFile)
 	default:
 		err = fmt.Errorf("unexpected controller type %d", contype)
 	}
@@ -83,6 +111,16 @@ func New(contype int) (*Agent, error) {
 		commands:   make(map[string]common.Command),
 		services:   make([]common.Service, 0),
 	}
+
+	// Replay controller reads the journal. Do not overwrite it.
+	if config.RecordJournal() && contype != config.ControllerReplay {
+		rec, err := recorder.New(controller, config.JournalFile())
+		if err != nil {
+			logger.Error().Println(pkgName, "Controller messages recorder", err)
+		} else {
+			agent.controller = rec
+		}
+	}
 	agent.ctx, agent.cancel = context.WithCancel(context.Background())
 
 	agent.mole, err = mole.New(agent.controller)
@@ -126,8 +164,13 @@ func New(contype int) (*Agent, error) {
 		dockerHelper = &docker.DockerNull{}
 	}
 
-	agent.addCommand(configinfo.New(agent.controller, agent.mole, dockerHelper))
-	agent.addCommand(wgconf.New(agent.controller, agent.mole))
+	var snapshotStore *snapshot.Store
+	if config.StateSnapshotEnabled() {
+		snapshotStore = snapshot.New()
+	}
+
+	agent.addCommand(configinfo.New(agent.controller, agent.mole, dockerHelper, snapshotStore))
+	agent.addCommand(wgconf.New(agent.controller, agent.mole, snapshotStore))
 
 	autoping := autoping.New(agent.controller, agent.pinger)
 	agent.addCommand(autoping)
@@ -137,23 +180,53 @@ func New(contype int) (*Agent, error) {
 	switch c := controller.(type) {
 	case *saas.CloudController:
 		agent.addService(ifacemon.New(c.Reconnect))
+		// Rollback configuration, if it breaks connection to controller
+		agent.mole.SetConnectivityCheck(c.CheckConnectivity)
+		// Controller may be upgraded or failed over during reconnect. Negotiate again every time.
+		c.OnConnect(func() {
+			agent.capabilities.Reset()
+			agent.capabilities.Send()
+		})
 	}
 
-	if config.MetricsExporterEnabled() {
-		metrics, err := exporter.New(config.MetricsExporterPort(), agent.mole.Router())
-		if err != nil {
-			logger.Error().Println(pkgName, "metrics exporter create", err)
-		} else {
-			agent.addService(metrics)
-		}
+	agent.exporter, err = exporter.New(config.MetricsExporterPort(), agent.mole.Router())
+	if err != nil {
+		logger.Error().Println(pkgName, "metrics exporter create", err)
+	} else {
+		agent.addService(agent.exporter)
+	}
+
+	var bandwidthProbe *bwprobe.BandwidthProbe
+	if config.BandwidthProbeEnabled() {
+		bandwidthProbe = bwprobe.New(agent.controller, agent.mole, config.BandwidthProbePort())
+		agent.addCommand(bandwidthProbe)
+		agent.addService(bandwidthProbe)
 	}
 
 	agent.addCommand(getinfo.New(agent.controller, dockerHelper))
-	agent.addCommand(settings.New())
+	agent.addCommand(settings.New(agent.controller, agent.mole.Router(), agent.applySettings))
 	agent.addCommand(supportinfo.New(agent.controller,
 		shellcmd.New("wg_info", "wg", "show"),
 		shellcmd.New("routes", "route", "-n"),
 		autoping))
+	agent.capabilities = capabilities.New(agent.controller, agent.commandsInfo)
+	agent.addCommand(agent.capabilities)
+
+	agent.scheduler = scheduler.New(agent.controller, agent.processCommand,
+		agent.commandLane, agent.superseded)
+	agent.addService(agent.scheduler)
+
+	// Local control socket for `syntropyctl`
+	agent.ctlServer = ctlserver.New(env.ControlSocket, agent.mole, agent.serviceNames)
+	agent.ctlServer.Handle(ctlapi.PathPlan, agent.planCommand)
+	if bandwidthProbe != nil {
+		agent.ctlServer.Handle(ctlapi.PathBandwidth, bandwidthProbe.CtlHandler)
+	}
+	agent.addService(agent.ctlServer)
+
+	// Restore previous configuration before connecting to controller.
+	// Controller may be unreachable for a long time.
+	agent.restoreSnapshot(snapshotStore, dockerHelper)
 
 	return agent, agent.controller.Open()
 }
@@ -184,24 +257,18 @@ func (agent *Agent) detectNAT() {
 func (agent *Agent) Run() {
 	logger.Info().Println(pkgName, "Starting Agent messages handler")
 	agent.detectNAT()
+	// Cloud controller gets capabilities upon every connect
+	if config.GetControllerType() != config.ControllerSaas {
+		agent.capabilities.Send()
+	}
 	// Start all "services"
 	agent.startServices()
 
 	// Decouple packet receive from processing it
 	// Because a big network configuration apply takes quite a long time
 	// And this can result in websocket timeout
-	// Use buffered channel and 2 goroutines for this purpose
-	rxchan := make(chan []byte, 9)
-
-	go func() {
-		for raw := range rxchan {
-			agent.processCommand(raw)
-		}
-	}()
-
+	// Scheduler processes received messages in its own lanes
 	go func() {
-		defer close(rxchan)
-
 		for {
 			raw, err := agent.controller.Recv()
 
@@ -217,7 +284,7 @@ func (agent *Agent) Run() {
 				return
 			}
 
-			rxchan <- raw
+			agent.scheduler.Push(raw)
 		}
 	}()
 }
@@ -50,6 +50,11 @@ func (obj *AutoPing) Name() string {
 	return cmd
 }
 
+// AUTO_PING changes only pinged addresses, not network configuration
+func (obj *AutoPing) ReadOnly() bool {
+	return true
+}
+
 func (obj *AutoPing) Exec(raw []byte) error {
 	var req autoPingRequest
 	err := json.Unmarshal(raw, &req)
@@ -0,0 +1,294 @@
+// bwprobe package implements active bandwidth probing between peers.
+// It is both: controller.Command (BANDWIDTH_PROBE) and controller.Service (probe server).
+// Probe server listens on wireguard tunnel addresses only, so it is not reachable from outside.
+// Probe results are reported to controller and stored in router (shown in peers info).
+// Results are informational only - route selectors do not use them.
+// Probe is not a read-only query (it updates router state), so it is processed in configuration lane.
+// Exec only starts a probe in background, thus does not delay other configuration commands.
+package bwprobe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/ctlserver"
+	"github.com/SyntropyNet/syntropy-agent/agent/mole"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/bwprobe"
+)
+
+const (
+	cmd     = "BANDWIDTH_PROBE"
+	pkgName = "Bandwidth_Probe. "
+	// wireguard interfaces come and go. Check tunnel addresses this often
+	listenersUpdatePeriod = 10 * time.Second
+)
+
+type BandwidthProbe struct {
+	sync.Mutex
+	ctx       context.Context
+	writer    io.Writer
+	mole      *mole.Mole
+	port      uint16
+	listeners map[netip.Addr]*bwprobe.Server
+	// probes are run one at a time. Parallel probes would share links and spoil results
+	probeLock sync.Mutex
+}
+
+// path is a single wireguard peer to be probed
+type path struct {
+	ifname       string
+	connectionID int
+	local        netip.Addr
+	remote       netip.Addr
+}
+
+func New(w io.Writer, m *mole.Mole, port uint16) *BandwidthProbe {
+	return &BandwidthProbe{
+		writer:    w,
+		mole:      m,
+		port:      port,
+		listeners: make(map[netip.Addr]*bwprobe.Server),
+	}
+}
+
+func (obj *BandwidthProbe) Name() string {
+	return cmd
+}
+
+func (obj *BandwidthProbe) Run(ctx context.Context) error {
+	if obj.ctx != nil {
+		return fmt.Errorf("%s is already running", pkgName)
+	}
+	obj.ctx = ctx
+
+	go func() {
+		ticker := time.NewTicker(listenersUpdatePeriod)
+		defer ticker.Stop()
+
+		obj.updateListeners()
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Debug().Println(pkgName, "stopping", cmd)
+				obj.closeListeners()
+				return
+			case <-ticker.C:
+				obj.updateListeners()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// updateListeners starts probe servers on new tunnel addresses and stops them on removed ones
+func (obj *BandwidthProbe) updateListeners() {
+	obj.Lock()
+	defer obj.Unlock()
+
+	addrs := make(map[netip.Addr]bool)
+	for _, dev := range obj.mole.Wireguard().Devices() {
+		if dev.IP.IsValid() {
+			addrs[dev.IP] = true
+		}
+	}
+
+	for addr, srv := range obj.listeners {
+		if !addrs[addr] {
+			logger.Debug().Println(pkgName, "stop listening on", addr)
+			srv.Close()
+			delete(obj.listeners, addr)
+		}
+	}
+
+	for addr := range addrs {
+		if _, ok := obj.listeners[addr]; ok {
+			continue
+		}
+		srv, err := bwprobe.Listen(netip.AddrPortFrom(addr, obj.port))
+		if err != nil {
+			// Tunnel address may be not configured yet. Will retry later.
+			logger.Warning().Println(pkgName, "listen", err)
+			continue
+		}
+		logger.Debug().Println(pkgName, "listening on", srv.Addr())
+		obj.listeners[addr] = srv
+		go func() {
+			err := srv.Serve()
+			if err != nil {
+				logger.Error().Println(pkgName, "serve", err)
+			}
+		}()
+	}
+}
+
+func (obj *BandwidthProbe) closeListeners() {
+	obj.Lock()
+	defer obj.Unlock()
+
+	for addr, srv := range obj.listeners {
+		srv.Close()
+		delete(obj.listeners, addr)
+	}
+}
+
+// Exec runs requested probes in background and reports results to controller.
+// Probes last several seconds, and must not block other commands.
+func (obj *BandwidthProbe) Exec(raw []byte) error {
+	var req bandwidthProbeRequest
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		return err
+	}
+
+	ctx := obj.ctx
+	if ctx == nil {
+		return fmt.Errorf("%s is not running", pkgName)
+	}
+
+	go func() {
+		results := obj.Probe(ctx, &req.Data)
+		resp := newResponseMsg(req.ID, results)
+		arr, err := json.Marshal(resp)
+		if err != nil {
+			logger.Error().Println(pkgName, "json marshal", err)
+			return
+		}
+		obj.writer.Write(arr)
+	}()
+
+	return nil
+}
+
+// Probe measures bandwidth of requested paths one by one
+func (obj *BandwidthProbe) Probe(ctx context.Context, req *ctlapi.BandwidthRequest) []ctlapi.BandwidthResult {
+	return obj.probe(ctx, obj.paths(req), time.Duration(req.Duration)*time.Second)
+}
+
+func (obj *BandwidthProbe) probe(ctx context.Context, paths []path, duration time.Duration) []ctlapi.BandwidthResult {
+	obj.probeLock.Lock()
+	defer obj.probeLock.Unlock()
+
+	results := []ctlapi.BandwidthResult{}
+
+	for _, p := range paths {
+		result := ctlapi.BandwidthResult{
+			Address:      p.remote.String(),
+			IfName:       p.ifname,
+			ConnectionID: p.connectionID,
+		}
+
+		if !p.local.IsValid() {
+			result.Error = "unknown path"
+			results = append(results, result)
+			continue
+		}
+
+		res, err := bwprobe.Probe(ctx, p.local, netip.AddrPortFrom(p.remote, obj.port), duration)
+		if err != nil {
+			logger.Warning().Println(pkgName, "probe", p.remote, "via", p.ifname, err)
+			result.Error = err.Error()
+		} else {
+			result.Bandwidth = float32(res.Bandwidth())
+			result.Bytes = res.Bytes
+			result.Duration = res.Duration.Milliseconds()
+			logger.Info().Println(pkgName, p.remote, "via", p.ifname, result.Bandwidth, "Mbps")
+			// Make results available to route selector
+			obj.mole.Router().SetPeerBandwidth(p.remote, result.Bandwidth)
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// paths resolves requested addresses and connection IDs to wireguard peers
+// Unknown addresses are returned without local address
+func (obj *BandwidthProbe) paths(req *ctlapi.BandwidthRequest) []path {
+	var rv []path
+	known := make(map[netip.Addr]path)
+	connections := make(map[int]bool)
+	for _, id := range req.ConnectionIDs {
+		connections[id] = true
+	}
+
+	for _, dev := range obj.mole.Wireguard().Devices() {
+		for _, peer := range dev.Peers() {
+			if len(peer.AllowedIPs) == 0 {
+				continue
+			}
+			p := path{
+				ifname:       peer.IfName,
+				connectionID: peer.ConnectionID,
+				local:        dev.IP,
+				remote:       peer.AllowedIPs[0].Addr(),
+			}
+			known[p.remote] = p
+			if connections[peer.ConnectionID] {
+				rv = append(rv, p)
+			}
+		}
+	}
+
+	for _, str := range req.Addresses {
+		addr, err := netip.ParseAddr(str)
+		if err != nil {
+			logger.Warning().Println(pkgName, "invalid address", str, err)
+			continue
+		}
+		p, ok := known[addr]
+		if !ok {
+			p = path{remote: addr}
+		}
+		rv = append(rv, p)
+	}
+
+	return rv
+}
+
+// CtlHandler is a control socket handler, which runs probes and waits for results
+// Requests, that would not complete before control socket write timeout, are rejected
+func (obj *BandwidthProbe) CtlHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		ctlserver.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req ctlapi.BandwidthRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ctlserver.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	paths := obj.paths(&req)
+	duration := time.Duration(req.Duration) * time.Second
+	if timeout := probeTimeout(paths, duration); timeout > ctlserver.WriteTimeout {
+		ctlserver.WriteError(w, http.StatusBadRequest,
+			fmt.Sprintf("%d paths probe may take up to %s, which exceeds %s limit. Probe fewer paths or use shorter duration",
+				len(paths), timeout, ctlserver.WriteTimeout))
+		return
+	}
+
+	ctlserver.WriteJSON(w, obj.probe(r.Context(), paths, duration))
+}
+
+// probeTimeout returns the longest time probing paths may take
+// Unknown paths are not probed
+func probeTimeout(paths []path, duration time.Duration) time.Duration {
+	var rv time.Duration
+	for _, p := range paths {
+		if p.local.IsValid() {
+			rv = rv + bwprobe.Timeout(duration)
+		}
+	}
+	return rv
+}
@@ -0,0 +1,34 @@
+package bwprobe
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/ctlserver"
+	"github.com/SyntropyNet/syntropy-agent/pkg/bwprobe"
+)
+
+func TestProbeTimeout(t *testing.T) {
+	known := path{local: netip.MustParseAddr("10.0.0.1"), remote: netip.MustParseAddr("10.0.0.2")}
+	unknown := path{remote: netip.MustParseAddr("10.0.0.3")}
+
+	// Unknown paths are not probed
+	if timeout := probeTimeout([]path{known, unknown}, 0); timeout != bwprobe.Timeout(bwprobe.DefaultDuration) {
+		t.Errorf("Invalid default duration timeout %s", timeout)
+	}
+
+	// Duration is limited per path
+	paths := []path{known, known, known}
+	if timeout := probeTimeout(paths, time.Hour); timeout != 3*bwprobe.Timeout(bwprobe.MaxDuration) {
+		t.Errorf("Invalid max duration timeout %s", timeout)
+	}
+
+	// Many paths do not fit into control socket timeout
+	for len(paths) < 10 {
+		paths = append(paths, known)
+	}
+	if timeout := probeTimeout(paths, bwprobe.MaxDuration); timeout <= ctlserver.WriteTimeout {
+		t.Errorf("%d paths fit into control socket timeout %s", len(paths), timeout)
+	}
+}
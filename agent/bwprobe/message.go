@@ -0,0 +1,28 @@
+package bwprobe
+
+import (
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+)
+
+type bandwidthProbeRequest struct {
+	common.MessageHeader
+	Data ctlapi.BandwidthRequest `json:"data"`
+}
+
+type bandwidthProbeResponse struct {
+	common.MessageHeader
+	Data struct {
+		Results []ctlapi.BandwidthResult `json:"results"`
+	} `json:"data"`
+}
+
+func newResponseMsg(id string, results []ctlapi.BandwidthResult) *bandwidthProbeResponse {
+	msg := &bandwidthProbeResponse{}
+	msg.ID = id
+	msg.MsgType = cmd
+	msg.Data.Results = results
+	msg.Now()
+
+	return msg
+}
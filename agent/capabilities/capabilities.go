@@ -0,0 +1,177 @@
+// capabilities package tells controller, what this agent build supports,
+// and negotiates protocol version with it.
+// This allows controller to manage mixed version agents safely.
+package capabilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+// Protocol versions, supported by this agent:
+//
+//	1 - legacy protocol. Unknown commands are ignored.
+//	2 - capabilities negotiation. Unknown commands are replied with UNKNOWN_COMMAND error.
+//	3 - COMMAND_ACK is sent for every command with message ID (including unknown commands).
+const (
+	ProtocolMin = 1
+	ProtocolMax = 3
+)
+
+// Route strategies and network APIs in the order of config constants
+var routeStrategies = []string{"speed", "dr", "cost"}
+var networkAPIs = []string{config.ContainerTypeDocker, config.ContainerTypeKubernetes, config.ContainerTypeHost}
+
+type Capabilities struct {
+	w        io.Writer
+	commands func() []CommandInfo
+	protocol int32
+}
+
+// New creates capabilities reporter. commands returns registered commands info.
+func New(w io.Writer, commands func() []CommandInfo) *Capabilities {
+	return &Capabilities{
+		w:        w,
+		commands: commands,
+		// Until controller negotiates - talk the legacy protocol
+		protocol: ProtocolMin,
+	}
+}
+
+// Name returns PROTOCOL_VERSION command name. Controller sends it to negotiate protocol version.
+// It is processed in configuration lane (it is not a QueryCommand), because acknowledges
+// of the following configuration commands depend on the negotiated version.
+func (c *Capabilities) Name() string {
+	return cmdProtocol
+}
+
+// Protocol returns negotiated protocol version
+func (c *Capabilities) Protocol() int {
+	return int(atomic.LoadInt32(&c.protocol))
+}
+
+// Reset falls back to the legacy protocol until controller negotiates again.
+// Must be called on every (re)connect, because agent may be connected to a different (older) controller.
+func (c *Capabilities) Reset() {
+	atomic.StoreInt32(&c.protocol, ProtocolMin)
+}
+
+func features() []string {
+	rv := []string{}
+	add := func(enabled bool, name string) {
+		if enabled {
+			rv = append(rv, name)
+		}
+	}
+	add(config.DryRun(), "dry_run")
+	add(config.StateSnapshotEnabled(), "state_snapshot")
+	add(config.ApplyRollback(), "apply_rollback")
+	add(config.RouteMultipath(), "route_multipath")
+	add(config.BandwidthProbeEnabled(), "bandwidth_probe")
+	add(config.MetricsExporterEnabled(), "metrics_exporter")
+	add(config.IsVPNClient(), "vpn_client")
+	add(config.QueuePersist(), "queue_persist")
+
+	return rv
+}
+
+// Send sends agent capabilities to controller.
+// Should be sent every time agent (re)connects to controller.
+func (c *Capabilities) Send() error {
+	msg := capabilitiesMessage{}
+	msg.ID = env.MessageDefaultID
+	msg.MsgType = cmd
+	msg.Now()
+
+	msg.Data.AgentVersion = config.GetVersion()
+	msg.Data.Protocol.Min = ProtocolMin
+	msg.Data.Protocol.Max = ProtocolMax
+	msg.Data.Protocol.Current = c.Protocol()
+	msg.Data.Commands = c.commands()
+	msg.Data.RouteStrategies = routeStrategies
+	if s := config.GetRouteStrategy(); s >= 0 && s < len(routeStrategies) {
+		msg.Data.RouteStrategy = routeStrategies[s]
+	}
+	msg.Data.NetworkAPIs = networkAPIs
+	msg.Data.NetworkAPI = config.GetContainerType()
+	msg.Data.Features = features()
+
+	raw, err := json.Marshal(&msg)
+	if err != nil {
+		return err
+	}
+
+	logger.Message().Println(pkgName, "Sending: ", string(raw))
+	_, err = c.w.Write(raw)
+	return err
+}
+
+// Exec accepts protocol version, proposed by controller
+func (c *Capabilities) Exec(raw []byte) error {
+	var req protocolMessage
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		return err
+	}
+
+	if req.Data.Version < ProtocolMin || req.Data.Version > ProtocolMax {
+		errMsg := fmt.Sprintf("unsupported protocol version %d (supported %d-%d)",
+			req.Data.Version, ProtocolMin, ProtocolMax)
+		c.sendError(req.MessageHeader, "UNSUPPORTED_PROTOCOL", errMsg)
+		return errors.New(errMsg)
+	}
+
+	atomic.StoreInt32(&c.protocol, int32(req.Data.Version))
+	logger.Info().Println(pkgName, "Protocol version", req.Data.Version, "negotiated")
+
+	resp := protocolMessage{
+		MessageHeader: req.MessageHeader,
+	}
+	resp.Data.Version = req.Data.Version
+	resp.Now()
+	arr, err := json.Marshal(&resp)
+	if err != nil {
+		return err
+	}
+
+	logger.Message().Println(pkgName, "Sending: ", string(arr))
+	_, err = c.w.Write(arr)
+	return err
+}
+
+func (c *Capabilities) sendError(header common.MessageHeader, errType, errMsg string) {
+	resp := common.ErrorResponse{
+		MessageHeader: header,
+	}
+	resp.Data.Type = errType
+	resp.Data.Message = errMsg
+	resp.Now()
+
+	arr, err := json.Marshal(&resp)
+	if err != nil {
+		return
+	}
+	c.w.Write(arr)
+}
+
+// Acknowledge returns true, if COMMAND_ACK should be sent for commands
+func (c *Capabilities) Acknowledge() bool {
+	return c.Protocol() >= 3
+}
+
+// UnknownCommand replies to not supported command.
+// In legacy protocol unknown commands are silently ignored.
+func (c *Capabilities) UnknownCommand(header common.MessageHeader) {
+	if c.Protocol() < 2 {
+		return
+	}
+	c.sendError(header, "UNKNOWN_COMMAND", fmt.Sprintf("command '%s' is not supported", header.MsgType))
+}
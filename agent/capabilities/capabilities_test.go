@@ -0,0 +1,47 @@
+package capabilities
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+)
+
+func TestProtocolNegotiation(t *testing.T) {
+	var buf bytes.Buffer
+	c := New(&buf, func() []CommandInfo { return nil })
+
+	// Legacy protocol ignores unknown commands
+	c.UnknownCommand(common.MessageHeader{ID: "1", MsgType: "NEW_COMMAND"})
+	if buf.Len() != 0 {
+		t.Errorf("Unknown command replied in legacy protocol: %s", buf.String())
+	}
+
+	err := c.Exec([]byte(`{"id":"2","type":"PROTOCOL_VERSION","data":{"version":4}}`))
+	if err == nil || c.Protocol() != ProtocolMin {
+		t.Errorf("Unsupported protocol accepted")
+	}
+	buf.Reset()
+
+	err = c.Exec([]byte(`{"id":"3","type":"PROTOCOL_VERSION","data":{"version":2}}`))
+	if err != nil || c.Protocol() != 2 {
+		t.Fatalf("Protocol negotiation failed %s", err)
+	}
+	buf.Reset()
+
+	c.UnknownCommand(common.MessageHeader{ID: "4", MsgType: "NEW_COMMAND"})
+	var resp common.ErrorResponse
+	err = json.Unmarshal(buf.Bytes(), &resp)
+	if err != nil || resp.ID != "4" || resp.Data.Type != "UNKNOWN_COMMAND" {
+		t.Errorf("Invalid unknown command reply %s", buf.String())
+	}
+
+	// Reconnected controller must negotiate again
+	c.Reset()
+	buf.Reset()
+	c.UnknownCommand(common.MessageHeader{ID: "5", MsgType: "NEW_COMMAND"})
+	if c.Protocol() != ProtocolMin || buf.Len() != 0 {
+		t.Errorf("Protocol was not reset")
+	}
+}
@@ -0,0 +1,39 @@
+package capabilities
+
+import "github.com/SyntropyNet/syntropy-agent/agent/common"
+
+const (
+	cmd         = "AGENT_CAPABILITIES"
+	cmdProtocol = "PROTOCOL_VERSION"
+	pkgName     = "Capabilities. "
+)
+
+type CommandInfo struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+type capabilitiesMessage struct {
+	common.MessageHeader
+	Data struct {
+		AgentVersion string `json:"agent_version"`
+		Protocol     struct {
+			Min     int `json:"min"`
+			Max     int `json:"max"`
+			Current int `json:"current"`
+		} `json:"protocol"`
+		Commands        []CommandInfo `json:"commands"`
+		RouteStrategies []string      `json:"route_strategies"`
+		RouteStrategy   string        `json:"route_strategy"`
+		NetworkAPIs     []string      `json:"network_apis"`
+		NetworkAPI      string        `json:"network_api"`
+		Features        []string      `json:"features"`
+	} `json:"data"`
+}
+
+type protocolMessage struct {
+	common.MessageHeader
+	Data struct {
+		Version int `json:"version"`
+	} `json:"data"`
+}
@@ -2,9 +2,17 @@ package agent
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
 	"time"
 
+	"github.com/SyntropyNet/syntropy-agent/agent/capabilities"
 	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/agent/ctlserver"
+	"github.com/SyntropyNet/syntropy-agent/agent/scheduler"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 )
 
@@ -13,6 +21,48 @@ func (a *Agent) addCommand(cmd common.Command) error {
 	return nil
 }
 
+// commandsInfo returns registered commands with their versions, sorted by name
+func (a *Agent) commandsInfo() []capabilities.CommandInfo {
+	rv := []capabilities.CommandInfo{}
+	for name, cmd := range a.commands {
+		version := 1
+		if vc, ok := cmd.(common.VersionedCommand); ok {
+			version = vc.Version()
+		}
+		rv = append(rv, capabilities.CommandInfo{Name: name, Version: version})
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Name < rv[j].Name })
+	return rv
+}
+
+// commandLane is a scheduler classifier. Read-only queries are processed in separate lanes (short and long ones).
+// Configuration commands keep their order, but full configuration supersedes pending ones.
+func (a *Agent) commandLane(h *scheduler.Header) (int, []string) {
+	cmd, ok := a.commands[h.MsgType]
+	if !ok {
+		// Will be replied as unknown command. No need to wait for configuration.
+		return scheduler.LaneQuery, nil
+	}
+	if q, ok := cmd.(common.QueryCommand); ok && q.ReadOnly() {
+		if l, ok := cmd.(common.LongRunningCommand); ok && l.LongRunning() {
+			return scheduler.LaneLongQuery, nil
+		}
+		return scheduler.LaneQuery, nil
+	}
+	if s, ok := cmd.(common.SupersedingCommand); ok {
+		return scheduler.LaneConfig, s.Supersedes()
+	}
+	return scheduler.LaneConfig, nil
+}
+
+// superseded acknowledges command, that was dropped without processing
+func (a *Agent) superseded(req, by common.MessageHeader) {
+	if a.capabilities.Acknowledge() {
+		a.acknowledge(req, 0, common.NewClassifiedError(common.ErrorClassSuperseded,
+			fmt.Errorf("superseded by %s %s", by.MsgType, by.ID)))
+	}
+}
+
 func (a *Agent) processCommand(raw []byte) {
 	var req common.MessageHeader
 	if err := json.Unmarshal(raw, &req); err != nil {
@@ -24,6 +74,12 @@ func (a *Agent) processCommand(raw []byte) {
 	if !ok {
 		logger.Warning().Printf("%s Command '%s' not found\n", pkgName, req.MsgType)
 		logger.Message().Println(pkgName, "Received:", string(raw))
+		if a.capabilities.Acknowledge() {
+			a.acknowledge(req, 0, common.NewClassifiedError(common.ErrorClassUnknownCommand,
+				fmt.Errorf("command '%s' is not supported", req.MsgType)))
+		} else {
+			a.capabilities.UnknownCommand(req)
+		}
 		return
 	}
 
@@ -33,5 +89,61 @@ func (a *Agent) processCommand(raw []byte) {
 	if err != nil {
 		logger.Error().Printf("%s Command '%s' failed: %s\n", pkgName, req.MsgType, err.Error())
 	}
-	logger.Info().Printf("%s Command '%s' completed in %s.", pkgName, req.MsgType, time.Now().Sub(started))
+	duration := time.Now().Sub(started)
+	logger.Info().Printf("%s Command '%s' completed in %s.", pkgName, req.MsgType, duration)
+
+	if a.capabilities.Acknowledge() {
+		a.acknowledge(req, duration, err)
+	}
+}
+
+// acknowledge sends command execution result to controller.
+// Messages without ID (e.g. periodic requests) are not acknowledged.
+func (a *Agent) acknowledge(req common.MessageHeader, duration time.Duration, err error) {
+	if req.ID == "" || req.ID == env.MessageDefaultID {
+		return
+	}
+
+	ack := common.NewCommandAck(req, duration, err)
+	arr, err := json.Marshal(ack)
+	if err != nil {
+		logger.Error().Println(pkgName, "command ack", err)
+		return
+	}
+	logger.Message().Println(pkgName, "Sending: ", string(arr))
+	a.controller.Write(arr)
+}
+
+// planCommand is a control socket handler, which dry-runs
+// configuration message (CONFIG_INFO, WG_CONF) and returns planned changes
+func (a *Agent) planCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		ctlserver.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		ctlserver.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req common.MessageHeader
+	if err := json.Unmarshal(raw, &req); err != nil {
+		ctlserver.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cmd, ok := a.commands[req.MsgType].(common.PlanCommand)
+	if !ok {
+		ctlserver.WriteError(w, http.StatusBadRequest, "command '"+req.MsgType+"' does not support dry-run")
+		return
+	}
+
+	plan, err := cmd.Plan(raw)
+	if err != nil {
+		ctlserver.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctlserver.WriteJSON(w, plan)
 }
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const cmdAck = "COMMAND_ACK"
+
+// Command acknowledgement statuses
+const (
+	AckStatusOK      = "ok"
+	AckStatusPartial = "partial"
+	AckStatusError   = "error"
+)
+
+// Error classes, reported in command acknowledgement
+const (
+	ErrorClassInvalidMessage = "INVALID_MESSAGE"
+	ErrorClassUnknownCommand = "UNKNOWN_COMMAND"
+	ErrorClassExecution      = "EXECUTION_ERROR"
+	ErrorClassPartial        = "PARTIAL_FAILURE"
+	ErrorClassSuperseded     = "SUPERSEDED"
+)
+
+// ClassifiedError is a command error with explicit error class
+type ClassifiedError struct {
+	Class string
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+func NewClassifiedError(class string, err error) error {
+	return &ClassifiedError{
+		Class: class,
+		Err:   err,
+	}
+}
+
+// ItemFailure is a failed part of command (e.g. a peer of CONFIG_INFO)
+type ItemFailure struct {
+	Item  string `json:"item"`
+	Error string `json:"error"`
+}
+
+// PartialError is returned by commands, that were applied, but some of their items failed
+type PartialError struct {
+	Total    int
+	Failures []ItemFailure
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d of %d items failed", len(e.Failures), e.Total)
+}
+
+// Add counts processed item. Failure is recorded if err is not nil.
+func (e *PartialError) Add(item string, err error) {
+	e.Total++
+	if err != nil {
+		e.Failures = append(e.Failures, ItemFailure{Item: item, Error: err.Error()})
+	}
+}
+
+// Err returns nil, if no items failed. Otherwise returns itself.
+func (e *PartialError) Err() error {
+	if e == nil || len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+type AckError struct {
+	Class   string `json:"class"`
+	Message string `json:"message"`
+}
+
+// CommandAck is a uniform command execution result, sent for every command with message ID
+type CommandAck struct {
+	MessageHeader
+	Data struct {
+		Command  string        `json:"command"`
+		Status   string        `json:"status"`
+		Duration int64         `json:"duration_ms"`
+		Error    *AckError     `json:"error,omitempty"`
+		Total    int           `json:"total,omitempty"`
+		Failures []ItemFailure `json:"failures,omitempty"`
+	} `json:"data"`
+}
+
+func errorClass(err error) string {
+	var classified *ClassifiedError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &classified):
+		return classified.Class
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return ErrorClassInvalidMessage
+	default:
+		return ErrorClassExecution
+	}
+}
+
+// NewCommandAck creates acknowledgement of command (identified by req), completed with err.
+func NewCommandAck(req MessageHeader, duration time.Duration, err error) *CommandAck {
+	ack := &CommandAck{
+		MessageHeader: MessageHeader{
+			ID:      req.ID,
+			MsgType: cmdAck,
+		},
+	}
+	ack.Data.Command = req.MsgType
+	ack.Data.Duration = duration.Milliseconds()
+	ack.Now()
+
+	var partial *PartialError
+	switch {
+	case err == nil:
+		ack.Data.Status = AckStatusOK
+	case errors.As(err, &partial):
+		ack.Data.Status = AckStatusPartial
+		ack.Data.Error = &AckError{Class: ErrorClassPartial, Message: partial.Error()}
+		ack.Data.Total = partial.Total
+		ack.Data.Failures = partial.Failures
+	default:
+		ack.Data.Status = AckStatusError
+		ack.Data.Error = &AckError{Class: errorClass(err), Message: err.Error()}
+	}
+
+	return ack
+}
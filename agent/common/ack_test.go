@@ -0,0 +1,54 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCommandAck(t *testing.T) {
+	req := MessageHeader{ID: "42", MsgType: "CONFIG_INFO"}
+
+	ack := NewCommandAck(req, 1500*time.Millisecond, nil)
+	if ack.ID != "42" || ack.MsgType != "COMMAND_ACK" || ack.Data.Command != "CONFIG_INFO" ||
+		ack.Data.Status != AckStatusOK || ack.Data.Duration != 1500 || ack.Data.Error != nil {
+		t.Errorf("Invalid success ack %+v", ack)
+	}
+
+	failures := &PartialError{}
+	for i := 0; i < 200; i++ {
+		var err error
+		if i%70 == 0 {
+			err = errors.New("peer add failed")
+		}
+		failures.Add(fmt.Sprintf("peer %d", i), err)
+	}
+	ack = NewCommandAck(req, time.Second, failures.Err())
+	if ack.Data.Status != AckStatusPartial || ack.Data.Total != 200 || len(ack.Data.Failures) != 3 ||
+		ack.Data.Error.Class != ErrorClassPartial || ack.Data.Error.Message != "3 of 200 items failed" {
+		t.Errorf("Invalid partial ack %+v", ack.Data)
+	}
+
+	if (&PartialError{Total: 5}).Err() != nil {
+		t.Errorf("No failures must be no error")
+	}
+
+	var v struct{ A int }
+	err := json.Unmarshal([]byte(`{"A":"x"}`), &v)
+	ack = NewCommandAck(req, 0, fmt.Errorf("parse: %w", err))
+	if ack.Data.Status != AckStatusError || ack.Data.Error.Class != ErrorClassInvalidMessage {
+		t.Errorf("Invalid message error class %+v", ack.Data.Error)
+	}
+
+	ack = NewCommandAck(req, 0, NewClassifiedError(ErrorClassUnknownCommand, errors.New("not supported")))
+	if ack.Data.Error.Class != ErrorClassUnknownCommand {
+		t.Errorf("Invalid classified error %+v", ack.Data.Error)
+	}
+
+	ack = NewCommandAck(req, 0, errors.New("commit failed"))
+	if ack.Data.Error.Class != ErrorClassExecution {
+		t.Errorf("Invalid execution error class %+v", ack.Data.Error)
+	}
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"net/netip"
+
+	"github.com/SyntropyNet/syntropy-agent/pkg/netcfg"
+)
+
+// AddrFamilies describes which IP families host can reach (has default route for)
+type AddrFamilies struct {
+	IPv4 bool
+	IPv6 bool
+}
+
+// ReachableFamilies checks host default routes.
+// It dumps routing table, so call it once per message, not per peer.
+func ReachableFamilies() AddrFamilies {
+	_, _, err4 := netcfg.DefaultRoute()
+	_, _, err6 := netcfg.DefaultRoute6()
+	return AddrFamilies{
+		IPv4: err4 == nil,
+		IPv6: err6 == nil,
+	}
+}
+
+// ParseDualStackAddr parses IPv4 and IPv6 address pair, as sent by controller.
+// If both addresses are valid - IPv4 is preferred, unless host has IPv6 connectivity only.
+// Returns invalid (zero) address, if none of them could be parsed.
+func (f AddrFamilies) ParseDualStackAddr(ipv4, ipv6 string) netip.Addr {
+	addr4, err4 := netip.ParseAddr(ipv4)
+	addr6, err6 := netip.ParseAddr(ipv6)
+
+	switch {
+	case err4 != nil && err6 != nil:
+		return netip.Addr{}
+	case err6 != nil:
+		return addr4
+	case err4 != nil:
+		return addr6
+	}
+
+	// Both are present. Use the reachable one
+	if !f.IPv4 && f.IPv6 {
+		return addr6
+	}
+	return addr4
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseDualStackAddr(t *testing.T) {
+	v4 := netip.MustParseAddr("1.2.3.4")
+	v6 := netip.MustParseAddr("2001:db8::1")
+
+	tests := []struct {
+		families   AddrFamilies
+		ipv4, ipv6 string
+		expect     netip.Addr
+	}{
+		{AddrFamilies{true, true}, "1.2.3.4", "2001:db8::1", v4},
+		{AddrFamilies{true, false}, "1.2.3.4", "2001:db8::1", v4},
+		{AddrFamilies{false, true}, "1.2.3.4", "2001:db8::1", v6},
+		// Neither is reachable - keep IPv4 preference
+		{AddrFamilies{false, false}, "1.2.3.4", "2001:db8::1", v4},
+		// Single address is used regardless of connectivity
+		{AddrFamilies{false, true}, "1.2.3.4", "", v4},
+		{AddrFamilies{true, false}, "", "2001:db8::1", v6},
+		{AddrFamilies{true, false}, "invalid", "2001:db8::1", v6},
+		{AddrFamilies{true, true}, "", "", netip.Addr{}},
+	}
+
+	for _, tt := range tests {
+		addr := tt.families.ParseDualStackAddr(tt.ipv4, tt.ipv6)
+		if addr != tt.expect {
+			t.Errorf("%+v %q %q: expected %s, got %s", tt.families, tt.ipv4, tt.ipv6, tt.expect, addr)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package common
 
-import "context"
+import (
+	"context"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+)
 
 // Command interface is used for controller commands executors
 type Command interface {
@@ -21,6 +25,41 @@ type CommandService interface {
 	Service
 }
 
+// PlanCommand is a controller command, that supports dry-run (plan) mode
+// Plan calculates changes, that Exec would do, without applying them
+type PlanCommand interface {
+	Command
+	Plan(data []byte) (*ctlapi.Plan, error)
+}
+
+// VersionedCommand is a controller command, that reports its message format version.
+// Commands, that do not implement it, have version 1.
+type VersionedCommand interface {
+	Command
+	Version() int
+}
+
+// QueryCommand is a read-only controller command.
+// Queries are processed in a separate lane and are not delayed by long configuration changes.
+type QueryCommand interface {
+	Command
+	ReadOnly() bool
+}
+
+// LongRunningCommand is a query, that takes long to complete (e.g. runs external commands).
+// Long queries are processed in a separate lane and do not delay other queries.
+type LongRunningCommand interface {
+	QueryCommand
+	LongRunning() bool
+}
+
+// SupersedingCommand carries full configuration.
+// Pending (not yet processed) commands of types it supersedes are dropped.
+type SupersedingCommand interface {
+	Command
+	Supersedes() []string
+}
+
 type SupportInfoHelper interface {
 	SupportInfo() *KeyValue
 }
@@ -3,6 +3,7 @@ package common
 import (
 	"time"
 
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
 	"github.com/SyntropyNet/syntropy-agent/internal/env"
 )
 
@@ -29,3 +30,21 @@ type KeyValue struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
 }
+
+// PlanResponse reports dry-run results of a configuration message
+type PlanResponse struct {
+	MessageHeader
+	Data *ctlapi.Plan `json:"data"`
+}
+
+func NewPlanResponse(id string, plan *ctlapi.Plan) *PlanResponse {
+	resp := &PlanResponse{
+		MessageHeader: MessageHeader{
+			ID:      id,
+			MsgType: "CONFIG_PLAN",
+		},
+		Data: plan,
+	}
+	resp.Now()
+	return resp
+}
@@ -2,34 +2,92 @@ package configinfo
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 
 	"github.com/SyntropyNet/syntropy-agent/agent/docker"
 	"github.com/SyntropyNet/syntropy-agent/agent/mole"
+	"github.com/SyntropyNet/syntropy-agent/agent/snapshot"
 
 	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/agent/swireguard"
 	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 )
 
 const (
 	cmd     = "CONFIG_INFO"
-	cmdResp = "UPDATE_AGENT_CONFIG"
 	pkgName = "Config_Info. "
 )
 
 type configInfo struct {
-	writer io.Writer
-	mole   *mole.Mole
-	docker docker.DockerHelper
+	writer   io.Writer
+	mole     *mole.Mole
+	docker   docker.DockerHelper
+	snapshot *snapshot.Store
+	// interfaces private keys and peers preshared keys, used only when restoring from snapshot
+	restore bool
+	keys    map[string]string
+	psks    map[string]string
 }
 
-func New(w io.Writer, m *mole.Mole, d docker.DockerHelper) common.Command {
+func New(w io.Writer, m *mole.Mole, d docker.DockerHelper, s *snapshot.Store) common.Command {
 	return &configInfo{
-		writer: w,
-		mole:   m,
-		docker: d,
+		writer:   w,
+		mole:     m,
+		docker:   d,
+		snapshot: s,
+	}
+}
+
+// Restore replays CONFIG_INFO message from snapshot.
+// Nothing is sent to controller and mole.Apply() is left for the caller.
+func Restore(m *mole.Mole, d docker.DockerHelper, raw []byte, keys, psks map[string]string) error {
+	obj := &configInfo{
+		writer:  io.Discard,
+		mole:    m,
+		docker:  d,
+		restore: true,
+		keys:    keys,
+		psks:    psks,
+	}
+
+	var req configInfoMsg
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		return err
+	}
+
+	resp := &swireguard.UpdateAgentConfigMsg{}
+	obj.configure(obj.mole, obj.docker, &req, resp)
+
+	return nil
+}
+
+// Plan calculates changes CONFIG_INFO message would do, without applying them
+func (obj *configInfo) Plan(raw []byte) (*ctlapi.Plan, error) {
+	var req configInfoMsg
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.plan(&req), nil
+}
+
+func (obj *configInfo) plan(req *configInfoMsg) *ctlapi.Plan {
+	planner := obj.mole.NewPlanner()
+	// Docker networks are not touched in dry-run mode
+	obj.configure(planner, &docker.DockerNull{}, req, &swireguard.UpdateAgentConfigMsg{})
+	return planner.Plan(cmd)
+}
+
+// presets private key, if restoring from snapshot
+func (obj *configInfo) presetKey(wgi *swireguard.InterfaceInfo) {
+	if key, ok := obj.keys[wgi.IfName]; ok {
+		wgi.SetPrivateKey(key)
 	}
 }
 
@@ -37,19 +95,34 @@ func (obj *configInfo) Name() string {
 	return cmd
 }
 
-func (obj *configInfo) processInterface(e *configInfoNetworkEntry, name string, resp *updateAgentConfigMsg) {
+// Version 2 supports dry_run (plan) flag
+// Version 3 supports peers preshared keys (delivered or generated)
+func (obj *configInfo) Version() int {
+	return 3
+}
+
+// CONFIG_INFO is a full configuration. Pending older configuration changes are obsolete.
+func (obj *configInfo) Supersedes() []string {
+	return []string{cmd, "WG_CONF"}
+}
+
+func (obj *configInfo) processInterface(target mole.Configurer, e *configInfoNetworkEntry, name string,
+	resp *swireguard.UpdateAgentConfigMsg, failures *common.PartialError) {
 	if e == nil {
 		return
 	}
 	wgi, err := e.asInterfaceInfo(name)
 	if err != nil {
 		logger.Error().Println(pkgName, "parse network", name, "failed", err)
+		failures.Add("interface "+name, err)
 		return
 	}
-	err = obj.mole.CreateInterface(wgi)
+	obj.presetKey(wgi)
+	err = target.CreateInterface(wgi)
 	if err != nil {
 		logger.Error().Printf("%s Create interface %s error: %s\n", pkgName, wgi.IfName, err)
 	}
+	failures.Add("interface "+name, err)
 
 	if e.PublicKey != wgi.PublicKey || e.Port != wgi.Port {
 		resp.AddInterface(wgi)
@@ -63,6 +136,17 @@ func (obj *configInfo) Exec(raw []byte) error {
 		return err
 	}
 
+	if req.DryRun || config.DryRun() {
+		logger.Info().Println(pkgName, "Dry-run. Configuration is not applied.")
+		arr, err := json.Marshal(common.NewPlanResponse(req.ID, obj.plan(&req)))
+		if err != nil {
+			return err
+		}
+		logger.Message().Println(pkgName, "Sending: ", string(arr))
+		obj.writer.Write(arr)
+		return nil
+	}
+
 	// Network section is empty is a special case
 	// agent is deleted in the UI
 	if req.Data.Network.Public == nil &&
@@ -71,6 +155,9 @@ func (obj *configInfo) Exec(raw []byte) error {
 		req.Data.Network.Sdn3 == nil {
 		logger.Info().Println(pkgName, "Platform Agent deletion in progress.")
 
+		// Do not restore deleted agent configuration on next start
+		obj.snapshot.Remove()
+
 		// Cleanup will be done on mole, wireguard and router Close functions.
 		// But CleanupOnExit must be enabled. Force enable it
 		config.ForceCleanupOnExit()
@@ -92,28 +179,67 @@ func (obj *configInfo) Exec(raw []byte) error {
 		os.Exit(0)
 	}
 
-	resp := &updateAgentConfigMsg{
-		MessageHeader: req.MessageHeader,
-		Data:          []updateAgentConfigEntry{},
-	}
-	resp.MsgType = cmdResp
+	resp := swireguard.NewUpdateAgentConfigMsg(req.ID)
 
 	// CONFIG_INFO can be quite big message and could take a longer time to process
 	// Thus note that processing has started
 	logger.Info().Println(pkgName, "Configuring...")
+	tx := obj.mole.Begin()
+	addPeerCount, failures := obj.configure(obj.mole, obj.docker, &req, resp)
+
+	logger.Info().Println(pkgName, "Configured", addPeerCount, "peers")
+
+	// CONFIG_INFO message sends me full configuration
+	// Finally sync and merge everything between controller and OS
+	// (mostly for cleanup residual obsolete configuration)
+	commitErr := obj.mole.Commit(tx)
+
+	// Configuration may be rolled back. Report the actual state.
+	resp.Sync(obj.mole.Wireguard().Devices())
+	resp.Now()
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	logger.Message().Println(pkgName, "Sending: ", string(arr))
+	obj.writer.Write(arr)
+
+	if commitErr != nil {
+		return commitErr
+	}
+
+	// Keep successfully applied configuration for cold start
+	devices := obj.mole.Wireguard().Devices()
+	obj.snapshot.SetConfigInfo(raw, snapshot.InterfaceKeys(devices))
+	obj.snapshot.AddPresharedKeys(swireguard.PresharedKeys(devices))
+
+	// Configuration is applied, but some peers or interfaces may have failed
+	return failures.Err()
+}
+
+// configure creates interfaces and peers. Returns count of added peers and failed items
+// target is either mole or dry-run planner
+func (obj *configInfo) configure(target mole.Configurer, dh docker.DockerHelper,
+	req *configInfoMsg, resp *swireguard.UpdateAgentConfigMsg) (int, *common.PartialError) {
+	var err error
+	failures := &common.PartialError{}
+	// Preshared keys are never generated in dry-run (plan would show a random change)
+	// and when restoring (nobody would deliver a new key to the other side)
+	_, planning := target.(*mole.Planner)
+	generate := !planning && !obj.restore
 	// CONFIG_INFO message sends me full configuration
 	// Drop old cache and will build a new cache from zero
-	obj.mole.Flush()
+	target.Flush()
 
 	// create missing interfaces
-	obj.processInterface(req.Data.Network.Public, "PUBLIC", resp)
-	obj.processInterface(req.Data.Network.Sdn1, "SDN1", resp)
-	obj.processInterface(req.Data.Network.Sdn2, "SDN2", resp)
-	obj.processInterface(req.Data.Network.Sdn3, "SDN3", resp)
+	obj.processInterface(target, req.Data.Network.Public, "PUBLIC", resp, failures)
+	obj.processInterface(target, req.Data.Network.Sdn1, "SDN1", resp, failures)
+	obj.processInterface(target, req.Data.Network.Sdn2, "SDN2", resp, failures)
+	obj.processInterface(target, req.Data.Network.Sdn3, "SDN3", resp, failures)
 
 	for _, subnetwork := range req.Data.Subnetworks {
 		if subnetwork.Type == "DOCKER" {
-			err := obj.docker.NetworkCreate(subnetwork.Name, subnetwork.Subnet)
+			err := dh.NetworkCreate(subnetwork.Name, subnetwork.Subnet)
 			if err != nil {
 				logger.Info().Printf("%s Docker subnetwork %s already created\n", pkgName, subnetwork.Name)
 			}
@@ -121,55 +247,68 @@ func (obj *configInfo) Exec(raw []byte) error {
 	}
 
 	addPeerCount := 0
+	families := common.ReachableFamilies()
 	for _, cmd := range req.Data.VPN {
 		switch cmd.Function {
 		case "add_peer":
-			pi, err := cmd.asPeerInfo()
+			item := fmt.Sprintf("add_peer %s connection %d", cmd.Args.IfName, cmd.Metadata.ConnectionID)
+			pi, err := cmd.asPeerInfo(families)
 			if err != nil {
 				logger.Warning().Println(pkgName, err)
+				failures.Add(item, err)
 				continue
 			}
 			netpath, err := cmd.asNetworkPath()
 			if err != nil {
 				logger.Warning().Println(pkgName, err)
+				failures.Add(item, err)
 				continue
 			}
-			err = obj.mole.AddPeer(pi, netpath)
+			generated := false
+			if cmd.Args.GeneratePresharedKey {
+				generated, err = obj.mole.Wireguard().PresetPresharedKey(pi, obj.psks,
+					generate, cmd.Args.RotatePresharedKey)
+				if err != nil {
+					logger.Error().Println(pkgName, "generate preshared key", err)
+					failures.Add(item, err)
+					continue
+				}
+			}
+			err = target.AddPeer(pi, netpath)
 			if err == nil {
 				addPeerCount++
+				if generated {
+					resp.AddPresharedKey(pi)
+				}
+			} else {
+				logger.Error().Println(pkgName, cmd.Function, err)
 			}
+			failures.Add(item, err)
 
 		case "create_interface":
+			item := "create_interface " + cmd.Args.IfName
 			wgi, err := cmd.asInterfaceInfo()
 			if err != nil {
 				logger.Error().Println(pkgName, "parse interface info failed", err)
+				failures.Add(item, err)
 				continue
 			}
-			err = obj.mole.CreateInterface(wgi)
+			obj.presetKey(wgi)
+			err = target.CreateInterface(wgi)
 			if err == nil &&
 				cmd.Args.PublicKey != wgi.PublicKey ||
 				cmd.Args.ListenPort != wgi.Port {
 				resp.AddInterface(wgi)
 			}
+			if err != nil {
+				logger.Error().Println(pkgName, cmd.Function, err)
+			}
+			failures.Add(item, err)
 		}
 		if err != nil {
 			logger.Error().Println(pkgName, cmd.Function, err)
 		}
 	}
 
-	logger.Info().Println(pkgName, "Configured", addPeerCount, "peers")
-	resp.Now()
-	arr, err := json.Marshal(resp)
-	if err != nil {
-		return err
-	}
-	logger.Message().Println(pkgName, "Sending: ", string(arr))
-	obj.writer.Write(arr)
-
-	// CONFIG_INFO message sends me full configuration
-	// Finally sync and merge everything between controller and OS
-	// (mostly for cleanup residual obsolete configuration)
-	obj.mole.Apply()
-
-	return nil
+	return addPeerCount, failures
 }
@@ -37,7 +37,8 @@ func (e *configInfoNetworkEntry) asInterfaceInfo(ifaceName string) (*swireguard.
 	}, nil
 }
 
-func (e *configInfoVpnEntry) asPeerInfo() (*swireguard.PeerInfo, error) {
+// families are host reachable IP families, used to select one of dual-stack addresses
+func (e *configInfoVpnEntry) asPeerInfo(families common.AddrFamilies) (*swireguard.PeerInfo, error) {
 	var ifname string
 	if strings.HasPrefix(e.Args.IfName, env.InterfaceNamePrefix) {
 		ifname = e.Args.IfName
@@ -52,12 +53,13 @@ func (e *configInfoVpnEntry) asPeerInfo() (*swireguard.PeerInfo, error) {
 		GroupID:      e.Metadata.GroupID,
 		AgentID:      e.Metadata.AgentID,
 		Port:         e.Args.EndpointPort,
+		PresharedKey: e.Args.PresharedKey,
 	}
 
 	// These values may be absent on peer delete messages. Ignore errors.
 	// Don't worry about values - they will be taken from cache
-	pi.IP, _ = netip.ParseAddr(e.Args.EndpointIPv4)
-	pi.Gateway, _ = netip.ParseAddr(e.Args.GatewayIPv4)
+	pi.IP = families.ParseDualStackAddr(e.Args.EndpointIPv4, e.Args.EndpointIPv6)
+	pi.Gateway = families.ParseDualStackAddr(e.Args.GatewayIPv4, e.Args.GatewayIPv6)
 
 	for _, ipStr := range e.Args.AllowedIPs {
 		aip, err := netip.ParsePrefix(ipStr)
@@ -132,8 +134,16 @@ type configInfoVpnEntry struct {
 		// add_peer
 		AllowedIPs   []string `json:"allowed_ips,omitempty"`
 		EndpointIPv4 string   `json:"endpoint_ipv4,omitempty"`
+		EndpointIPv6 string   `json:"endpoint_ipv6,omitempty"`
 		EndpointPort int      `json:"endpoint_port,omitempty"`
 		GatewayIPv4  string   `json:"gw_ipv4,omitempty"`
+		GatewayIPv6  string   `json:"gw_ipv6,omitempty"`
+		// Optional preshared key. If missing and generation is requested,
+		// agent reuses peer's key or generates a new one and reports it back to controller.
+		PresharedKey         string `json:"preshared_key,omitempty"`
+		GeneratePresharedKey bool   `json:"preshared_key_generate,omitempty"`
+		// Generate a new key, even if peer already has one
+		RotatePresharedKey bool `json:"preshared_key_rotate,omitempty"`
 	} `json:"args,omitempty"`
 
 	Metadata struct {
@@ -151,7 +161,9 @@ type configInfoVpnEntry struct {
 
 type configInfoMsg struct {
 	common.MessageHeader
-	Data struct {
+	// Only plan configuration changes, do not apply them
+	DryRun bool `json:"dry_run,omitempty"`
+	Data   struct {
 		AgentID int `json:"agent_id"`
 		Network struct {
 			Public *configInfoNetworkEntry `json:"PUBLIC,omitempty"`
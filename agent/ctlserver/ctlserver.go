@@ -0,0 +1,123 @@
+// ctlserver package serves local control socket
+// It is used by `syntropyctl` to inspect a running agent,
+// even when SaaS controller is unreachable.
+package ctlserver
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/mole"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+const (
+	pkgName = "ControlSocket. "
+	cmd     = "CONTROL_SOCKET"
+	// Some requests (e.g. bandwidth probes) take long to complete
+	// Handlers must reject requests, that cannot complete in time
+	WriteTimeout = 5 * time.Minute
+)
+
+type ControlServer struct {
+	socket   string
+	mole     *mole.Mole
+	services func() []string
+	mux      *http.ServeMux
+}
+
+func New(socket string, m *mole.Mole, services func() []string) *ControlServer {
+	obj := ControlServer{
+		socket:   socket,
+		mole:     m,
+		services: services,
+		mux:      http.NewServeMux(),
+	}
+
+	obj.mux.HandleFunc(ctlapi.PathInterfaces, obj.getOnly(obj.interfaces))
+	obj.mux.HandleFunc(ctlapi.PathPeers, obj.getOnly(obj.peers))
+	obj.mux.HandleFunc(ctlapi.PathRoutes, obj.getOnly(obj.routes))
+	obj.mux.HandleFunc(ctlapi.PathServices, obj.getOnly(obj.serviceList))
+
+	return &obj
+}
+
+func (obj *ControlServer) Name() string {
+	return cmd
+}
+
+func (obj *ControlServer) Run(ctx context.Context) error {
+	// Remove stale socket, left from previous (crashed) run.
+	// Lock file guarantees there is no other agent instance running
+	os.Remove(obj.socket)
+
+	l, err := net.Listen("unix", obj.socket)
+	if err != nil {
+		return err
+	}
+	// Control socket exposes internal agent state. Allow access only for root
+	err = os.Chmod(obj.socket, 0600)
+	if err != nil {
+		l.Close()
+		return err
+	}
+
+	srv := http.Server{
+		Handler:      obj.mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: WriteTimeout,
+	}
+
+	go func() {
+		err := srv.Serve(l)
+		if err != http.ErrServerClosed {
+			logger.Error().Println(pkgName, err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		logger.Debug().Println(pkgName, "stopping", cmd)
+		srv.Close()
+		os.Remove(obj.socket)
+	}()
+
+	return nil
+}
+
+// Handle registers additional handler on control socket
+// Note: must be called before Run()
+func (obj *ControlServer) Handle(path string, handler http.HandlerFunc) {
+	obj.mux.HandleFunc(path, handler)
+}
+
+func (obj *ControlServer) getOnly(handler func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		WriteJSON(w, handler())
+	}
+}
+
+// WriteJSON is a helper to send successful response
+func WriteJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.Error().Println(pkgName, "response encode", err)
+	}
+}
+
+// WriteError is a helper to send error response
+func WriteError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ctlapi.ErrorResponse{Error: msg})
+}
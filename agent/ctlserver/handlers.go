@@ -0,0 +1,75 @@
+package ctlserver
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+)
+
+func (obj *ControlServer) interfaces() interface{} {
+	rv := []ctlapi.Interface{}
+
+	for _, dev := range obj.mole.Wireguard().Devices() {
+		iface := ctlapi.Interface{
+			IfName:     dev.IfName,
+			PublicKey:  dev.PublicKey,
+			Port:       dev.Port,
+			PeersCount: len(dev.Peers()),
+		}
+		if dev.IP.IsValid() {
+			iface.IP = dev.IP.String()
+		}
+		rv = append(rv, iface)
+	}
+
+	return rv
+}
+
+func (obj *ControlServer) peers() interface{} {
+	rv := []ctlapi.Peer{}
+
+	for _, dev := range obj.mole.Wireguard().Devices() {
+		for _, p := range dev.Peers() {
+			peer := ctlapi.Peer{
+				IfName:       p.IfName,
+				PublicKey:    p.PublicKey,
+				ConnectionID: p.ConnectionID,
+				GroupID:      p.GroupID,
+				AgentID:      p.AgentID,
+				AllowedIPs:   []string{},
+				RxBytes:      p.Stats.RxBytesTotal,
+				TxBytes:      p.Stats.TxBytesTotal,
+			}
+			if p.IP.IsValid() {
+				peer.Endpoint = netip.AddrPortFrom(p.IP, uint16(p.Port)).String()
+			}
+			if p.Gateway.IsValid() {
+				peer.Gateway = p.Gateway.String()
+			}
+			for _, ip := range p.AllowedIPs {
+				peer.AllowedIPs = append(peer.AllowedIPs, ip.String())
+			}
+			if !p.Stats.LastHandshake.IsZero() {
+				peer.LastHandshake = p.Stats.LastHandshake.Format(time.RFC3339)
+			}
+			rv = append(rv, peer)
+		}
+	}
+
+	return rv
+}
+
+func (obj *ControlServer) routes() interface{} {
+	return obj.mole.Router().Info()
+}
+
+func (obj *ControlServer) serviceList() interface{} {
+	rv := []ctlapi.Service{}
+
+	for _, name := range obj.services() {
+		rv = append(rv, ctlapi.Service{Name: name})
+	}
+
+	return rv
+}
@@ -131,3 +131,19 @@ func (obj *dockerWatcher) Run(ctx context.Context) error {
 
 	return nil
 }
+
+// Check verifies that docker API is reachable. Returns docker API version.
+// Is used by diagnostics.
+func Check(ctx context.Context) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return "", err
+	}
+	defer cli.Close()
+
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ping.APIVersion, nil
+}
@@ -0,0 +1,375 @@
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/docker"
+	"github.com/SyntropyNet/syntropy-agent/agent/kubernetes"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
+	"github.com/SyntropyNet/syntropy-agent/pkg/iptables"
+	"github.com/SyntropyNet/syntropy-agent/pkg/proxy"
+	"github.com/beevik/ntp"
+	"github.com/google/nftables"
+	"golang.org/x/sys/unix"
+	"pault.ag/go/modprobe"
+)
+
+// Linux capabilities bits (linux/capability.h)
+const (
+	capNetAdmin = 12
+	capNetRaw   = 13
+)
+
+// Agent creates public and up to 3 SDN interfaces, each needs a listen port
+const requiredPorts = 4
+
+// parseCapEff parses effective capabilities from /proc/<pid>/status content
+func parseCapEff(status string) (uint64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(status))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "CapEff:" {
+			return strconv.ParseUint(fields[1], 16, 64)
+		}
+	}
+	return 0, errors.New("CapEff not found")
+}
+
+func checkPrivileges(ctx context.Context) (string, string) {
+	if os.Geteuid() != 0 {
+		return StatusFail, "agent must run as root"
+	}
+
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return StatusWarn, "running as root, could not read capabilities: " + err.Error()
+	}
+	caps, err := parseCapEff(string(status))
+	if err != nil {
+		return StatusWarn, "running as root, could not parse capabilities: " + err.Error()
+	}
+
+	if caps&(1<<capNetAdmin) == 0 {
+		return StatusFail, "CAP_NET_ADMIN is missing (use `--cap-add=NET_ADMIN` for containers)"
+	}
+	if caps&(1<<capNetRaw) == 0 {
+		return StatusWarn, "CAP_NET_RAW is missing, peers latency may not be measured"
+	}
+	return StatusPass, "running as root with CAP_NET_ADMIN and CAP_NET_RAW"
+}
+
+func kernelModuleLoaded(name string) bool {
+	data, err := os.ReadFile("/proc/modules")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// kernelModuleAvailable checks if module may be loaded.
+// modprobe package may panic on inconsistent modules directory (e.g. kernel upgraded, but not rebooted)
+func kernelModuleAvailable(name string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_, err := modprobe.ResolveName(name)
+	return err == nil
+}
+
+func checkWireguard(ctx context.Context) (string, string) {
+	if config.GetWireguardMode() == config.WireguardUserspace {
+		return checkTun("SYNTROPY_WIREGUARD=userspace")
+	}
+
+	switch {
+	case kernelModuleLoaded("wireguard"):
+		return StatusPass, "kernel module is loaded"
+	case fileExists("/sys/module/wireguard"):
+		return StatusPass, "kernel module is built-in"
+	case kernelModuleAvailable("wireguard"):
+		return StatusPass, "kernel module is available and will be loaded"
+	}
+
+	if config.GetWireguardMode() == config.WireguardKernel {
+		return StatusFail, "SYNTROPY_WIREGUARD=kernel, but kernel module is not available"
+	}
+	status, msg := checkTun("kernel module is not available")
+	if status == StatusPass {
+		// Works, but is slower than kernel
+		status = StatusWarn
+	}
+	return status, msg
+}
+
+// checkTun checks if embedded userspace wireguard can create interfaces
+func checkTun(reason string) (string, string) {
+	f, err := os.OpenFile("/dev/net/tun", os.O_RDWR, 0)
+	if err != nil {
+		return StatusFail, reason + ", and embedded userspace implementation cannot be used: " + err.Error() +
+			" (use `--device /dev/net/tun` for containers)"
+	}
+	f.Close()
+	return StatusPass, reason + ", embedded userspace implementation will be used"
+}
+
+func iptablesVariants(proto iptables.Protocol) []string {
+	prefix := "iptables"
+	if proto == iptables.ProtocolIPv6 {
+		prefix = "ip6tables"
+	}
+	variants := []struct {
+		name    string
+		variant iptables.Variant
+	}{
+		{prefix + "-legacy", iptables.Legacy},
+		{prefix + "-nft", iptables.Nftables},
+		{prefix, iptables.Default},
+	}
+
+	rv := []string{}
+	for _, v := range variants {
+		_, err := iptables.New(iptables.IPFamily(proto), iptables.IptVariant(v.variant), iptables.Timeout(2))
+		if err == nil {
+			rv = append(rv, v.name)
+		}
+	}
+	return rv
+}
+
+func nftablesUsable() bool {
+	conn, err := nftables.New()
+	if err != nil {
+		return false
+	}
+	_, err = conn.ListTables()
+	return err == nil
+}
+
+func checkPacketFilter(ctx context.Context) (string, string) {
+	ipt := iptablesVariants(iptables.ProtocolIPv4)
+	ipt6 := iptablesVariants(iptables.ProtocolIPv6)
+	nft := nftablesUsable()
+
+	usable := append(ipt, ipt6...)
+	if nft {
+		usable = append(usable, "nftables")
+	}
+	msg := "usable: " + strings.Join(usable, ", ")
+	if len(usable) == 0 {
+		msg = "no usable iptables or nftables"
+	}
+
+	switch config.GetPacketFilter() {
+	case config.PacketFilterIptables:
+		if len(ipt) == 0 {
+			return StatusFail, "SYNTROPY_PACKET_FILTER=iptables, but " + msg
+		}
+	case config.PacketFilterNftables:
+		if !nft {
+			return StatusFail, "SYNTROPY_PACKET_FILTER=nftables, but " + msg
+		}
+		return StatusPass, msg
+	default:
+		if len(ipt) == 0 && !nft {
+			return StatusFail, msg
+		}
+		if len(ipt) == 0 {
+			return StatusPass, msg
+		}
+	}
+
+	if len(ipt6) == 0 {
+		return StatusWarn, msg + " (ip6tables is missing, IPv6 rules will not be created)"
+	}
+	return StatusPass, msg
+}
+
+func readSysctl(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	return strings.TrimSpace(string(data)), err
+}
+
+func checkForwarding(ctx context.Context) (string, string) {
+	v4, err := readSysctl("/proc/sys/net/ipv4/ip_forward")
+	if err != nil {
+		return StatusWarn, err.Error()
+	}
+	v6, _ := readSysctl("/proc/sys/net/ipv6/conf/all/forwarding")
+
+	msg := fmt.Sprintf("net.ipv4.ip_forward=%s net.ipv6.conf.all.forwarding=%s", v4, v6)
+	if v4 != "1" {
+		return StatusWarn, msg + ". Services behind this agent will not be reachable"
+	}
+	return StatusPass, msg
+}
+
+func checkRpFilter(ctx context.Context) (string, string) {
+	all, err := readSysctl("/proc/sys/net/ipv4/conf/all/rp_filter")
+	if err != nil {
+		return StatusWarn, err.Error()
+	}
+	def, _ := readSysctl("/proc/sys/net/ipv4/conf/default/rp_filter")
+
+	msg := fmt.Sprintf("net.ipv4.conf.all.rp_filter=%s net.ipv4.conf.default.rp_filter=%s", all, def)
+	// Kernel uses maximum of `all` and interface value. New interfaces get `default` value.
+	if all == "1" || def == "1" {
+		return StatusWarn, msg + ". Strict mode may drop traffic of SDN paths, use 2 (loose) or 0"
+	}
+	return StatusPass, msg
+}
+
+func checkNetworkAPI(ctx context.Context) (string, string) {
+	switch config.GetContainerType() {
+	case config.ContainerTypeDocker:
+		version, err := docker.Check(ctx)
+		if err != nil {
+			return StatusFail, "docker API: " + err.Error()
+		}
+		return StatusPass, "docker API version " + version + " is reachable"
+	case config.ContainerTypeKubernetes:
+		count, err := kubernetes.Check(ctx)
+		if err != nil {
+			return StatusFail, "kubernetes API: " + err.Error()
+		}
+		return StatusPass, fmt.Sprintf("kubernetes API is reachable, %d services found", count)
+	case config.ContainerTypeHost:
+		return StatusPass, "host network API does not need external services"
+	case "":
+		return StatusPass, "SYNTROPY_NETWORK_API is not set"
+	default:
+		return StatusWarn, "unknown SYNTROPY_NETWORK_API " + config.GetContainerType()
+	}
+}
+
+func checkPortRange(ctx context.Context) (string, string) {
+	start, end := config.GetPortsRange()
+	if start == 0 || end == 0 {
+		return StatusPass, "SYNTROPY_PORT_RANGE is not set, any free port is used"
+	}
+
+	free := 0
+	for port := uint32(start); port <= uint32(end); port++ {
+		conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", port))
+		if err == nil {
+			conn.Close()
+			free++
+		}
+	}
+
+	msg := fmt.Sprintf("%d of %d UDP ports in range %d-%d are free", free, int(end)-int(start)+1, start, end)
+	switch {
+	case free == 0:
+		return StatusFail, msg
+	case free < requiredPorts:
+		return StatusWarn, msg + fmt.Sprintf(". Agent may need up to %d ports", requiredPorts)
+	default:
+		return StatusPass, msg
+	}
+}
+
+func checkLockFile(ctx context.Context) (string, string) {
+	f, err := os.Open(env.LockFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return StatusPass, "no other agent instance is running"
+	} else if err != nil {
+		return StatusWarn, err.Error()
+	}
+	defer f.Close()
+
+	err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err != nil {
+		return StatusWarn, "another agent instance is running (" + env.LockFile + " is locked)"
+	}
+	unix.Flock(int(f.Fd()), unix.LOCK_UN)
+	return StatusPass, "stale lock file " + env.LockFile + " is not locked and will be reused"
+}
+
+func checkClock(ctx context.Context) (string, string) {
+	const (
+		toleratedOffset = 10 * time.Second
+		maxOffset       = 5 * time.Minute
+	)
+
+	resp, err := ntp.QueryWithOptions("pool.ntp.org", ntp.QueryOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		return StatusWarn, "NTP query failed: " + err.Error()
+	}
+
+	offset := resp.ClockOffset.Round(time.Millisecond)
+	if offset < 0 {
+		offset = -offset
+	}
+	msg := fmt.Sprintf("clock offset %s", offset)
+	switch {
+	case offset > maxOffset:
+		return StatusFail, msg + ". TLS connections to controller may fail"
+	case offset > toleratedOffset:
+		return StatusWarn, msg
+	default:
+		return StatusPass, msg
+	}
+}
+
+func checkControllerDNS(ctx context.Context) (string, string) {
+	if config.GetControllerType() != config.ControllerSaas {
+		return StatusPass, "not used by " + config.GetControllerName(config.GetControllerType()) + " controller"
+	}
+
+	endpoints := config.GetControllerEndpoints()
+	if len(endpoints) == 0 {
+		return StatusFail, "SYNTROPY_CONTROLLER_URL has no valid endpoints"
+	}
+
+	resolved := []string{}
+	failed := []string{}
+	for _, ep := range endpoints {
+		host := ep.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		// Proxy resolves controller address. Agent needs to resolve proxy only.
+		lookup := host
+		if u, err := proxy.ForURL(&url.URL{Scheme: "https", Host: ep.Host}); err == nil && u != nil {
+			lookup = u.Hostname()
+		}
+
+		_, err := net.DefaultResolver.LookupHost(ctx, lookup)
+		if err != nil {
+			failed = append(failed, lookup)
+		} else {
+			resolved = append(resolved, lookup)
+		}
+	}
+
+	switch {
+	case len(failed) == 0:
+		return StatusPass, "resolved " + strings.Join(resolved, ", ")
+	case len(resolved) == 0:
+		return StatusFail, "could not resolve " + strings.Join(failed, ", ")
+	default:
+		return StatusWarn, "could not resolve " + strings.Join(failed, ", ")
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
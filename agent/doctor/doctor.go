@@ -0,0 +1,103 @@
+// doctor package runs agent preflight diagnostics.
+// It checks everything agent depends on (privileges, WireGuard, packet filter,
+// kernel settings, network API, ports, time, controller) and reports pass/warn/fail.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Check statuses
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Every check is limited in time, so unreachable services do not hang the report
+const checkTimeout = 10 * time.Second
+
+type Result struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type Summary struct {
+	Pass int `json:"pass"`
+	Warn int `json:"warn"`
+	Fail int `json:"fail"`
+}
+
+type Report struct {
+	Checks  []Result `json:"checks"`
+	Summary Summary  `json:"summary"`
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context) (status, message string)
+}
+
+var checks = []check{
+	{"privileges", checkPrivileges},
+	{"wireguard", checkWireguard},
+	{"packet_filter", checkPacketFilter},
+	{"ip_forwarding", checkForwarding},
+	{"rp_filter", checkRpFilter},
+	{"network_api", checkNetworkAPI},
+	{"port_range", checkPortRange},
+	{"lock_file", checkLockFile},
+	{"clock", checkClock},
+	{"controller_dns", checkControllerDNS},
+}
+
+// Run executes all checks
+func Run(ctx context.Context) *Report {
+	report := &Report{
+		Checks: []Result{},
+	}
+
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		status, msg := c.run(checkCtx)
+		cancel()
+		report.add(c.name, status, msg)
+	}
+
+	return report
+}
+
+func (r *Report) add(name, status, msg string) {
+	r.Checks = append(r.Checks, Result{Name: name, Status: status, Message: msg})
+	switch status {
+	case StatusPass:
+		r.Summary.Pass++
+	case StatusWarn:
+		r.Summary.Warn++
+	default:
+		r.Summary.Fail++
+	}
+}
+
+// Failed is true, if at least one check failed
+func (r *Report) Failed() bool {
+	return r.Summary.Fail > 0
+}
+
+func (r *Report) WriteText(w io.Writer) {
+	for _, c := range r.Checks {
+		fmt.Fprintf(w, "[%-4s] %-15s %s\n", c.Status, c.Name, c.Message)
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", r.Summary.Pass, r.Summary.Warn, r.Summary.Fail)
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,63 @@
+package doctor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseCapEff(t *testing.T) {
+	status := "Name:\tsyntropy_agent\nCapInh:\t0000000000000000\nCapPrm:\t000001ffffffffff\n" +
+		"CapEff:\t0000000000003000\nCapBnd:\t000001ffffffffff\n"
+
+	caps, err := parseCapEff(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps&(1<<capNetAdmin) == 0 || caps&(1<<capNetRaw) == 0 {
+		t.Errorf("Invalid capabilities %x", caps)
+	}
+
+	_, err = parseCapEff("Name:\tsyntropy_agent\n")
+	if err == nil {
+		t.Error("Missing CapEff parsed")
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := &Report{}
+	r.add("a", StatusPass, "ok")
+	r.add("b", StatusWarn, "hmm")
+	if r.Failed() {
+		t.Error("Report without failures failed")
+	}
+	r.add("c", StatusFail, "bad")
+	if !r.Failed() {
+		t.Error("Report with failures passed")
+	}
+	if r.Summary != (Summary{Pass: 1, Warn: 1, Fail: 1}) {
+		t.Errorf("Invalid summary %+v", r.Summary)
+	}
+
+	var text bytes.Buffer
+	r.WriteText(&text)
+	if !strings.Contains(text.String(), "[fail] c") ||
+		!strings.Contains(text.String(), "1 passed, 1 warnings, 1 failed") {
+		t.Errorf("Invalid text report:\n%s", text.String())
+	}
+
+	var js bytes.Buffer
+	err := r.WriteJSON(&js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed Report
+	err = json.Unmarshal(js.Bytes(), &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Checks) != 3 || parsed.Checks[1].Status != StatusWarn {
+		t.Errorf("Invalid json report %s", js.String())
+	}
+}
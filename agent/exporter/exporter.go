@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
@@ -17,10 +18,14 @@ const (
 )
 
 type PeersMetrics struct {
+	sync.Mutex
 	port uint16
 	reg  *prometheus.Registry
+	ctx  context.Context
+	srv  *http.Server
 }
 
+// New creates exporter. Port 0 means exporter is disabled, until port is set.
 func New(port uint16, collector prometheus.Collector) (*PeersMetrics, error) {
 	obj := PeersMetrics{
 		port: port,
@@ -36,17 +41,57 @@ func New(port uint16, collector prometheus.Collector) (*PeersMetrics, error) {
 }
 
 func (obj *PeersMetrics) Run(ctx context.Context) error {
+	obj.Lock()
+	defer obj.Unlock()
+
+	obj.ctx = ctx
+	obj.start()
+
+	go func() {
+		<-ctx.Done()
+		logger.Debug().Println(pkgName, "stopping", cmd)
+		obj.Lock()
+		obj.stop()
+		obj.Unlock()
+	}()
+
+	return nil
+}
+
+// SetPort restarts exporter on a new port. Port 0 stops exporter.
+func (obj *PeersMetrics) SetPort(port uint16) {
+	obj.Lock()
+	defer obj.Unlock()
+
+	if port == obj.port {
+		return
+	}
+	obj.stop()
+	obj.port = port
+	if obj.ctx != nil && obj.ctx.Err() == nil {
+		obj.start()
+	}
+	logger.Info().Println(pkgName, "exporter port changed to", port)
+}
+
+// start must be called with lock held
+func (obj *PeersMetrics) start() {
+	if obj.port == 0 {
+		return
+	}
+
 	handler := promhttp.HandlerFor(obj.reg, promhttp.HandlerOpts{})
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", handler)
 
 	logger.Debug().Println(pkgName, "exporter starting on port", obj.port)
-	srv := http.Server{
+	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", obj.port),
 		Handler:      mux,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 	}
+	obj.srv = srv
 
 	go func() {
 		err := srv.ListenAndServe()
@@ -54,14 +99,14 @@ func (obj *PeersMetrics) Run(ctx context.Context) error {
 			logger.Error().Println(pkgName, err)
 		}
 	}()
+}
 
-	go func() {
-		<-ctx.Done()
-		logger.Debug().Println(pkgName, "stopping", cmd)
-		srv.Close()
-	}()
-
-	return nil
+// stop must be called with lock held
+func (obj *PeersMetrics) stop() {
+	if obj.srv != nil {
+		obj.srv.Close()
+		obj.srv = nil
+	}
 }
 
 func (obj *PeersMetrics) Name() string {
@@ -52,6 +52,10 @@ func (obj *getInfo) Name() string {
 	return cmd
 }
 
+func (obj *getInfo) ReadOnly() bool {
+	return true
+}
+
 func (obj *getInfo) Exec(raw []byte) error {
 	var req getInfoRequest
 	err := json.Unmarshal(raw, &req)
@@ -45,6 +45,8 @@ func (obj *hostNetServices) Run(ctx context.Context) error {
 				return
 			case <-ticker.C:
 				obj.execute()
+				// Peer check period may be changed by configuration reload
+				ticker.Reset(config.PeerCheckTime())
 			}
 		}
 	}()
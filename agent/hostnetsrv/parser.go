@@ -2,9 +2,11 @@ package hostnetsrv
 
 import (
 	"bufio"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
 	"io"
+	"net"
+	"net/netip"
 	"os"
 	"path"
 	"path/filepath"
@@ -35,27 +37,23 @@ const (
 	stateListen = "0A"
 )
 
-func convertIpFromHex(hex string) string {
-	if len(hex) > 8 {
-		logger.Warning().Println(pkgName, "IPv6 is not yet supported")
-		// fallback to some invalid IPv6 address
-		// So it will result to error everywhere, when IPv6 is started testing
-		return "ffff::eeee::0001"
-	} else {
-		var num [4]int64
-		var err error
-		printOnce := false
-		for i := 0; i < 4; i++ {
-			num[i], err = strconv.ParseInt(hex[i*2:i*2+2], 16, 8)
-			if err != nil && !printOnce {
-				// Parse errors here should never happen.
-				logger.Error().Println(pkgName, err)
-				printOnce = true
-			}
-		}
-		// Hex is in network order. Reverse it.
-		return fmt.Sprintf("%d.%d.%d.%d", num[3], num[2], num[1], num[0])
+// /proc/net files store IP addresses as hex strings of 32 bit words in host (little endian) order.
+// IPv4 is a single word, IPv6 - 4 words.
+func convertIpFromHex(hexIP string) string {
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		// Parse errors here should never happen.
+		logger.Error().Println(pkgName, "invalid hex IP address", hexIP, err)
+		return ""
 	}
+
+	// Reverse each 32bit word to get network order
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	addr, _ := netip.AddrFromSlice(raw)
+	return addr.String()
 }
 
 // Parse line by line /proc/net/tcp|udp file
@@ -90,14 +88,18 @@ func (obj *hostNetServices) parseProcNetFile(name string, services *[]hostServic
 			return
 		}
 		arr := strings.Fields(line)
-		if arr[state] != stateListen {
+		if len(arr) <= inode || arr[state] != stateListen {
 			continue
 		}
 
 		ipPort := strings.Split(arr[localAddress], ":")
 
 		// IP is in hex. Convert to printable string IP format
-		entry.Subnets = append(entry.Subnets, convertIpFromHex(ipPort[0]))
+		ip := convertIpFromHex(ipPort[0])
+		if ip == "" {
+			continue
+		}
+		entry.Subnets = append(entry.Subnets, ip)
 
 		// Port is in hex. Convert to dec
 		port, err := strconv.ParseInt(ipPort[1], 16, 17)
@@ -163,14 +165,14 @@ func (obj *hostNetServices) appendEnvSetup(services *[]hostServiceEntry) {
 func (obj *hostNetServices) execute() {
 	services := []hostServiceEntry{}
 
-	// Do not parse locally running services. They can anyway be reached via created tunnels
-	// Leving commented code for some time, as this part may need to be reviewed once again
-	// obj.parseProcNetFile("/proc/net/tcp", &services)
-	// obj.parseProcNetFile("/proc/net/udp", &services)
-
-	// Not yet
-	//	obj.parseProcNetFile("/proc/net/tcp6", &services)
-	//	obj.parseProcNetFile("/proc/net/udp6", &services)
+	// By default do not parse locally running services. They can anyway be reached via created tunnels
+	// But it can be enabled with SYNTROPY_HOST_SERVICES_DISCOVERY=true
+	if config.HostServicesDiscovery() {
+		obj.parseProcNetFile("/proc/net/tcp", &services)
+		obj.parseProcNetFile("/proc/net/udp", &services)
+		obj.parseProcNetFile("/proc/net/tcp6", &services)
+		obj.parseProcNetFile("/proc/net/udp6", &services)
+	}
 
 	obj.appendEnvSetup(&services)
 
@@ -0,0 +1,58 @@
+package hostnetsrv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertIpFromHex(t *testing.T) {
+	tests := []struct {
+		hex    string
+		expect string
+	}{
+		{"0100007F", "127.0.0.1"},
+		{"00000000", "0.0.0.0"},
+		{"00000000000000000000000001000000", "::1"},
+		{"00000000000000000000000000000000", "::"},
+		{"B80D0120000000000000000001000000", "2001:db8::1"},
+		// IPv4-mapped ::ffff:10.0.0.1
+		{"0000000000000000FFFF00000100000A", "::ffff:10.0.0.1"},
+		{"invalid", ""},
+		{"0100", ""},
+	}
+
+	for _, tt := range tests {
+		if ip := convertIpFromHex(tt.hex); ip != tt.expect {
+			t.Errorf("%s: expected %s, got %s", tt.hex, tt.expect, ip)
+		}
+	}
+}
+
+func TestParseProcNetFile(t *testing.T) {
+	const tcp6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: B80D0120000000000000000001000000:1F90 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 100 0 0 10 0
+   2: 00000000000000000000000001000000:1F91 00000000000000000000000001000000:D431 01 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 20 4 30 10 -1
+`
+	name := filepath.Join(t.TempDir(), "tcp6")
+	if err := os.WriteFile(name, []byte(tcp6), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	obj := &hostNetServices{}
+	services := []hostServiceEntry{}
+	obj.parseProcNetFile(name, &services)
+
+	// Established connection (state 01) is not a service
+	if len(services) != 2 {
+		t.Fatalf("Expected 2 services, got %+v", services)
+	}
+	if services[0].Subnets[0] != "::" || len(services[0].Ports.TCP) != 1 || services[0].Ports.TCP[0] != 22 {
+		t.Errorf("Invalid service %+v", services[0])
+	}
+	if services[1].Subnets[0] != "2001:db8::1" || services[1].Ports.TCP[0] != 8080 ||
+		len(services[1].Ports.UDP) != 0 {
+		t.Errorf("Invalid service %+v", services[1])
+	}
+}
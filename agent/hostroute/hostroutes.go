@@ -12,21 +12,56 @@ import (
 
 const pkgName = "HostRoute. "
 
-type HostRouter struct {
-	sync.Mutex
+// Default gateway of a single address family
+type defaultGateway struct {
 	gw     netip.Addr // Default gateway
 	ifname string     // Interface where default gw is reachable
+}
+
+type HostRouter struct {
+	sync.Mutex
+	gw4    defaultGateway
+	gw6    defaultGateway
 	routes map[netip.Prefix]*routeEntry
 }
 
 // TODO: in future need to handle IP/gw changes and reapply configuration
 func (hr *HostRouter) getDefaultRoute() error {
-	var err error
-	hr.gw, hr.ifname, err = netcfg.DefaultRoute()
-	if err != nil {
-		logger.Error().Println(pkgName, "Could not find default route", err)
+	var err4, err6 error
+	hr.gw4.gw, hr.gw4.ifname, err4 = netcfg.DefaultRoute()
+	if err4 != nil {
+		hr.gw4 = defaultGateway{}
+	}
+	hr.gw6.gw, hr.gw6.ifname, err6 = netcfg.DefaultRoute6()
+	if err6 != nil {
+		hr.gw6 = defaultGateway{}
+	}
+
+	// Single stack hosts are OK. Only fail if there is no default route at all
+	if err4 != nil && err6 != nil {
+		logger.Error().Println(pkgName, "Could not find default route", err4, err6)
+		return err4
+	}
+	return nil
+}
+
+// gateway returns default gateway for the address family of ip
+func (hr *HostRouter) gateway(ip netip.Prefix) *defaultGateway {
+	if ip.Addr().Is4() {
+		return &hr.gw4
+	}
+	return &hr.gw6
+}
+
+// Reachable returns true if there is a default gateway for addr address family
+func (hr *HostRouter) Reachable(addr netip.Addr) bool {
+	hr.Lock()
+	defer hr.Unlock()
+
+	if addr.Is4() {
+		return hr.gw4.gw.IsValid()
 	}
-	return err
+	return hr.gw6.gw.IsValid()
 }
 
 func (hr *HostRouter) Init() error {
@@ -88,7 +123,7 @@ func (hr *HostRouter) Apply() error {
 	hr.Lock()
 	defer hr.Unlock()
 
-	if !hr.gw.IsValid() {
+	if !hr.gw4.gw.IsValid() && !hr.gw6.gw.IsValid() {
 		err := hr.getDefaultRoute()
 		if err != nil {
 			return err
@@ -101,6 +136,7 @@ func (hr *HostRouter) Apply() error {
 	del := 0
 	// Apply pending operations
 	for ip, entry := range hr.routes {
+		gw := hr.gateway(ip)
 		if entry.count == 0 {
 			// Nobody needs it and entry is ready for deletion
 			delIPs = append(delIPs, ip)
@@ -108,8 +144,8 @@ func (hr *HostRouter) Apply() error {
 			if !entry.pending {
 				del++
 				logger.Debug().Println(pkgName, "Peer host route del to",
-					ip, "via", hr.ifname)
-				err := netcfg.RouteDel(hr.ifname, &ip)
+					ip, "via", gw.ifname)
+				err := netcfg.RouteDel(gw.ifname, &ip)
 				if err != nil {
 					// Warning and try to continue.
 					logger.Warning().Println(pkgName, "peer host route delete", err)
@@ -119,10 +155,16 @@ func (hr *HostRouter) Apply() error {
 		} else {
 			// if pending==false - this entry was already applied
 			if entry.pending {
+				if !gw.gw.IsValid() {
+					// No default route for this address family (single stack host)
+					logger.Warning().Println(pkgName, "no default route for", ip)
+					errCount++
+					continue
+				}
 				add++
 				logger.Debug().Println(pkgName, "Peer host route add to", ip,
-					"via", hr.gw, hr.ifname)
-				err := netcfg.RouteAdd(hr.ifname, &hr.gw, &ip)
+					"via", gw.gw, gw.ifname)
+				err := netcfg.RouteAdd(gw.ifname, &gw.gw, &ip)
 				if err != nil {
 					// Add peer host route failed. It should be some route conflict.
 					// In normal case this should not happen.
@@ -150,6 +192,39 @@ func (hr *HostRouter) Apply() error {
 	return nil
 }
 
+// Routes returns wanted host routes and count of their users.
+// Is used to snapshot configuration before changing it.
+func (hr *HostRouter) Routes() map[netip.Prefix]uint32 {
+	hr.Lock()
+	defer hr.Unlock()
+
+	rv := make(map[netip.Prefix]uint32)
+	for ip, entry := range hr.routes {
+		if entry.count > 0 {
+			rv[ip] = entry.count
+		}
+	}
+	return rv
+}
+
+// Restore makes wanted host routes exactly the same as in snapshot (see Routes).
+// Is used to roll back failed configuration. Routes are added and deleted in Apply.
+func (hr *HostRouter) Restore(routes map[netip.Prefix]uint32) {
+	hr.Lock()
+	defer hr.Unlock()
+
+	for ip, entry := range hr.routes {
+		entry.count = routes[ip]
+	}
+	for ip, count := range routes {
+		if _, ok := hr.routes[ip]; !ok {
+			e := newEntry()
+			e.count = count
+			hr.routes[ip] = e
+		}
+	}
+}
+
 // Flush is used for smart merge on newly received ConfigInfo message
 // It resets counter. And entries will be removed in apply
 func (hr *HostRouter) Flush() {
@@ -175,8 +250,9 @@ func (hr *HostRouter) Close() error {
 			// delete all added (not pending) routes
 			if !entry.pending {
 				count++
-				logger.Debug().Println(pkgName, "Cleanup host route", ip, "via", hr.ifname)
-				err := netcfg.RouteDel(hr.ifname, &ip)
+				ifname := hr.gateway(ip).ifname
+				logger.Debug().Println(pkgName, "Cleanup host route", ip, "via", ifname)
+				err := netcfg.RouteDel(ifname, &ip)
 				if err != nil {
 					// Warning and try to continue.
 					logger.Warning().Println(pkgName, "peer host route cleanup", err)
@@ -0,0 +1,31 @@
+package hostroute
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	hr := &HostRouter{routes: make(map[netip.Prefix]*routeEntry)}
+	kept := netip.MustParsePrefix("1.1.1.1/32")
+	dropped := netip.MustParsePrefix("2.2.2.2/32")
+	added := netip.MustParsePrefix("3.3.3.3/32")
+
+	hr.Add(kept, kept, dropped)
+	hr.routes[kept].pending = false
+	snapshot := hr.Routes()
+
+	// Failed configuration
+	hr.Flush()
+	hr.Add(added, kept)
+
+	hr.Restore(snapshot)
+	if !reflect.DeepEqual(hr.Routes(), snapshot) {
+		t.Errorf("Restored routes %v, expected %v", hr.Routes(), snapshot)
+	}
+	// Applied route is not added again, restored and dropped ones are (re)applied
+	if hr.routes[kept].pending || !hr.routes[dropped].pending || hr.routes[added].count != 0 {
+		t.Errorf("Invalid restored entries %+v %+v %+v", hr.routes[kept], hr.routes[dropped], hr.routes[added])
+	}
+}
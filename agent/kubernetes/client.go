@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"context"
 	"fmt"
 	"net"
 
@@ -101,3 +102,17 @@ func (obj *kubernet) monitorServices() ([]kubernetesServiceEntry, error) {
 
 	return res, nil
 }
+
+// Check verifies that kubernetes API is reachable and services in configured namespaces
+// can be listed. Returns services count. Is used by diagnostics.
+func Check(ctx context.Context) (int, error) {
+	obj := &kubernet{ctx: ctx}
+	err := obj.initClient()
+	if err != nil {
+		return 0, err
+	}
+	defer obj.httpClient.CloseIdleConnections()
+
+	services, err := obj.monitorServices()
+	return len(services), err
+}
@@ -107,6 +107,8 @@ func (obj *kubernet) Run(ctx context.Context) error {
 				return
 			case <-ticker.C:
 				obj.execute()
+				// Peer check period may be changed by configuration reload
+				ticker.Reset(config.PeerCheckTime() * time.Duration(config.PeerCheckWindow()))
 			}
 		}
 	}()
@@ -66,12 +66,32 @@ func (m *Mole) Flush() {
 // Apply pending results (sync cache to reality)
 // Send some messages to controller (Writter), if needed
 func (m *Mole) Apply() {
+	routeStatusMessage, peersActiveDataMessage, _ := m.apply()
+
+	routeStatusMessage.Send(m.writer)
+	peersActiveDataMessage.Send(m.writer)
+}
+
+// apply syncs cache to OS and prepares messages for controller.
+// All steps are executed even if some of them fail. The first failure is returned.
+func (m *Mole) apply() (*routestatus.Message, *peeradata.Message, *routestatus.ApplyError) {
+	var applyErr *routestatus.ApplyError
+	failed := func(stage string, err error) {
+		logger.Error().Println(pkgName, stage, "apply", err)
+		if applyErr == nil {
+			applyErr = &routestatus.ApplyError{
+				Stage:   stage,
+				Message: err.Error(),
+			}
+		}
+	}
+
 	routeStatusMessage := routestatus.New()
 	peersActiveDataMessage := peeradata.NewMessage()
 
 	delRoutes, err := m.wg.Apply()
 	if err != nil {
-		logger.Error().Println(pkgName, "wireguard apply", err)
+		failed(stageWireguard, err)
 	}
 
 	// store initial new peers counters values
@@ -95,7 +115,12 @@ func (m *Mole) Apply() {
 
 	err = m.hostRoute.Apply()
 	if err != nil {
-		logger.Error().Println(pkgName, "host routes apply", err)
+		failed(stageHostRoutes, err)
+	}
+
+	err = m.filter.Apply()
+	if err != nil {
+		failed(stagePacketFilter, err)
 	}
 
 	routeRes, peersData := m.router.Apply()
@@ -103,6 +128,5 @@ func (m *Mole) Apply() {
 	routeStatusMessage.Add(routeRes...)
 	peersActiveDataMessage.Add(peersData...)
 
-	routeStatusMessage.Send(m.writer)
-	peersActiveDataMessage.Send(m.writer)
+	return routeStatusMessage, peersActiveDataMessage, applyErr
 }
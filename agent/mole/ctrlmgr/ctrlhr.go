@@ -4,16 +4,19 @@ package ctrlmgr
 import (
 	"net"
 	"net/netip"
+	"net/url"
 
 	"github.com/SyntropyNet/syntropy-agent/agent/hostroute"
 	"github.com/SyntropyNet/syntropy-agent/internal/config"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/proxy"
 )
 
 // In case a VPN client is used sometimes may happen that other peer is stopped
 // Or when deleting connection - VPN server first deletes connection
 // In such situation client is left in nonet situation and will not get any more messages from controller
 // To workarround such case - add direct host routes to cloud controller (only in VPN_CLIENT=true)
+// If controller is reached via proxy - host routes to proxy are added as well
 
 const pkgName = "ControlerHostRoutes. "
 
@@ -38,9 +41,33 @@ func (chr *ControllerHostRouteManager) Init() error {
 		return err
 	}
 
-	addrs, err := net.LookupIP(config.GetCloudURL())
+	// All configured controller endpoints must be reachable for failover.
+	// Proxy (if used) is reachable directly as well.
+	// Controller address may be not resolvable locally in such case.
+	// Unresolvable endpoints are skipped - agent must start even if some (or all) of them are down
+	proxies := make(map[string]bool)
+	for _, ep := range config.GetControllerEndpoints() {
+		host := ep.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		chr.addHost(host)
+
+		p, err := proxy.ForURL(&url.URL{Scheme: "https", Host: ep.Host})
+		if err == nil && p != nil && !proxies[p.Hostname()] {
+			proxies[p.Hostname()] = true
+			chr.addHost(p.Hostname())
+		}
+	}
+
+	return chr.hostRoute.Apply()
+}
+
+// addHost adds host routes to all host addresses
+func (chr *ControllerHostRouteManager) addHost(host string) error {
+	addrs, err := net.LookupIP(host)
 	if err != nil {
-		logger.Error().Println(pkgName, "Could not resolve cloud URL", err)
+		logger.Error().Println(pkgName, "Could not resolve", host, err)
 		return err
 	}
 
@@ -49,20 +76,20 @@ func (chr *ControllerHostRouteManager) Init() error {
 		if !ok {
 			continue
 		}
-		// Agent controlls only IPv4 addresses
-		// TODO: Ignore IPv6 for now.
-		if !ipAddr.Is4() {
+		ipAddr = ipAddr.Unmap()
+		// Skip address family, which is not reachable on this host (single stack host)
+		if !chr.hostRoute.Reachable(ipAddr) {
+			logger.Debug().Println(pkgName, "no default route for", host, "address", ipAddr)
 			continue
 		}
 		dest := netip.PrefixFrom(ipAddr, ipAddr.BitLen())
 
 		err = chr.hostRoute.Add(dest)
 		if err != nil {
-			logger.Warning().Println(pkgName, "controller host route add", addr, err)
+			logger.Warning().Println(pkgName, "host route add", host, addr, err)
 		}
 	}
-
-	return chr.hostRoute.Apply()
+	return nil
 }
 
 // Delete (if created) host route to controller
@@ -90,5 +90,7 @@ func (m *Mole) RemoveInterface(ii *swireguard.InterfaceInfo) error {
 }
 
 func (m *Mole) CreateChain() error {
-	return m.filter.CreateChain()
+	err := m.filter.CreateChain()
+	m.filterChain = err == nil
+	return err
 }
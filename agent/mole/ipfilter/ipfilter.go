@@ -1,161 +1,159 @@
-// Package ipfilter wraps iptables commands
-// and is used to setup Syntropy releated rules
+// Package ipfilter is used to setup Syntropy releated packet filter rules
+// It supports several backends: iptables (exec based) and nftables (native netlink)
 package ipfilter
 
 import (
-	"errors"
 	"fmt"
 	"net/netip"
 
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
-	"github.com/SyntropyNet/syntropy-agent/pkg/iptables"
-	"github.com/SyntropyNet/syntropy-agent/pkg/netcfg"
 )
 
+const pkgName = "PacketFilter. "
+
+// filterBackend is implemented by all packet filter backends
+type filterBackend interface {
+	Name() string
+	CreateChain() error
+	RulesAdd(ips ...netip.Prefix) error
+	RulesDel(ips ...netip.Prefix) error
+	ForwardEnable(ifname string) error
+	// Flush is called when new full configuration is received
+	Flush()
+	// Apply syncs pending changes to OS (if backend is caching them)
+	Apply() error
+	Close() error
+}
+
 type PacketFilter struct {
-	ipt          *iptables.IPTables
-	chainCreated bool
+	backend filterBackend
+	// peers allowed IPs, that have rules (since the last Flush)
+	rules map[netip.Prefix]bool
 }
 
-func New() (*PacketFilter, error) {
-	pf := new(PacketFilter)
-	var err error
+type backendConstructor func() (filterBackend, error)
 
-	pf.ipt, err = iptables.New(iptables.IPFamily(iptables.ProtocolIPv4), iptables.IptVariant(iptables.Legacy))
-	if err == nil {
-		return pf, nil
-	}
-
-	logger.Error().Println(pkgName, "iptables-legacy failed. Trying iptables-nft")
-	pf.ipt, err = iptables.New(iptables.IPFamily(iptables.ProtocolIPv4), iptables.IptVariant(iptables.Nftables))
-	if err == nil {
-		return pf, nil
+func New() (*PacketFilter, error) {
+	backend, err := selectBackend(config.GetPacketFilter(),
+		func() (filterBackend, error) { return newIptablesFilter() },
+		func() (filterBackend, error) { return newNftablesFilter() })
+	if err != nil {
+		return nil, fmt.Errorf("packet filter: %s", err)
 	}
 
-	logger.Error().Println(pkgName, "iptables-nft failed. Fallback to OS default iptables")
+	logger.Info().Println(pkgName, "Using", backend.Name(), "backend")
+	return newPacketFilter(backend), nil
+}
 
-	pf.ipt, err = iptables.New(iptables.IPFamily(iptables.ProtocolIPv4), iptables.IptVariant(iptables.Default))
-	if err == nil {
-		return pf, nil
+func newPacketFilter(backend filterBackend) *PacketFilter {
+	return &PacketFilter{
+		backend: backend,
+		rules:   make(map[netip.Prefix]bool),
 	}
+}
 
-	logger.Error().Println(pkgName, "Default iptables failed", err)
-	return nil, fmt.Errorf("iptables failed")
+// selectBackend creates backend, configured by SYNTROPY_PACKET_FILTER
+func selectBackend(mode int, iptables, nftables backendConstructor) (filterBackend, error) {
+	switch mode {
+	case config.PacketFilterIptables:
+		return iptables()
+	case config.PacketFilterNftables:
+		return nftables()
+	default:
+		// Prefer iptables for compatibility with previous versions
+		// and fallback to native nftables on hosts that do not ship iptables
+		backend, err := iptables()
+		if err != nil {
+			logger.Warning().Println(pkgName, "iptables is not available. Trying native nftables")
+			return nftables()
+		}
+		return backend, nil
+	}
 }
 
-const (
-	pkgName       = "IpTables. "
-	defaultTable  = "filter"
-	natTable      = "nat"
-	forwardChain  = "FORWARD"
-	syntropyChain = "SYNTROPY_CHAIN"
-)
+func (pf *PacketFilter) Name() string {
+	return pf.backend.Name()
+}
 
 func (pf *PacketFilter) CreateChain() error {
-	rule := []string{"-s", "0.0.0.0/0", "-d", "0.0.0.0/0", "-j", syntropyChain}
+	return pf.backend.CreateChain()
+}
 
-	exists, err := pf.ipt.ChainExists(defaultTable, syntropyChain)
-	if !exists && err == nil {
-		err = pf.ipt.NewChain(defaultTable, syntropyChain)
-	}
-	if err != nil {
-		return err
+func (pf *PacketFilter) RulesAdd(ips ...netip.Prefix) error {
+	for _, ip := range ips {
+		pf.rules[ip] = true
 	}
+	return pf.backend.RulesAdd(ips...)
+}
 
-	exists, err = pf.ipt.Exists(defaultTable, forwardChain, rule...)
-	if !exists && err == nil {
-		err = pf.ipt.Insert(defaultTable, forwardChain, 1, rule...)
-	}
-	if err != nil {
-		return err
+func (pf *PacketFilter) RulesDel(ips ...netip.Prefix) error {
+	for _, ip := range ips {
+		delete(pf.rules, ip)
 	}
-
-	pf.chainCreated = true
-	return nil
+	return pf.backend.RulesDel(ips...)
 }
 
-func (pf *PacketFilter) processPeerRule(add bool, ip netip.Prefix) (err error) {
-	// No need adding rules to non existing chain
-	if !pf.chainCreated {
-		return nil
+// Rules returns peers allowed IPs, that have rules.
+// Is used to snapshot configuration before changing it.
+func (pf *PacketFilter) Rules() []netip.Prefix {
+	rv := []netip.Prefix{}
+	for ip := range pf.rules {
+		rv = append(rv, ip)
 	}
+	return rv
+}
 
-	// Add source rule
-	const sourceDestIdx = 2
-	rule := []string{"-p", "all", "-s", ip.String(), "-j", "ACCEPT"}
-	if add {
-		err = pf.ipt.AppendUnique(defaultTable, syntropyChain, rule...)
-	} else {
-		err = pf.ipt.DeleteIfExists(defaultTable, syntropyChain, rule...)
-	}
-	if err != nil {
-		return err
+// Restore makes peers rules exactly the same as in snapshot (see Rules).
+// Is used to roll back failed configuration.
+// failed are rules of failed configuration: iptables rules are not deleted on Flush, thus they must be deleted explicitly.
+func (pf *PacketFilter) Restore(rules, failed []netip.Prefix) error {
+	wanted := make(map[netip.Prefix]bool)
+	for _, ip := range rules {
+		wanted[ip] = true
 	}
 
-	// and destination rule also
-	rule[sourceDestIdx] = "-d"
-	if add {
-		err = pf.ipt.AppendUnique(defaultTable, syntropyChain, rule...)
-	} else {
-		err = pf.ipt.DeleteIfExists(defaultTable, syntropyChain, rule...)
+	stale := make(map[netip.Prefix]bool)
+	for _, ip := range failed {
+		stale[ip] = true
 	}
-
-	return err
-}
-
-func (pf *PacketFilter) RulesAdd(ips ...netip.Prefix) error {
-	// No need adding rules to non existing chain
-	if !pf.chainCreated {
-		return nil
+	for ip := range pf.rules {
+		stale[ip] = true
 	}
-
-	for _, ip := range ips {
-		err := pf.processPeerRule(true, ip)
-		if err != nil {
-			return err
+	del := []netip.Prefix{}
+	for ip := range stale {
+		if !wanted[ip] {
+			del = append(del, ip)
 		}
 	}
-	return nil
-}
-
-func (pf *PacketFilter) RulesDel(ips ...netip.Prefix) error {
-	// No need adding rules to non existing chain
-	if !pf.chainCreated {
-		return nil
+	add := []netip.Prefix{}
+	for _, ip := range rules {
+		if !pf.rules[ip] {
+			add = append(add, ip)
+		}
 	}
 
-	for _, ip := range ips {
-		err := pf.processPeerRule(false, ip)
-		if err != nil {
-			return err
-		}
+	errDel := pf.RulesDel(del...)
+	errAdd := pf.RulesAdd(add...)
+	if errDel != nil {
+		return errDel
 	}
-	return nil
+	return errAdd
 }
 
 func (pf *PacketFilter) ForwardEnable(ifname string) error {
-	forwardRule := []string{"-i", ifname, "-j", "ACCEPT"}
-	err := pf.ipt.AppendUnique(defaultTable, "FORWARD", forwardRule...)
-	if err != nil {
-		return err
-	}
-
-	_, dri, _ := netcfg.DefaultRoute()
-	if dri == "" {
-		return errors.New("could not parse default route interface")
-	}
+	return pf.backend.ForwardEnable(ifname)
+}
 
-	masquaradeRule := []string{"-o", dri, "-j", "MASQUERADE"}
-	return pf.ipt.AppendUnique(natTable, "POSTROUTING", masquaradeRule...)
+func (pf *PacketFilter) Flush() {
+	pf.rules = make(map[netip.Prefix]bool)
+	pf.backend.Flush()
 }
 
-func (pf *PacketFilter) Close() error {
-	// TODO: cleanup configured iptables rules on exit
-	return nil
+func (pf *PacketFilter) Apply() error {
+	return pf.backend.Apply()
 }
 
-func (pf *PacketFilter) Flush() {
-	// TODO: Flush is called when new configuration is received.
-	// Think about marking roles for deletion
-	// NB: need to introduce some kind of cache
+func (pf *PacketFilter) Close() error {
+	return pf.backend.Close()
 }
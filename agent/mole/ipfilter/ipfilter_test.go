@@ -0,0 +1,180 @@
+package ipfilter
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+type fakeBackend struct {
+	nftablesFilter
+	name string
+}
+
+func (fb *fakeBackend) Name() string {
+	return fb.name
+}
+
+func TestSelectBackend(t *testing.T) {
+	available := func(name string) backendConstructor {
+		return func() (filterBackend, error) { return &fakeBackend{name: name}, nil }
+	}
+	missing := func() (filterBackend, error) { return nil, errors.New("missing") }
+
+	tests := []struct {
+		mode     int
+		ipt, nft backendConstructor
+		expect   string
+	}{
+		{config.PacketFilterAuto, available("iptables"), available("nftables"), "iptables"},
+		{config.PacketFilterAuto, missing, available("nftables"), "nftables"},
+		{config.PacketFilterAuto, missing, missing, ""},
+		{config.PacketFilterIptables, available("iptables"), available("nftables"), "iptables"},
+		{config.PacketFilterIptables, missing, available("nftables"), ""},
+		{config.PacketFilterNftables, available("iptables"), available("nftables"), "nftables"},
+		{config.PacketFilterNftables, available("iptables"), missing, ""},
+	}
+
+	for i, tt := range tests {
+		backend, err := selectBackend(tt.mode, tt.ipt, tt.nft)
+		switch {
+		case tt.expect == "" && err == nil:
+			t.Errorf("%d: expected error, got %s", i, backend.Name())
+		case tt.expect != "" && err != nil:
+			t.Errorf("%d: expected %s, got error %s", i, tt.expect, err)
+		case tt.expect != "" && backend.Name() != tt.expect:
+			t.Errorf("%d: expected %s, got %s", i, tt.expect, backend.Name())
+		}
+	}
+}
+
+func TestNftablesPeerRules(t *testing.T) {
+	nf := &nftablesFilter{
+		peers:   make(map[netip.Prefix]bool),
+		forward: make(map[string]bool),
+	}
+	table := nf.table()
+	chain := &nftables.Chain{Name: nftForwardChain, Table: table}
+
+	v4 := netip.MustParsePrefix("10.0.0.1/24")
+	v6 := netip.MustParsePrefix("fd00::1/128")
+
+	// Rules are not added until chain is created
+	nf.RulesAdd(v4)
+	if len(nf.peerRules(table, chain)) != 0 {
+		t.Fatal("Rules added without chain")
+	}
+
+	nf.CreateChain()
+	// Same as iptables AppendUnique: repeated add is a single rule, removed with a single delete
+	nf.RulesAdd(v4, v6)
+	nf.RulesAdd(v4)
+	rules := nf.peerRules(table, chain)
+	if len(rules) != 4 {
+		t.Fatalf("Expected 4 rules (source and destination for 2 prefixes), got %d", len(rules))
+	}
+
+	// Sorted: 10.0.0.0/24 comes before fd00::1/128
+	checkPayload(t, rules[0], 12, 4, "10.0.0.0", true)
+	checkPayload(t, rules[1], 16, 4, "10.0.0.0", true)
+	checkPayload(t, rules[2], 8, 16, "fd00::1", false)
+	checkPayload(t, rules[3], 24, 16, "fd00::1", false)
+
+	nf.RulesDel(v4)
+	rules = nf.peerRules(table, chain)
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules after delete, got %d", len(rules))
+	}
+
+	nf.Flush()
+	if len(nf.peerRules(table, chain)) != 0 {
+		t.Error("Rules left after flush")
+	}
+}
+
+func checkPayload(t *testing.T, rule *nftables.Rule, offset, length uint32, addr string, masked bool) {
+	t.Helper()
+
+	var payload *expr.Payload
+	var cmp *expr.Cmp
+	var bitwise *expr.Bitwise
+	for _, e := range rule.Exprs {
+		switch v := e.(type) {
+		case *expr.Payload:
+			payload = v
+		case *expr.Cmp:
+			// last compare is the address
+			cmp = v
+		case *expr.Bitwise:
+			bitwise = v
+		}
+	}
+	if _, ok := rule.Exprs[len(rule.Exprs)-1].(*expr.Verdict); !ok {
+		t.Error("Rule does not end with verdict")
+	}
+	if payload == nil || payload.Offset != offset || payload.Len != length {
+		t.Errorf("Invalid payload %+v, expected offset %d len %d", payload, offset, length)
+	}
+	if cmp == nil || netip.MustParseAddr(addr) != mustAddr(cmp.Data) {
+		t.Errorf("Invalid address compare %+v, expected %s", cmp, addr)
+	}
+	if (bitwise != nil) != masked {
+		t.Errorf("Invalid mask %+v", bitwise)
+	}
+}
+
+func mustAddr(b []byte) netip.Addr {
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// iptablesBackend keeps rules on Flush, same as iptables
+type iptablesBackend struct {
+	fakeBackend
+	rules map[netip.Prefix]bool
+}
+
+func (ib *iptablesBackend) RulesAdd(ips ...netip.Prefix) error {
+	for _, ip := range ips {
+		ib.rules[ip] = true
+	}
+	return nil
+}
+
+func (ib *iptablesBackend) RulesDel(ips ...netip.Prefix) error {
+	for _, ip := range ips {
+		delete(ib.rules, ip)
+	}
+	return nil
+}
+
+func (ib *iptablesBackend) Flush() {}
+
+func TestPacketFilterRestore(t *testing.T) {
+	backend := &iptablesBackend{rules: make(map[netip.Prefix]bool)}
+	pf := newPacketFilter(backend)
+	kept := netip.MustParsePrefix("10.0.0.1/32")
+	dropped := netip.MustParsePrefix("10.0.0.2/32")
+	added := netip.MustParsePrefix("10.0.0.3/32")
+
+	pf.RulesAdd(kept, dropped)
+	snapshot := pf.Rules()
+
+	// Failed configuration
+	pf.RulesDel(dropped)
+	pf.RulesAdd(added)
+	failed := pf.Rules()
+	pf.Flush()
+	pf.RulesAdd(kept)
+
+	err := pf.Restore(snapshot, failed)
+	expect := map[netip.Prefix]bool{kept: true, dropped: true}
+	if err != nil || !reflect.DeepEqual(pf.rules, expect) || !reflect.DeepEqual(backend.rules, expect) {
+		t.Errorf("Restored rules %v, OS rules %v, error %v", pf.rules, backend.rules, err)
+	}
+}
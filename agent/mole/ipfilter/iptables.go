@@ -0,0 +1,232 @@
+package ipfilter
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/iptables"
+	"github.com/SyntropyNet/syntropy-agent/pkg/netcfg"
+)
+
+// iptablesFilter is iptables (exec based) packet filter backend
+type iptablesFilter struct {
+	ipt          *iptables.IPTables
+	ipt6         *iptables.IPTables // may be nil, if ip6tables is not available
+	chainCreated bool
+}
+
+func newIptables(proto iptables.Protocol) (*iptables.IPTables, error) {
+	ipt, err := iptables.New(iptables.IPFamily(proto), iptables.IptVariant(iptables.Legacy))
+	if err == nil {
+		return ipt, nil
+	}
+
+	logger.Error().Println(pkgName, "iptables-legacy failed. Trying iptables-nft")
+	ipt, err = iptables.New(iptables.IPFamily(proto), iptables.IptVariant(iptables.Nftables))
+	if err == nil {
+		return ipt, nil
+	}
+
+	logger.Error().Println(pkgName, "iptables-nft failed. Fallback to OS default iptables")
+
+	ipt, err = iptables.New(iptables.IPFamily(proto), iptables.IptVariant(iptables.Default))
+	if err == nil {
+		return ipt, nil
+	}
+
+	logger.Error().Println(pkgName, "Default iptables failed", err)
+	return nil, fmt.Errorf("iptables failed")
+}
+
+func newIptablesFilter() (*iptablesFilter, error) {
+	pf := new(iptablesFilter)
+	var err error
+
+	pf.ipt, err = newIptables(iptables.ProtocolIPv4)
+	if err != nil {
+		return nil, err
+	}
+
+	// IPv6 is optional. Agent still works on hosts without ip6tables
+	pf.ipt6, err = newIptables(iptables.ProtocolIPv6)
+	if err != nil {
+		logger.Warning().Println(pkgName, "ip6tables is not available. IPv6 rules will not be created")
+	}
+
+	return pf, nil
+}
+
+// Returns iptables instance for the IP address family (or nil, if not available)
+func (pf *iptablesFilter) family(ip netip.Prefix) *iptables.IPTables {
+	if ip.Addr().Is4() {
+		return pf.ipt
+	}
+	return pf.ipt6
+}
+
+// Returns all available iptables instances with their `any address` subnets
+func (pf *iptablesFilter) families() map[*iptables.IPTables]netip.Prefix {
+	rv := map[*iptables.IPTables]netip.Prefix{
+		pf.ipt: netip.PrefixFrom(netip.IPv4Unspecified(), 0),
+	}
+	if pf.ipt6 != nil {
+		rv[pf.ipt6] = netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+	}
+	return rv
+}
+
+const (
+	defaultTable  = "filter"
+	natTable      = "nat"
+	forwardChain  = "FORWARD"
+	syntropyChain = "SYNTROPY_CHAIN"
+)
+
+func (pf *iptablesFilter) CreateChain() error {
+	for ipt, anyAddr := range pf.families() {
+		rule := []string{"-s", anyAddr.String(), "-d", anyAddr.String(), "-j", syntropyChain}
+
+		exists, err := ipt.ChainExists(defaultTable, syntropyChain)
+		if !exists && err == nil {
+			err = ipt.NewChain(defaultTable, syntropyChain)
+		}
+		if err != nil {
+			return err
+		}
+
+		exists, err = ipt.Exists(defaultTable, forwardChain, rule...)
+		if !exists && err == nil {
+			err = ipt.Insert(defaultTable, forwardChain, 1, rule...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	pf.chainCreated = true
+	return nil
+}
+
+func (pf *iptablesFilter) processPeerRule(add bool, ip netip.Prefix) (err error) {
+	// No need adding rules to non existing chain
+	if !pf.chainCreated {
+		return nil
+	}
+
+	ipt := pf.family(ip)
+	if ipt == nil {
+		// ip6tables is not available. Warning was already printed on start.
+		return nil
+	}
+
+	// Add source rule
+	const sourceDestIdx = 2
+	rule := []string{"-p", "all", "-s", ip.String(), "-j", "ACCEPT"}
+	if add {
+		err = ipt.AppendUnique(defaultTable, syntropyChain, rule...)
+	} else {
+		err = ipt.DeleteIfExists(defaultTable, syntropyChain, rule...)
+	}
+	if err != nil {
+		return err
+	}
+
+	// and destination rule also
+	rule[sourceDestIdx] = "-d"
+	if add {
+		err = ipt.AppendUnique(defaultTable, syntropyChain, rule...)
+	} else {
+		err = ipt.DeleteIfExists(defaultTable, syntropyChain, rule...)
+	}
+
+	return err
+}
+
+func (pf *iptablesFilter) RulesAdd(ips ...netip.Prefix) error {
+	// No need adding rules to non existing chain
+	if !pf.chainCreated {
+		return nil
+	}
+
+	for _, ip := range ips {
+		err := pf.processPeerRule(true, ip)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pf *iptablesFilter) RulesDel(ips ...netip.Prefix) error {
+	// No need adding rules to non existing chain
+	if !pf.chainCreated {
+		return nil
+	}
+
+	for _, ip := range ips {
+		err := pf.processPeerRule(false, ip)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pf *iptablesFilter) ForwardEnable(ifname string) error {
+	forwardRule := []string{"-i", ifname, "-j", "ACCEPT"}
+	err := pf.ipt.AppendUnique(defaultTable, "FORWARD", forwardRule...)
+	if err != nil {
+		return err
+	}
+
+	_, dri, _ := netcfg.DefaultRoute()
+	_, dri6, _ := netcfg.DefaultRoute6()
+	if dri == "" && dri6 == "" {
+		return errors.New("could not parse default route interface")
+	}
+
+	if dri != "" {
+		masquaradeRule := []string{"-o", dri, "-j", "MASQUERADE"}
+		err = pf.ipt.AppendUnique(natTable, "POSTROUTING", masquaradeRule...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pf.ipt6 == nil {
+		return nil
+	}
+
+	err = pf.ipt6.AppendUnique(defaultTable, "FORWARD", forwardRule...)
+	if err != nil {
+		return err
+	}
+	if dri6 != "" {
+		masquaradeRule := []string{"-o", dri6, "-j", "MASQUERADE"}
+		err = pf.ipt6.AppendUnique(natTable, "POSTROUTING", masquaradeRule...)
+	}
+
+	return err
+}
+
+func (pf *iptablesFilter) Name() string {
+	return "iptables"
+}
+
+func (pf *iptablesFilter) Close() error {
+	// TODO: cleanup configured iptables rules on exit
+	return nil
+}
+
+func (pf *iptablesFilter) Flush() {
+	// TODO: Flush is called when new configuration is received.
+	// Think about marking roles for deletion
+	// NB: need to introduce some kind of cache
+}
+
+func (pf *iptablesFilter) Apply() error {
+	// iptables rules are applied immediately, one by one
+	return nil
+}
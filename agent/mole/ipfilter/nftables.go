@@ -0,0 +1,317 @@
+package ipfilter
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/netcfg"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	nftTableName       = "syntropy"
+	nftForwardChain    = "forward"
+	nftPostroutingChan = "postrouting"
+)
+
+// nftablesFilter is native (netlink) nftables packet filter backend.
+// It owns its own `syntropy` table and does not touch other tables.
+// Rules are cached and whole table is rebuilt atomically on Apply()
+// NOTE: nftables accept verdict in this table does not override drop verdicts
+// in other tables (e.g. firewalld). Those must allow Syntropy traffic by themselves.
+type nftablesFilter struct {
+	sync.Mutex
+	chainCreated bool
+	// Peers allowed IPs. Same as iptables backend (AppendUnique)
+	// a repeated add creates a single rule and a single delete removes it.
+	peers   map[netip.Prefix]bool
+	forward map[string]bool // interfaces with enabled forwarding
+	applied bool            // table was created at least once
+}
+
+func newNftablesFilter() (*nftablesFilter, error) {
+	// Check if nftables is supported by kernel
+	conn, err := nftables.New()
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.ListTables()
+	if err != nil {
+		return nil, fmt.Errorf("nftables: %s", err)
+	}
+
+	return &nftablesFilter{
+		peers:   make(map[netip.Prefix]bool),
+		forward: make(map[string]bool),
+	}, nil
+}
+
+func (nf *nftablesFilter) Name() string {
+	return "nftables"
+}
+
+func (nf *nftablesFilter) CreateChain() error {
+	nf.Lock()
+	defer nf.Unlock()
+
+	nf.chainCreated = true
+	return nil
+}
+
+func (nf *nftablesFilter) RulesAdd(ips ...netip.Prefix) error {
+	nf.Lock()
+	defer nf.Unlock()
+
+	// No need adding rules to non existing chain
+	if !nf.chainCreated {
+		return nil
+	}
+
+	for _, ip := range ips {
+		nf.peers[ip.Masked()] = true
+	}
+	return nil
+}
+
+func (nf *nftablesFilter) RulesDel(ips ...netip.Prefix) error {
+	nf.Lock()
+	defer nf.Unlock()
+
+	// No need deleting rules from non existing chain
+	if !nf.chainCreated {
+		return nil
+	}
+
+	for _, ip := range ips {
+		delete(nf.peers, ip.Masked())
+	}
+	return nil
+}
+
+func (nf *nftablesFilter) ForwardEnable(ifname string) error {
+	nf.Lock()
+	defer nf.Unlock()
+
+	nf.forward[ifname] = true
+	return nil
+}
+
+func (nf *nftablesFilter) Flush() {
+	nf.Lock()
+	defer nf.Unlock()
+
+	// New full configuration is received.
+	// Cache will be filled once again and table will be rebuilt on Apply
+	nf.peers = make(map[netip.Prefix]bool)
+	nf.forward = make(map[string]bool)
+}
+
+func (nf *nftablesFilter) table() *nftables.Table {
+	return &nftables.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   nftTableName,
+	}
+}
+
+// Apply rebuilds whole syntropy table in a single netlink batch (transaction)
+// So either all rules are applied, or none of them.
+func (nf *nftablesFilter) Apply() error {
+	nf.Lock()
+	defer nf.Unlock()
+
+	conn, err := nftables.New()
+	if err != nil {
+		return err
+	}
+
+	// Add (if missing) and delete table. This way I don't need to check if it exists.
+	table := nf.table()
+	conn.AddTable(table)
+	conn.DelTable(table)
+	table = conn.AddTable(nf.table())
+
+	policy := nftables.ChainPolicyAccept
+	forward := conn.AddChain(&nftables.Chain{
+		Name:     nftForwardChain,
+		Table:    table,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookForward,
+		Priority: nftables.ChainPriorityFilter,
+		Policy:   &policy,
+	})
+	postrouting := conn.AddChain(&nftables.Chain{
+		Name:     nftPostroutingChan,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookPostrouting,
+		Priority: nftables.ChainPriorityNATSource,
+	})
+
+	// Forward rules (and masquerade) for wireguard interfaces
+	for _, ifname := range sortedKeys(nf.forward) {
+		conn.AddRule(&nftables.Rule{
+			Table: table,
+			Chain: forward,
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifnameBytes(ifname)},
+				&expr.Verdict{Kind: expr.VerdictAccept},
+			},
+		})
+	}
+
+	if len(nf.forward) > 0 {
+		count := 0
+		if _, dri, err := netcfg.DefaultRoute(); err == nil {
+			conn.AddRule(masqueradeRule(table, postrouting, unix.NFPROTO_IPV4, dri))
+			count++
+		}
+		if _, dri, err := netcfg.DefaultRoute6(); err == nil {
+			conn.AddRule(masqueradeRule(table, postrouting, unix.NFPROTO_IPV6, dri))
+			count++
+		}
+		if count == 0 {
+			logger.Error().Println(pkgName, "could not parse default route interface")
+		}
+	}
+
+	for _, rule := range nf.peerRules(table, forward) {
+		conn.AddRule(rule)
+	}
+
+	err = conn.Flush()
+	if err != nil {
+		return fmt.Errorf("nftables apply: %s", err)
+	}
+
+	if !nf.applied {
+		logger.Info().Println(pkgName, "nftables table", nftTableName, "created")
+		nf.applied = true
+	}
+	logger.Debug().Println(pkgName, "nftables applied. Interfaces:", len(nf.forward), "peers:", len(nf.peers))
+	return nil
+}
+
+// peerRules builds peers allowed IPs rules (source and destination match) in a stable order
+func (nf *nftablesFilter) peerRules(table *nftables.Table, chain *nftables.Chain) []*nftables.Rule {
+	if !nf.chainCreated {
+		return nil
+	}
+
+	ips := make([]netip.Prefix, 0, len(nf.peers))
+	for ip := range nf.peers {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		return ips[i].String() < ips[j].String()
+	})
+
+	rules := []*nftables.Rule{}
+	for _, ip := range ips {
+		for _, src := range []bool{true, false} {
+			rules = append(rules, &nftables.Rule{
+				Table: table,
+				Chain: chain,
+				Exprs: prefixMatchAccept(ip, src),
+			})
+		}
+	}
+	return rules
+}
+
+func (nf *nftablesFilter) Close() error {
+	nf.Lock()
+	defer nf.Unlock()
+
+	if !config.CleanupOnExit() || !nf.applied {
+		return nil
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return err
+	}
+	conn.DelTable(nf.table())
+	return conn.Flush()
+}
+
+// Interface name is compared as 16 bytes null terminated string
+func ifnameBytes(ifname string) []byte {
+	b := make([]byte, unix.IFNAMSIZ)
+	copy(b, ifname+"\x00")
+	return b
+}
+
+func masqueradeRule(table *nftables.Table, chain *nftables.Chain, family byte, ifname string) *nftables.Rule {
+	return &nftables.Rule{
+		Table: table,
+		Chain: chain,
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+			&expr.Meta{Key: expr.MetaKeyOIFNAME, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifnameBytes(ifname)},
+			&expr.Masq{},
+		},
+	}
+}
+
+// Builds `ip[6] saddr|daddr <prefix> accept` rule expressions
+func prefixMatchAccept(ip netip.Prefix, src bool) []expr.Any {
+	family := byte(unix.NFPROTO_IPV4)
+	// IPv4 header source and destination offsets
+	offset := uint32(12)
+	if !src {
+		offset = 16
+	}
+	if ip.Addr().Is6() {
+		family = unix.NFPROTO_IPV6
+		// IPv6 header source and destination offsets
+		offset = 8
+		if !src {
+			offset = 24
+		}
+	}
+	addrLen := uint32(ip.Addr().BitLen() / 8)
+
+	exprs := []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+		&expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseNetworkHeader,
+			Offset:       offset,
+			Len:          addrLen,
+		},
+	}
+	if ip.Bits() < ip.Addr().BitLen() {
+		exprs = append(exprs, &expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            addrLen,
+			Mask:           net.CIDRMask(ip.Bits(), ip.Addr().BitLen()),
+			Xor:            make([]byte, addrLen),
+		})
+	}
+
+	return append(exprs,
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ip.Masked().Addr().AsSlice()},
+		&expr.Verdict{Kind: expr.VerdictAccept},
+	)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	rv := make([]string, 0, len(m))
+	for k := range m {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/SyntropyNet/syntropy-agent/agent/hostroute"
 	"github.com/SyntropyNet/syntropy-agent/agent/mole/ctrlmgr"
@@ -27,9 +28,13 @@ type Mole struct {
 	wg                   *swireguard.Wireguard
 	router               *router.Router
 	filter               *ipfilter.PacketFilter
+	filterChain          bool // SYNTROPY_CHAIN was created
 	hostRoute            *hostroute.HostRouter
 	peers                *peercache.PeerCache
 	controllerHostRoutes ctrlmgr.ControllerHostRouteManager
+	connCheck            ConnectivityCheck
+	connReachable        bool      // cached connectivity check result
+	connChecked          time.Time // when connectivity was checked
 }
 
 func New(w io.Writer) (*Mole, error) {
@@ -0,0 +1,232 @@
+package mole
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/agent/swireguard"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+	"github.com/SyntropyNet/syntropy-agent/pkg/netcfg"
+)
+
+// Configurer is implemented by Mole (configuration is applied)
+// and by Planner (configuration changes are only calculated - dry-run)
+type Configurer interface {
+	Flush()
+	CreateInterface(ii *swireguard.InterfaceInfo) error
+	AddPeer(pi *swireguard.PeerInfo, netpath *common.SdnNetworkPath) error
+	RemovePeer(pi *swireguard.PeerInfo, netpath *common.SdnNetworkPath) error
+}
+
+// Plan objects, in the order they are reported
+const (
+	planInterface  = "interface"
+	planPeer       = "peer"
+	planRoute      = "route"
+	planHostRoute  = "host_route"
+	planFilterRule = "filter_rule"
+)
+
+var planObjects = []string{planInterface, planPeer, planRoute, planHostRoute, planFilterRule}
+
+type planState struct {
+	interfaces map[string]*swireguard.InterfaceInfo
+	peers      map[string]*swireguard.PeerInfo // by ifname and public key
+}
+
+// Planner collects requested configuration without touching OS and mole caches.
+// Plan() returns a diff between current and requested configurations.
+type Planner struct {
+	mole    *Mole
+	current planState
+	wanted  planState
+}
+
+func peerKey(ifname, publicKey string) string {
+	return ifname + " " + publicKey
+}
+
+// NewPlanner creates planner. Initially requested state is the current state,
+// so incremental changes (WG_CONF) are planned on top of it.
+func (m *Mole) NewPlanner() *Planner {
+	m.Lock()
+	defer m.Unlock()
+
+	p := &Planner{
+		mole: m,
+		current: planState{
+			interfaces: make(map[string]*swireguard.InterfaceInfo),
+			peers:      make(map[string]*swireguard.PeerInfo),
+		},
+	}
+	for _, dev := range m.wg.Devices() {
+		p.current.interfaces[dev.IfName] = dev
+		for _, peer := range dev.Peers() {
+			p.current.peers[peerKey(peer.IfName, peer.PublicKey)] = peer
+		}
+	}
+
+	p.wanted = planState{
+		interfaces: make(map[string]*swireguard.InterfaceInfo),
+		peers:      make(map[string]*swireguard.PeerInfo),
+	}
+	for k, v := range p.current.interfaces {
+		p.wanted.interfaces[k] = v
+	}
+	for k, v := range p.current.peers {
+		p.wanted.peers[k] = v
+	}
+
+	return p
+}
+
+// Flush drops requested state (full configuration will be planned)
+func (p *Planner) Flush() {
+	p.wanted.interfaces = make(map[string]*swireguard.InterfaceInfo)
+	p.wanted.peers = make(map[string]*swireguard.PeerInfo)
+}
+
+func (p *Planner) CreateInterface(ii *swireguard.InterfaceInfo) error {
+	if ii == nil {
+		return fmt.Errorf("invalid parameters to CreateInterface")
+	}
+	p.wanted.interfaces[ii.IfName] = ii
+	return nil
+}
+
+func (p *Planner) AddPeer(pi *swireguard.PeerInfo, netpath *common.SdnNetworkPath) error {
+	if _, ok := p.wanted.interfaces[pi.IfName]; !ok {
+		return fmt.Errorf("peer add: interface %s does not exist", pi.IfName)
+	}
+	p.wanted.peers[peerKey(pi.IfName, pi.PublicKey)] = pi
+	return nil
+}
+
+func (p *Planner) RemovePeer(pi *swireguard.PeerInfo, netpath *common.SdnNetworkPath) error {
+	key := peerKey(pi.IfName, pi.PublicKey)
+	if _, ok := p.wanted.peers[key]; !ok {
+		return fmt.Errorf("peer remove: peer %s does not exist", pi.PublicKey)
+	}
+	delete(p.wanted.peers, key)
+	return nil
+}
+
+// Plan compares current and requested configuration
+func (p *Planner) Plan(message string) *ctlapi.Plan {
+	plan := &ctlapi.Plan{
+		Message: message,
+		Changes: []ctlapi.PlanEntry{},
+	}
+
+	current := p.objects(&p.current)
+	wanted := p.objects(&p.wanted)
+
+	for _, object := range planObjects {
+		cur, want := current[object], wanted[object]
+
+		for _, name := range sortedNames(cur) {
+			w, ok := want[name]
+			if !ok {
+				plan.Changes = append(plan.Changes, ctlapi.PlanEntry{
+					Action:  ctlapi.PlanRemove,
+					Object:  object,
+					Name:    name,
+					Details: cur[name],
+				})
+			} else if w != cur[name] {
+				plan.Changes = append(plan.Changes, ctlapi.PlanEntry{
+					Action:  ctlapi.PlanChange,
+					Object:  object,
+					Name:    name,
+					Details: cur[name] + " -> " + w,
+				})
+			}
+		}
+		for _, name := range sortedNames(want) {
+			if _, ok := cur[name]; !ok {
+				plan.Changes = append(plan.Changes, ctlapi.PlanEntry{
+					Action:  ctlapi.PlanAdd,
+					Object:  object,
+					Name:    name,
+					Details: want[name],
+				})
+			}
+		}
+	}
+
+	return plan
+}
+
+// objects converts state to comparable objects, grouped by object type.
+// Values are human readable object details.
+// Mirrors what mole would configure for interfaces and peers.
+func (p *Planner) objects(s *planState) map[string]map[string]string {
+	rv := make(map[string]map[string]string)
+	for _, object := range planObjects {
+		rv[object] = make(map[string]string)
+	}
+
+	for ifname, ii := range s.interfaces {
+		details := ""
+		if ii.IP.IsValid() {
+			details = ii.IP.String()
+		}
+		rv[planInterface][ifname] = details
+		if config.CreateIptablesRules() {
+			rv[planFilterRule]["forward "+ifname] = ""
+		}
+	}
+
+	for _, pi := range s.peers {
+		ips := []string{}
+		for _, ip := range pi.AllowedIPs {
+			ips = append(ips, ip.String())
+		}
+		endpoint := ""
+		if pi.IP.IsValid() {
+			endpoint = netip.AddrPortFrom(pi.IP, uint16(pi.Port)).String()
+		}
+		details := fmt.Sprintf("endpoint %s allowed_ips %s", endpoint, strings.Join(ips, ","))
+		if id := pi.PresharedKeyID(); id != "" {
+			// Key fingerprint only. Shows key rotation as a change.
+			details += " psk " + id
+		}
+		rv[planPeer][peerKey(pi.IfName, pi.PublicKey)] = details
+
+		if pi.IP.IsValid() && p.mole.hostRoute.Reachable(pi.IP) {
+			rv[planHostRoute][netip.PrefixFrom(pi.IP, pi.IP.BitLen()).String()] = ""
+		}
+
+		for idx, ip := range pi.AllowedIPs {
+			if p.mole.filterChain {
+				rv[planFilterRule]["accept "+ip.Masked().String()] = ""
+			}
+			// Same as Router.RouteAdd: first IP is the peer itself, others are services behind it
+			if !config.IsVPNClient() && netcfg.IsDefaultRoute(&ip) {
+				continue
+			}
+			kind := "service"
+			if idx == 0 {
+				kind = "peer"
+			}
+			name := fmt.Sprintf("%s via %s", ip, pi.AllowedIPs[0].Addr())
+			rv[planRoute][name] = fmt.Sprintf("%s on %s [%d:%d]",
+				kind, pi.IfName, pi.ConnectionID, pi.GroupID)
+		}
+	}
+
+	return rv
+}
+
+func sortedNames(m map[string]string) []string {
+	rv := make([]string, 0, len(m))
+	for k := range m {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
@@ -0,0 +1,233 @@
+package mole
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/agent/peeradata"
+	"github.com/SyntropyNet/syntropy-agent/agent/routestatus"
+	"github.com/SyntropyNet/syntropy-agent/agent/swireguard"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+// Apply stages, reported to controller on failure
+const (
+	stageWireguard    = "wireguard"
+	stageHostRoutes   = "host_routes"
+	stagePacketFilter = "packet_filter"
+	stageConnectivity = "connectivity_check"
+)
+
+const (
+	connectivityRetries = 3
+	connectivityDelay   = 2 * time.Second
+	// Configuration messages often come in bursts. Do not check controller before each of them.
+	connectivityCacheTime = time.Minute
+)
+
+// ConnectivityCheck returns nil if controller is reachable
+type ConnectivityCheck func() error
+
+// Transaction keeps configuration, that was active before changes.
+// Wireguard interfaces and peers are used to rebuild routes.
+// Host routes and packet filter rules are restored exactly as they were.
+type Transaction struct {
+	devices     []*swireguard.InterfaceInfo
+	hostRoutes  map[netip.Prefix]uint32
+	filterRules []netip.Prefix
+	// controller was reachable before changes
+	reachable bool
+}
+
+// committer is the configuration transaction is working with. Mole implements it.
+type committer interface {
+	apply() (*routestatus.Message, *peeradata.Message, *routestatus.ApplyError)
+	checkConnectivity() error
+	rollback(tx *Transaction)
+}
+
+// SetConnectivityCheck configures post-apply controller reachability check
+func (m *Mole) SetConnectivityCheck(check ConnectivityCheck) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.connCheck = check
+	m.connChecked = time.Time{}
+}
+
+// Begin starts configuration transaction. Must be called before changing configuration.
+// Returns nil, if rollback is disabled. Commit accepts nil transaction.
+func (m *Mole) Begin() *Transaction {
+	if !config.ApplyRollback() {
+		return nil
+	}
+	return m.begin()
+}
+
+func (m *Mole) begin() *Transaction {
+	m.Lock()
+	tx := &Transaction{}
+	for _, dev := range m.wg.Devices() {
+		tx.devices = append(tx.devices, dev.Clone())
+	}
+	tx.hostRoutes = m.hostRoute.Routes()
+	tx.filterRules = m.filter.Rules()
+	m.Unlock()
+
+	// If controller is unreachable already - there is no sense checking it after apply
+	tx.reachable = m.controllerReachable()
+
+	return tx
+}
+
+// controllerReachable runs connectivity check, if it is configured.
+// Result is cached for connectivityCacheTime.
+func (m *Mole) controllerReachable() bool {
+	m.Lock()
+	check := m.connCheck
+	if check == nil {
+		m.Unlock()
+		return false
+	}
+	if time.Since(m.connChecked) < connectivityCacheTime {
+		reachable := m.connReachable
+		m.Unlock()
+		return reachable
+	}
+	m.Unlock()
+
+	err := check()
+	if err != nil {
+		logger.Warning().Println(pkgName, "controller is unreachable", err)
+	}
+
+	m.Lock()
+	m.connReachable = err == nil
+	m.connChecked = time.Now()
+	m.Unlock()
+
+	return err == nil
+}
+
+// Commit applies configuration changes (same as Apply).
+// If any apply stage or controller connectivity check fails,
+// previous configuration is restored and error is reported in route status message.
+func (m *Mole) Commit(tx *Transaction) error {
+	return commit(m, tx, m.writer)
+}
+
+func commit(c committer, tx *Transaction, w io.Writer) error {
+	routeStatusMessage, peersActiveDataMessage, applyErr := c.apply()
+
+	if applyErr == nil && tx != nil && tx.reachable {
+		err := c.checkConnectivity()
+		if err != nil {
+			logger.Error().Println(pkgName, "controller is unreachable after apply", err)
+			applyErr = &routestatus.ApplyError{
+				Stage:   stageConnectivity,
+				Message: err.Error(),
+			}
+		}
+	}
+
+	if applyErr != nil && tx != nil {
+		logger.Warning().Println(pkgName, "Rolling back configuration. Failed stage:", applyErr.Stage)
+		c.rollback(tx)
+
+		// Report the state after rollback. Failed configuration routes are not valid any more.
+		var rollbackErr *routestatus.ApplyError
+		routeStatusMessage, peersActiveDataMessage, rollbackErr = c.apply()
+		if rollbackErr != nil {
+			logger.Error().Println(pkgName, "rollback failed", rollbackErr.Stage, rollbackErr.Message)
+		}
+		applyErr.RolledBack = rollbackErr == nil
+	}
+
+	routeStatusMessage.Error = applyErr
+	routeStatusMessage.Send(w)
+	peersActiveDataMessage.Send(w)
+
+	if applyErr != nil {
+		return fmt.Errorf("%s: %s", applyErr.Stage, applyErr.Message)
+	}
+	return nil
+}
+
+// Retry a few times, because new routes may need some time to settle
+func (m *Mole) checkConnectivity() error {
+	m.Lock()
+	check := m.connCheck
+	m.Unlock()
+
+	var err error
+	for i := 0; i < connectivityRetries; i++ {
+		if i > 0 {
+			time.Sleep(connectivityDelay)
+		}
+		err = check()
+		if err == nil {
+			break
+		}
+	}
+
+	m.Lock()
+	if err == nil {
+		m.connReachable = true
+		m.connChecked = time.Now()
+	} else {
+		// Configuration will be rolled back. Check again before the next transaction.
+		m.connChecked = time.Time{}
+	}
+	m.Unlock()
+
+	return err
+}
+
+// rollback rebuilds caches from transaction and leaves them for apply.
+func (m *Mole) rollback(tx *Transaction) {
+	// iptables backend applies rules immediately and does not delete them on Flush.
+	// Remember failed configuration rules and delete those, that are not restored.
+	m.Lock()
+	failedRules := m.filter.Rules()
+	m.Unlock()
+
+	m.Flush()
+
+	for _, dev := range tx.devices {
+		err := m.CreateInterface(dev.Clone())
+		if err != nil {
+			logger.Error().Println(pkgName, "rollback interface", dev.IfName, err)
+			continue
+		}
+
+		for _, peer := range dev.Peers() {
+			if len(peer.AllowedIPs) == 0 {
+				continue
+			}
+			netpath := &common.SdnNetworkPath{
+				Ifname:       peer.IfName,
+				PublicKey:    peer.PublicKey,
+				Gateway:      peer.AllowedIPs[0].Addr(),
+				ConnectionID: peer.ConnectionID,
+				GroupID:      peer.GroupID,
+			}
+			err = m.AddPeer(peer, netpath)
+			if err != nil {
+				logger.Error().Println(pkgName, "rollback peer", peer.PublicKey, err)
+			}
+		}
+	}
+
+	// Peers rebuild host routes and rules, but a failed peer would leave them incomplete
+	m.Lock()
+	defer m.Unlock()
+	m.hostRoute.Restore(tx.hostRoutes)
+	err := m.filter.Restore(tx.filterRules, failedRules)
+	if err != nil {
+		logger.Error().Println(pkgName, "rollback packet filter rules", err)
+	}
+}
@@ -0,0 +1,124 @@
+package mole
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/hostroute"
+	"github.com/SyntropyNet/syntropy-agent/agent/mole/ipfilter"
+	"github.com/SyntropyNet/syntropy-agent/agent/peeradata"
+	"github.com/SyntropyNet/syntropy-agent/agent/routestatus"
+	"github.com/SyntropyNet/syntropy-agent/agent/swireguard"
+)
+
+type testCommitter struct {
+	applyErrs []*routestatus.ApplyError // results of consequent apply calls
+	applies   int
+	connErr   error
+	checks    int
+	rollbacks int
+}
+
+func (c *testCommitter) apply() (*routestatus.Message, *peeradata.Message, *routestatus.ApplyError) {
+	var err *routestatus.ApplyError
+	if c.applies < len(c.applyErrs) {
+		err = c.applyErrs[c.applies]
+	}
+	c.applies++
+	return routestatus.New(), peeradata.NewMessage(), err
+}
+
+func (c *testCommitter) checkConnectivity() error {
+	c.checks++
+	return c.connErr
+}
+
+func (c *testCommitter) rollback(tx *Transaction) {
+	c.rollbacks++
+}
+
+func TestCommit(t *testing.T) {
+	// commit updates error, thus every test needs a new one
+	wgErr := func() *routestatus.ApplyError {
+		return &routestatus.ApplyError{Stage: stageWireguard, Message: "failed"}
+	}
+
+	tests := []struct {
+		name      string
+		committer testCommitter
+		tx        *Transaction
+		rollbacks int
+		checks    int
+		status    string // expected in route status message, empty if message is not sent
+	}{
+		{"success", testCommitter{}, &Transaction{reachable: true}, 0, 1, ""},
+		{"rollback disabled", testCommitter{applyErrs: []*routestatus.ApplyError{wgErr()}}, nil, 0, 0,
+			`"stage":"wireguard","msg":"failed","rolled_back":false`},
+		{"wireguard failure", testCommitter{applyErrs: []*routestatus.ApplyError{wgErr()}}, &Transaction{reachable: true}, 1, 0,
+			`"stage":"wireguard","msg":"failed","rolled_back":true`},
+		{"host routes failure", testCommitter{applyErrs: []*routestatus.ApplyError{
+			{Stage: stageHostRoutes, Message: "failed"}}}, &Transaction{}, 1, 0,
+			`"stage":"host_routes","msg":"failed","rolled_back":true`},
+		{"controller unreachable", testCommitter{connErr: errors.New("timeout")}, &Transaction{reachable: true}, 1, 1,
+			`"stage":"connectivity_check","msg":"timeout","rolled_back":true`},
+		{"controller was unreachable before", testCommitter{connErr: errors.New("timeout")}, &Transaction{}, 0, 0, ""},
+		{"rollback failure", testCommitter{applyErrs: []*routestatus.ApplyError{wgErr(), wgErr()}}, &Transaction{}, 1, 0,
+			`"stage":"wireguard","msg":"failed","rolled_back":false`},
+	}
+
+	for _, tt := range tests {
+		w := &bytes.Buffer{}
+		err := commit(&tt.committer, tt.tx, w)
+		if (err != nil) != (tt.status != "") {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.committer.rollbacks != tt.rollbacks || tt.committer.checks != tt.checks {
+			t.Errorf("%s: rollbacks %d (expected %d), checks %d (expected %d)", tt.name,
+				tt.committer.rollbacks, tt.rollbacks, tt.committer.checks, tt.checks)
+		}
+		if !strings.Contains(w.String(), tt.status) || (tt.status == "" && w.Len() > 0) {
+			t.Errorf("%s: route status %q, expected %q", tt.name, w.String(), tt.status)
+		}
+	}
+}
+
+func TestBegin(t *testing.T) {
+	m := &Mole{wg: &swireguard.Wireguard{}, hostRoute: &hostroute.HostRouter{}, filter: &ipfilter.PacketFilter{}}
+
+	// No connectivity check - nothing to verify after apply
+	if tx := m.begin(); tx == nil || tx.reachable {
+		t.Errorf("Transaction without connectivity check %+v", tx)
+	}
+
+	checks := 0
+	var connErr error
+	m.SetConnectivityCheck(func() error {
+		checks++
+		return connErr
+	})
+
+	// Check result is cached between transactions
+	for i := 0; i < 3; i++ {
+		if tx := m.begin(); !tx.reachable {
+			t.Errorf("Controller is reachable")
+		}
+	}
+	if checks != 1 {
+		t.Errorf("Connectivity checked %d times", checks)
+	}
+
+	// Expired result is checked again
+	connErr = errors.New("timeout")
+	m.connChecked = m.connChecked.Add(-connectivityCacheTime)
+	if tx := m.begin(); tx.reachable {
+		t.Errorf("Controller is unreachable")
+	}
+
+	// Successful post-apply check updates cache
+	connErr = nil
+	if err := m.checkConnectivity(); err != nil || !m.controllerReachable() || checks != 3 {
+		t.Errorf("Post-apply check %v, checks %d", err, checks)
+	}
+}
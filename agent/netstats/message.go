@@ -19,12 +19,17 @@ const (
 )
 
 type PeerDataEntry struct {
-	PublicKey    string  `json:"public_key"`
-	IP           string  `json:"internal_ip"`
-	Handshake    string  `json:"last_handshake,omitempty"`
-	KeepAllive   int     `json:"keep_alive_interval"`
-	Latency      float32 `json:"latency_ms,omitempty"`
-	Loss         float32 `json:"packet_loss"`
+	PublicKey  string  `json:"public_key"`
+	IP         string  `json:"internal_ip"`
+	Handshake  string  `json:"last_handshake,omitempty"`
+	KeepAllive int     `json:"keep_alive_interval"`
+	Latency    float32 `json:"latency_ms,omitempty"`
+	Jitter     float32 `json:"jitter_ms,omitempty"`
+	Loss       float32 `json:"packet_loss"`
+	// Latency percentiles over SYNTROPY_PEERCHECK_WINDOW
+	LatencyP50   float32 `json:"latency_p50_ms,omitempty"`
+	LatencyP95   float32 `json:"latency_p95_ms,omitempty"`
+	LatencyP99   float32 `json:"latency_p99_ms,omitempty"`
 	RxBytes      int64   `json:"rx_bytes"`
 	TxBytes      int64   `json:"tx_bytes"`
 	RxSpeed      float32 `json:"rx_speed_mbps"`
@@ -34,9 +39,11 @@ type PeerDataEntry struct {
 }
 
 type IfaceBwEntry struct {
-	IfName    string           `json:"iface"`
-	PublicKey string           `json:"iface_public_key"`
-	Peers     []*PeerDataEntry `json:"peers"`
+	IfName    string `json:"iface"`
+	PublicKey string `json:"iface_public_key"`
+	// Wireguard implementation: kernel or userspace
+	Implementation string           `json:"implementation,omitempty"`
+	Peers          []*PeerDataEntry `json:"peers"`
 }
 
 type Message struct {
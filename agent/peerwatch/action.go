@@ -80,9 +80,10 @@ func (obj *wgPeerWatcher) message2controller(wgdevs []*swireguard.InterfaceInfo)
 	resp := netstats.NewMessage()
 	for _, wgdev := range wgdevs {
 		ifaceData := netstats.IfaceBwEntry{
-			IfName:    wgdev.IfName,
-			PublicKey: wgdev.PublicKey,
-			Peers:     []*netstats.PeerDataEntry{},
+			IfName:         wgdev.IfName,
+			PublicKey:      wgdev.PublicKey,
+			Implementation: wgdev.Implementation,
+			Peers:          []*netstats.PeerDataEntry{},
 		}
 
 		for _, p := range wgdev.Peers() {
@@ -131,6 +132,13 @@ func (obj *wgPeerWatcher) message2controller(wgdevs []*swireguard.InterfaceInfo)
 					entry.Loss = netstats.PingLoss
 					logger.Warning().Println(pkgName, "Invalid ping stats for", ipAddr)
 				}
+				// Jitter and percentiles are calculated over the same SYNTROPY_PEERCHECK_WINDOW
+				if ls, ok := obj.mole.Router().PeerLatencyStats(ipAddr.Addr()); ok {
+					entry.Jitter = ls.Jitter
+					entry.LatencyP50 = ls.P50
+					entry.LatencyP95 = ls.P95
+					entry.LatencyP99 = ls.P99
+				}
 				// Once again read comment above
 				// Add only fully configured and already pinged peers
 				ifaceData.Peers = append(ifaceData.Peers, entry)
@@ -72,6 +72,10 @@ func (obj *wgPeerWatcher) Run(ctx context.Context) error {
 				return
 			case <-ticker.C:
 				obj.execute(ctx)
+				// Peer check period may be changed by configuration reload
+				period := config.PeerCheckTime()
+				ticker.Reset(period)
+				obj.controlerSendCount = uint(time.Minute / period)
 			}
 		}
 	}()
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+// Reload rereads configuration file and applies changed settings at runtime.
+// Settings, that cannot be changed at runtime, are reported as requiring restart.
+func (agent *Agent) Reload() error {
+	logger.Info().Println(pkgName, "Reloading configuration file", config.ConfigFile())
+	res, err := config.Reload()
+	if err != nil {
+		logger.Error().Println(pkgName, "Configuration reload failed. Keeping current configuration:", err)
+		return err
+	}
+
+	if len(res.Overridden) > 0 {
+		logger.Warning().Println(pkgName, "Settings overridden by environment variables:",
+			strings.Join(res.Overridden, ", "))
+	}
+	if len(res.Restart) > 0 {
+		logger.Warning().Println(pkgName, "Settings changes require agent restart:",
+			strings.Join(res.Restart, ", "))
+	}
+	if len(res.Applied) == 0 {
+		logger.Info().Println(pkgName, "No runtime settings changed")
+		return nil
+	}
+
+	agent.applySettings()
+	logger.Info().Println(pkgName, "Settings applied:", strings.Join(res.Applied, ", "))
+	// Route strategy and features may have changed
+	agent.capabilities.Send()
+
+	return nil
+}
+
+// applySettings pushes runtime settings to components, that do not read configuration on every use.
+// Other components (e.g. host services, peer watcher) pick up changes on their next iteration.
+func (agent *Agent) applySettings() {
+	logger.SetLevel(config.GetDebugLevel())
+	agent.mole.Router().Reconfigure()
+	if agent.exporter != nil {
+		agent.exporter.SetPort(config.MetricsExporterPort())
+	}
+}
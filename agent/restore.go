@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"github.com/SyntropyNet/syntropy-agent/agent/configinfo"
+	"github.com/SyntropyNet/syntropy-agent/agent/docker"
+	"github.com/SyntropyNet/syntropy-agent/agent/snapshot"
+	"github.com/SyntropyNet/syntropy-agent/agent/wgconf"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+// restoreSnapshot replays the last applied configuration (CONFIG_INFO + WG_CONF deltas)
+// Controller will send a fresh CONFIG_INFO after connecting and it will supersede restored state
+func (agent *Agent) restoreSnapshot(store *snapshot.Store, dockerHelper docker.DockerHelper) {
+	if store == nil {
+		return
+	}
+
+	payload, err := store.Load()
+	if err != nil {
+		if err != snapshot.ErrNoSnapshot {
+			logger.Warning().Println(pkgName, "Configuration snapshot ignored:", err)
+		}
+		return
+	}
+
+	logger.Info().Println(pkgName, "Restoring configuration snapshot from", payload.Timestamp)
+	err = configinfo.Restore(agent.mole, dockerHelper, payload.ConfigInfo, payload.Keys,
+		payload.PresharedKeys)
+	if err != nil {
+		logger.Error().Println(pkgName, "Configuration snapshot restore", err)
+		return
+	}
+	for _, raw := range payload.WgConf {
+		err = wgconf.Restore(agent.mole, raw, payload.PresharedKeys)
+		if err != nil {
+			logger.Error().Println(pkgName, "Configuration snapshot WG_CONF restore", err)
+		}
+	}
+
+	// Single apply for the whole restored configuration
+	agent.mole.Apply()
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/peerlist"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+)
+
+// Info returns route groups state. Used by control socket.
+func (r *Router) Info() []ctlapi.RouteGroup {
+	r.Lock()
+	defer r.Unlock()
+
+	rv := []ctlapi.RouteGroup{}
+	for gid, routesGroup := range r.routes {
+		rv = append(rv, ctlapi.RouteGroup{
+			GroupID:  gid,
+			Selected: routesGroup.peerMonitor.Selected(),
+			Peers:    routesGroup.peerMonitor.Info(),
+			Services: routesGroup.serviceMonitor.Info(),
+		})
+	}
+
+	// maps are not ordered. Make output stable
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].GroupID < rv[j].GroupID
+	})
+
+	return rv
+}
+
+// PeerLatencyStats returns peer latency distribution over moving average window
+func (r *Router) PeerLatencyStats(addr netip.Addr) (peerlist.LatencyStats, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	dest := netip.PrefixFrom(addr, addr.BitLen())
+	for _, routesGroup := range r.routes {
+		if stats, ok := routesGroup.peerMonitor.LatencyStats(dest); ok {
+			return stats, true
+		}
+	}
+	return peerlist.LatencyStats{}, false
+}
+
+// SetPeerBandwidth stores peer path bandwidth probe result (in Mbps)
+func (r *Router) SetPeerBandwidth(addr netip.Addr, mbps float32) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	found := false
+	dest := netip.PrefixFrom(addr, addr.BitLen())
+	for _, routesGroup := range r.routes {
+		if routesGroup.peerMonitor.SetBandwidth(dest, mbps) {
+			found = true
+		}
+	}
+	return found
+}
@@ -20,6 +20,16 @@ var (
 		"Packet loss to connected peer",
 		labels, nil,
 	)
+	descJitter = prometheus.NewDesc(
+		"syntropy_platform_jitter",
+		"Mean packet latency difference between consecutive pings to connected peer",
+		labels, nil,
+	)
+	descLatencyQuantile = prometheus.NewDesc(
+		"syntropy_platform_latency_quantile",
+		"Packet latency percentiles to connected peer",
+		append([]string{"quantile"}, labels...), nil,
+	)
 )
 
 func (pm *PeerMonitor) Collect(ch chan<- prometheus.Metric, groupID int) {
@@ -36,5 +46,25 @@ func (pm *PeerMonitor) Collect(ch chan<- prometheus.Metric, groupID int) {
 			float64(peer.Loss()),
 			peer.Ifname, peer.PublicKey, addr.Addr().String(), strconv.Itoa(peer.ConnectionID), strconv.Itoa(groupID),
 		)
+
+		stats := peer.LatencyStats()
+		ch <- prometheus.MustNewConstMetric(
+			descJitter,
+			prometheus.GaugeValue,
+			float64(stats.Jitter),
+			peer.Ifname, peer.PublicKey, addr.Addr().String(), strconv.Itoa(peer.ConnectionID), strconv.Itoa(groupID),
+		)
+		for _, q := range []struct {
+			quantile string
+			value    float32
+		}{{"0.5", stats.P50}, {"0.95", stats.P95}, {"0.99", stats.P99}} {
+			ch <- prometheus.MustNewConstMetric(
+				descLatencyQuantile,
+				prometheus.GaugeValue,
+				float64(q.value),
+				q.quantile, peer.Ifname, peer.PublicKey, addr.Addr().String(),
+				strconv.Itoa(peer.ConnectionID), strconv.Itoa(groupID),
+			)
+		}
 	})
 }
@@ -0,0 +1,64 @@
+package peermon
+
+import (
+	"net/netip"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/peerlist"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+)
+
+// Info returns monitored peers state. Used by control socket.
+// NOTE: it is called under main Router lock
+func (pm *PeerMonitor) Info() []ctlapi.RoutePeer {
+	rv := []ctlapi.RoutePeer{}
+
+	pm.peerList.Iterate(func(ip netip.Prefix, entry *peerlist.PeerInfo) {
+		flags := ""
+		if entry.HasFlag(peerlist.PifAddPending) {
+			flags += "+"
+		}
+		if entry.HasFlag(peerlist.PifDelPending) {
+			flags += "-"
+		}
+		if entry.HasFlag(peerlist.PifDisabled) {
+			flags += "d"
+		}
+
+		stats := entry.LatencyStats()
+		rv = append(rv, ctlapi.RoutePeer{
+			Address:      ip.String(),
+			IfName:       entry.Ifname,
+			PublicKey:    entry.PublicKey,
+			ConnectionID: entry.ConnectionID,
+			Flags:        flags,
+			Latency:      entry.Latency(),
+			Loss:         entry.Loss(),
+			Jitter:       stats.Jitter,
+			LatencyP95:   stats.P95,
+			Bandwidth:    entry.Bandwidth(),
+		})
+	})
+
+	return rv
+}
+
+// Selected returns last selected best path (or nil, if none selected yet)
+// Differently from BestPath() it does not trigger new path calculation
+func (pm *PeerMonitor) Selected() *ctlapi.SelectedPath {
+	if pm.lastRoute == nil {
+		return nil
+	}
+
+	rv := &ctlapi.SelectedPath{
+		ConnectionID: pm.lastRoute.ID,
+	}
+	if pm.lastRoute.IP.IsValid() {
+		rv.Gateway = pm.lastRoute.IP.String()
+	}
+	if pm.lastRoute.Reason != nil {
+		rv.Reason = pm.lastRoute.Reason.Reason()
+		rv.Details = pm.lastRoute.Reason.String()
+	}
+
+	return rv
+}
@@ -0,0 +1,75 @@
+package peermon
+
+import (
+	"math"
+	"net/netip"
+	"sort"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/peerlist"
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector"
+)
+
+const (
+	// Packet loss is a fraction. So 1% of packet loss is as bad as 10ms latency
+	multipathLossPenalty = 1000
+	// Weight of the best path. Other paths get proportionally lower weights
+	multipathMaxWeight = 10
+)
+
+// Paths returns all healthy paths, whose score is within configured quality band from the best path.
+// Path weight is proportional to its quality, so better paths carry more traffic.
+// Implements MultiPathSelector interface.
+func (pm *PeerMonitor) Paths() []routeselector.Path {
+	type candidate struct {
+		ip    netip.Prefix
+		id    int
+		score float32
+	}
+	var candidates []candidate
+	var bestScore float32
+
+	pm.peerList.Iterate(func(ip netip.Prefix, peer *peerlist.PeerInfo) {
+		// New paths join only with full statistics, so a few lucky pings do not count
+		if !peer.Valid() || peer.StatsIncomplete() {
+			return
+		}
+		latency, loss := peer.Latency(), peer.Loss()
+		if latency <= 0 || loss >= 1 {
+			return
+		}
+		if pm.config.RouteDeleteLossThreshold > 0 &&
+			loss*100 >= pm.config.RouteDeleteLossThreshold {
+			return
+		}
+
+		score := latency + multipathLossPenalty*loss
+		if len(candidates) == 0 || score < bestScore {
+			bestScore = score
+		}
+		candidates = append(candidates, candidate{ip: ip, id: peer.ConnectionID, score: score})
+	})
+
+	limit := bestScore * (1 + pm.config.MultipathBand/100)
+	rv := []routeselector.Path{}
+	for _, c := range candidates {
+		if c.score > limit {
+			continue
+		}
+		weight := int(math.Round(float64(multipathMaxWeight * bestScore / c.score)))
+		if weight < 1 {
+			weight = 1
+		}
+		rv = append(rv, routeselector.Path{
+			IP:     c.ip.Addr(),
+			ID:     c.id,
+			Weight: weight,
+		})
+	}
+
+	// Map iteration order is random. Keep paths order stable.
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].IP.Less(rv[j].IP)
+	})
+
+	return rv
+}
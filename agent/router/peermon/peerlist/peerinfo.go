@@ -2,6 +2,8 @@ package peerlist
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 
 	"github.com/SyntropyNet/syntropy-agent/internal/env"
@@ -23,6 +25,8 @@ type PeerInfo struct {
 	latency      []float32
 	loss         []float32
 	index        int
+	// last active bandwidth probe result (Mbps). Zero if path was not probed
+	bandwidth float32
 }
 
 func NewPeerInfo(avgCount uint) *PeerInfo {
@@ -33,6 +37,28 @@ func NewPeerInfo(avgCount uint) *PeerInfo {
 	return &pi
 }
 
+// resize changes moving average window size. The latest measurements are kept.
+func (node *PeerInfo) resize(avgCount uint) {
+	size := len(node.latency)
+	latency := make([]float32, avgCount)
+	loss := make([]float32, avgCount)
+
+	// Measurements in chronological order: index points to the oldest one
+	count := size
+	if count > int(avgCount) {
+		count = int(avgCount)
+	}
+	for i := 0; i < count; i++ {
+		src := (node.index - count + i + size) % size
+		latency[i] = node.latency[src]
+		loss[i] = node.loss[src]
+	}
+
+	node.latency = latency
+	node.loss = loss
+	node.index = count % int(avgCount)
+}
+
 func (node *PeerInfo) Add(latency, loss float32) {
 	node.latency[node.index] = latency
 	node.loss[node.index] = loss
@@ -57,6 +83,97 @@ func (node *PeerInfo) Latency() float32 {
 	return 0
 }
 
+// LatencyStats are latency distribution statistics over moving average window
+type LatencyStats struct {
+	Jitter float32 // mean difference between consecutive latency samples
+	P50    float32
+	P95    float32
+	P99    float32
+}
+
+// samples returns valid latency values in chronological order (lost pings are skipped)
+func (node *PeerInfo) samples() []float32 {
+	rv := make([]float32, 0, len(node.latency))
+	// iterate from the oldest sample to the newest one
+	for i := 0; i < len(node.latency); i++ {
+		val := node.latency[(node.index+i)%len(node.latency)]
+		if val > 0 {
+			rv = append(rv, val)
+		}
+	}
+	return rv
+}
+
+// Jitter is a mean difference between consecutive latency samples
+// Lost pings (zero latency) are skipped
+func (node *PeerInfo) Jitter() float32 {
+	return jitter(node.samples())
+}
+
+// LatencyPercentile returns p-th (0..100) latency percentile (nearest-rank method)
+func (node *PeerInfo) LatencyPercentile(p float32) float32 {
+	return percentile(sorted(node.samples()), p)
+}
+
+func (node *PeerInfo) LatencyStats() LatencyStats {
+	samples := node.samples()
+	ordered := sorted(samples)
+	return LatencyStats{
+		Jitter: jitter(samples),
+		P50:    percentile(ordered, 50),
+		P95:    percentile(ordered, 95),
+		P99:    percentile(ordered, 99),
+	}
+}
+
+// samples must be in chronological order
+func jitter(samples []float32) float32 {
+	count := 0
+	var sum float32
+	for i := 1; i < len(samples); i++ {
+		diff := samples[i] - samples[i-1]
+		if diff < 0 {
+			diff = -diff
+		}
+		sum = sum + diff
+		count++
+	}
+	if count > 0 {
+		return sum / float32(count)
+	}
+	return 0
+}
+
+func sorted(samples []float32) []float32 {
+	rv := append([]float32{}, samples...)
+	sort.Slice(rv, func(i, j int) bool { return rv[i] < rv[j] })
+	return rv
+}
+
+// samples must be sorted
+func percentile(samples []float32, p float32) float32 {
+	if len(samples) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(float64(p) * float64(len(samples)) / 100))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(samples) {
+		rank = len(samples)
+	}
+	return samples[rank-1]
+}
+
+// Bandwidth returns achievable path throughput (in Mbps), measured by bandwidth probe
+// Zero means path was not probed yet. Is informational only and does not affect path score.
+func (node *PeerInfo) Bandwidth() float32 {
+	return node.bandwidth
+}
+
+func (node *PeerInfo) SetBandwidth(mbps float32) {
+	node.bandwidth = mbps
+}
+
 func (node *PeerInfo) Loss() float32 {
 	count := 0
 	var sum float32
@@ -50,3 +50,71 @@ func TestPeerInfo(t *testing.T) {
 		t.Errorf("invalid latency %f (%f expected)", pi.Latency(), expectedLatency)
 	}
 }
+
+func TestPeerInfoJitter(t *testing.T) {
+	pi := NewPeerInfo(5)
+
+	if pi.Jitter() != 0 {
+		t.Errorf("invalid empty jitter %f (0 expected)", pi.Jitter())
+	}
+
+	// Ring buffer is overwrapped: samples are 30, 0 (lost), 20, 40, 10
+	for _, latency := range []float32{99, 30, 0, 20, 40, 10} {
+		pi.Add(latency, 0)
+	}
+	// |20-30| + |40-20| + |10-40| = 60 / 3
+	if pi.Jitter() != 20 {
+		t.Errorf("invalid jitter %f (20 expected)", pi.Jitter())
+	}
+}
+
+func TestPeerInfoLatencyStats(t *testing.T) {
+	pi := NewPeerInfo(5)
+
+	if pi.LatencyPercentile(50) != 0 {
+		t.Errorf("invalid empty percentile %f (0 expected)", pi.LatencyPercentile(50))
+	}
+
+	// Ring buffer is overwrapped: samples are 30, 0 (lost), 20, 40, 10
+	for _, latency := range []float32{99, 30, 0, 20, 40, 10} {
+		pi.Add(latency, 0)
+	}
+	stats := pi.LatencyStats()
+	if stats.Jitter != 20 || stats.P50 != 20 || stats.P95 != 40 || stats.P99 != 40 {
+		t.Errorf("invalid latency stats %+v", stats)
+	}
+
+	for i := 1; i <= 100; i++ {
+		pi = NewPeerInfo(100)
+		for j := 100; j > 0; j-- {
+			pi.Add(float32(j), 0)
+		}
+		if pi.LatencyPercentile(float32(i)) != float32(i) {
+			t.Errorf("invalid percentile p%d = %f", i, pi.LatencyPercentile(float32(i)))
+		}
+	}
+}
+
+func TestPeerInfoResize(t *testing.T) {
+	pi := NewPeerInfo(5)
+	for i := 1; i <= 7; i++ {
+		pi.Add(float32(i), 0)
+	}
+
+	// Shrinking keeps the latest measurements
+	pi.resize(3)
+	if pi.Latency() != 6 {
+		t.Errorf("invalid latency after shrink %f (6 expected)", pi.Latency())
+	}
+	pi.Add(10, 0)
+	if pi.Latency() != float32(6+7+10)/3 {
+		t.Errorf("invalid latency after shrink and add %f", pi.Latency())
+	}
+
+	// Growing keeps all measurements and continues after them
+	pi.resize(6)
+	pi.Add(11, 0)
+	if len(pi.samples()) != 4 || pi.Latency() != float32(6+7+10+11)/4 {
+		t.Errorf("invalid latency after grow %f", pi.Latency())
+	}
+}
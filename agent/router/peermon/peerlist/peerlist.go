@@ -16,6 +16,17 @@ func NewPeerList(count uint) *PeerList {
 	}
 }
 
+// SetAvgCount changes moving average window size of all peers
+func (pl *PeerList) SetAvgCount(count uint) {
+	if count == pl.avgCount || count == 0 {
+		return
+	}
+	pl.avgCount = count
+	for _, peer := range pl.peers {
+		peer.resize(count)
+	}
+}
+
 func (pl *PeerList) AddPeer(ifname, pubKey string, endpoint netip.Prefix, connID int, disabled bool) {
 	e, ok := pl.peers[endpoint]
 	if !ok {
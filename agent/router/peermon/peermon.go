@@ -5,6 +5,7 @@ import (
 
 	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/peerlist"
 	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector"
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector/cost"
 	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector/dr"
 	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector/speed"
 	"github.com/SyntropyNet/syntropy-agent/internal/config"
@@ -26,6 +27,11 @@ type PeerMonitor struct {
 	groupID  int
 
 	pathSelector routeselector.PathSelector
+	// route strategy and window size pathSelector was created for
+	strategy int
+	window   uint
+	// last selected best path. Is kept for informational purposes only
+	lastRoute *routeselector.SelectedRoute
 }
 
 func New(cfg *routeselector.RouteSelectorConfig, gid int) *PeerMonitor {
@@ -34,13 +40,33 @@ func New(cfg *routeselector.RouteSelectorConfig, gid int) *PeerMonitor {
 		peerList: peerlist.NewPeerList(cfg.AverageSize),
 		config:   cfg,
 	}
-	if cfg.RouteStrategy == config.RouteStrategyDirectRoute {
+	pm.newPathSelector()
+
+	return pm
+}
+
+func (pm *PeerMonitor) newPathSelector() {
+	pm.strategy = pm.config.RouteStrategy
+	pm.window = pm.config.AverageSize
+	switch pm.strategy {
+	case config.RouteStrategyDirectRoute:
 		pm.pathSelector = dr.New(pm.peerList, pm.config)
-	} else {
+	case config.RouteStrategyCost:
+		pm.pathSelector = cost.New(pm.peerList, pm.config)
+	default:
 		pm.pathSelector = speed.New(pm.peerList, pm.config)
 	}
+}
 
-	return pm
+// Reconfigure recreates path selector, if configured route strategy or window size has changed.
+// Peers and their latest statistics are kept.
+func (pm *PeerMonitor) Reconfigure() {
+	if pm.window != pm.config.AverageSize {
+		pm.peerList.SetAvgCount(pm.config.AverageSize)
+		pm.newPathSelector()
+	} else if pm.strategy != pm.config.RouteStrategy {
+		pm.newPathSelector()
+	}
 }
 
 func (pm *PeerMonitor) AddNode(ifname, pubKey string, endpoint netip.Prefix, connID int, disabled bool) {
@@ -65,6 +91,24 @@ func (pm *PeerMonitor) Dump() {
 	})
 }
 
+// LatencyStats returns peer latency distribution over moving average window
+func (pm *PeerMonitor) LatencyStats(endpoint netip.Prefix) (peerlist.LatencyStats, bool) {
+	peer, ok := pm.peerList.GetPeer(endpoint)
+	if !ok {
+		return peerlist.LatencyStats{}, false
+	}
+	return peer.LatencyStats(), true
+}
+
+// SetBandwidth stores bandwidth probe result, so it is available to route selector
+func (pm *PeerMonitor) SetBandwidth(endpoint netip.Prefix, mbps float32) bool {
+	peer, ok := pm.peerList.GetPeer(endpoint)
+	if ok {
+		peer.SetBandwidth(mbps)
+	}
+	return ok
+}
+
 func (pm *PeerMonitor) Count() int {
 	return pm.peerList.Count()
 }
@@ -74,5 +118,15 @@ func (pm *PeerMonitor) PingProcess(pr *pingdata.PingData) {
 }
 
 func (pm *PeerMonitor) BestPath() *routeselector.SelectedRoute {
-	return pm.pathSelector.BestPath()
+	route := pm.pathSelector.BestPath()
+
+	// Keep the reason, that caused the last route change
+	if route != nil && pm.lastRoute != nil &&
+		!route.Reason.Changed() && route.ID == pm.lastRoute.ID {
+		pm.lastRoute.IP = route.IP
+	} else {
+		pm.lastRoute = route
+	}
+
+	return route
 }
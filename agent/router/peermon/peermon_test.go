@@ -252,6 +252,97 @@ func TestPeerMonitorDirectRouteStrategy(t *testing.T) {
 	}
 }
 
+func TestPeerMonitorSelected(t *testing.T) {
+	cfg := routeselector.RouteSelectorConfig{
+		AverageSize:   4,
+		RouteStrategy: config.RouteStrategySpeed,
+		RerouteRatio:  1.1,
+		RerouteDiff:   10,
+	}
+	pm := New(&cfg, 1)
+	addr1 := generateIP(1)
+	addr2 := generateIP(2)
+
+	if pm.Selected() != nil {
+		t.Errorf("Selected path before any selection")
+	}
+
+	for _, ip := range []netip.Prefix{addr1, addr2} {
+		pm.AddNode("SYNTROPY_"+ip.Addr().String(), "PublicKey", ip, int(ip.Addr().AsSlice()[3]), false)
+	}
+	pm.peerList.Iterate(func(ip netip.Prefix, peer *peerlist.PeerInfo) {
+		peer.ResetFlags()
+		for i := 0; i < int(cfg.AverageSize); i++ {
+			peer.Add(100, 0)
+		}
+	})
+
+	pm.BestPath()
+	sel := pm.Selected()
+	if sel == nil || sel.Reason != "new" {
+		t.Fatalf("Selected path new route failed %+v", sel)
+	}
+
+	// Stable route must keep the reason of the last change
+	pm.BestPath()
+	sel2 := pm.Selected()
+	if sel2 == nil || sel2.Reason != "new" || sel2.Gateway != sel.Gateway {
+		t.Errorf("Selected path no change failed %+v", sel2)
+	}
+}
+
+func TestPeerMonitorPaths(t *testing.T) {
+	cfg := routeselector.RouteSelectorConfig{
+		AverageSize:              10,
+		RouteStrategy:            config.RouteStrategySpeed,
+		RerouteRatio:             1.1,
+		RerouteDiff:              10,
+		RouteDeleteLossThreshold: 0,
+		MultipathBand:            20,
+	}
+	pm := New(&cfg, 1)
+	addrs := []netip.Prefix{generateIP(1), generateIP(2), generateIP(3), generateIP(4)}
+	for i, ip := range addrs {
+		pm.AddNode("SYNTROPY_"+ip.Addr().String(), "PublicKey", ip, i+1, false)
+	}
+
+	fillStats := func(endpoint netip.Prefix, count int, latency, loss float32) {
+		peer, _ := pm.peerList.GetPeer(endpoint)
+		peer.ResetFlags()
+		for i := 0; i < count; i++ {
+			peer.Add(latency, loss)
+		}
+	}
+
+	fillStats(addrs[0], 10, 100, 0)
+	fillStats(addrs[1], 10, 110, 0.01) // score 120 - in band
+	fillStats(addrs[2], 10, 200, 0)    // out of band
+	fillStats(addrs[3], 5, 100, 0)     // statistics incomplete
+
+	paths := pm.Paths()
+	if len(paths) != 2 ||
+		paths[0].IP != addrs[0].Addr() || paths[0].ID != 1 || paths[0].Weight != 10 ||
+		paths[1].IP != addrs[1].Addr() || paths[1].ID != 2 || paths[1].Weight != 8 {
+		t.Errorf("Multipath paths failed %+v", paths)
+	}
+
+	// Degraded path leaves the set
+	fillStats(addrs[1], 10, 110, 0.1)
+	paths = pm.Paths()
+	if len(paths) != 1 || paths[0].IP != addrs[0].Addr() {
+		t.Errorf("Multipath degraded path failed %+v", paths)
+	}
+
+	// Paths with too high packet loss are not used
+	cfg.RouteDeleteLossThreshold = 5
+	fillStats(addrs[0], 10, 100, 0.1)
+	fillStats(addrs[3], 10, 100, 0)
+	paths = pm.Paths()
+	if len(paths) != 1 || paths[0].IP != addrs[3].Addr() {
+		t.Errorf("Multipath loss threshold failed %+v", paths)
+	}
+}
+
 func generateIP(i int) netip.Prefix {
 	ip := netip.MustParseAddr(fmt.Sprintf("10.10.10.%d", i))
 	return netip.PrefixFrom(ip, ip.BitLen())
@@ -6,4 +6,14 @@ type RouteSelectorConfig struct {
 	RerouteRatio             float32
 	RerouteDiff              float32
 	RouteDeleteLossThreshold float32
+	// Multipath quality band (in percents). Paths this much worse than the best one are still used.
+	MultipathBand float32
+	// Path costs (used by cost route strategy). Are set by controller.
+	ConnectionCosts map[int]float32
+	InterfaceCosts  map[string]float32
+}
+
+// Cost returns total path cost of connection on interface
+func (cfg *RouteSelectorConfig) Cost(ifname string, connID int) float32 {
+	return cfg.ConnectionCosts[connID] + cfg.InterfaceCosts[ifname]
 }
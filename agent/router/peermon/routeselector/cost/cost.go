@@ -0,0 +1,132 @@
+/**
+ * Route selection algorithm, oriented at keeping traffic on cheap paths.
+ * Path score combines latency, jitter, packet loss and path cost (set by controller).
+ * Path with the lowest score wins. Reroute thresholds are used as hysteresis,
+ * so traffic stays on a cheap path unless quality really degrades.
+ **/
+package cost
+
+import (
+	"net/netip"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/peerlist"
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector"
+)
+
+const (
+	// Unstable latency is penalised twice
+	jitterFactor = 2
+	// Packet loss is a fraction. So 1% of packet loss is as bad as 10ms latency
+	lossPenalty = 1000
+)
+
+type costRouteSelector struct {
+	config    *routeselector.RouteSelectorConfig
+	peerlist  *peerlist.PeerList
+	bestRoute netip.Prefix
+}
+
+func New(peerlist *peerlist.PeerList, cfg *routeselector.RouteSelectorConfig) routeselector.PathSelector {
+	return &costRouteSelector{
+		peerlist: peerlist,
+		config:   cfg,
+	}
+}
+
+func (crs *costRouteSelector) BestPath() *routeselector.SelectedRoute {
+	route := &routeselector.SelectedRoute{
+		ID: 0, // Invalidate last ID
+		// IP is empty value, so  IP.IsValid()==false means delete route
+		// Reason will be set bellow
+	}
+	if crs.peerlist.Count() == 0 {
+		crs.bestRoute = netip.Prefix{}
+		route.Reason = routeselector.NewReason(routeselector.ReasonRouteDelete, 0, 0)
+		return route
+	}
+
+	route.Reason = crs.calculate()
+
+	if crs.bestRoute.IsValid() {
+		peer, ok := crs.peerlist.GetPeer(crs.bestRoute)
+
+		if ok {
+			if crs.config.RouteDeleteLossThreshold > 0 &&
+				peer.Loss()*100 >= crs.config.RouteDeleteLossThreshold {
+				route.Reason = routeselector.NewReason(routeselector.ReasonRouteDelete, 0, 0)
+				return route
+			}
+
+			route.IP = crs.bestRoute.Addr()
+			route.ID = peer.ConnectionID
+		}
+	}
+
+	return route
+}
+
+// score is measured in milliseconds. Lower is better.
+func (crs *costRouteSelector) score(peer *peerlist.PeerInfo) float32 {
+	return peer.Latency() + jitterFactor*peer.Jitter() + lossPenalty*peer.Loss() +
+		crs.config.Cost(peer.Ifname, peer.ConnectionID)
+}
+
+// lowestScore searches path with the lowest score
+func (crs *costRouteSelector) lowestScore() (best netip.Prefix, bestScore float32) {
+	crs.peerlist.Iterate(func(ip netip.Prefix, peer *peerlist.PeerInfo) {
+		if !peer.Valid() {
+			return
+		}
+		score := crs.score(peer)
+		// Map iteration order is random. Compare addresses on equal scores to be stable.
+		if !best.IsValid() || score < bestScore ||
+			score == bestScore && ip.Addr().Less(best.Addr()) {
+			best = ip
+			bestScore = score
+		}
+	})
+	return best, bestScore
+}
+
+func (crs *costRouteSelector) calculate() *routeselector.RouteChangeReason {
+	newIp, newScore := crs.lowestScore()
+	newStats, ok := crs.peerlist.GetPeer(newIp)
+	// No path has any ping results yet
+	if !newIp.IsValid() || !ok {
+		return routeselector.NewReason(routeselector.ReasonNoChange, 0, 0)
+	}
+
+	prevStats, ok := crs.peerlist.GetPeer(crs.bestRoute)
+	if !crs.bestRoute.IsValid() || !ok {
+		// No previous best route yet - choose the best
+		crs.bestRoute = newIp
+		return routeselector.NewReason(routeselector.ReasonNewRoute, 0, newScore)
+	}
+
+	if newIp == crs.bestRoute {
+		return routeselector.NewReason(routeselector.ReasonNoChange, newScore, newScore)
+	}
+
+	prevScore := crs.score(prevStats)
+
+	// current path is not usable any more (e.g. marked for deletion)
+	if !prevStats.Valid() {
+		crs.bestRoute = newIp
+		return routeselector.NewReason(routeselector.ReasonScore, prevScore, newScore)
+	}
+
+	// cannot compare scores, if one does not have full statistics yet
+	if newStats.StatsIncomplete() {
+		return routeselector.NewReason(routeselector.ReasonNoChange, prevScore, prevScore)
+	}
+
+	// apply thresholds (hysteresis)
+	if prevScore >= newScore*crs.config.RerouteRatio &&
+		prevScore-newScore >= crs.config.RerouteDiff {
+		crs.bestRoute = newIp
+		return routeselector.NewReason(routeselector.ReasonScore, prevScore, newScore)
+	}
+
+	// No changes - stay with old value
+	return routeselector.NewReason(routeselector.ReasonNoChange, prevScore, prevScore)
+}
@@ -0,0 +1,125 @@
+package cost
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/peerlist"
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+)
+
+const pathsCount = 4
+
+type testEntry struct {
+	latency [pathsCount]float32
+	loss    [pathsCount]float32
+	cost    [pathsCount]float32
+	best    [pathsCount]int
+}
+
+func generateIP(i int) netip.Prefix {
+	ip := netip.MustParseAddr(fmt.Sprintf("1.1.1.%d", i+1))
+	return netip.PrefixFrom(ip, ip.BitLen())
+}
+
+func (crs *costRouteSelector) fillStats(index int, latency, loss float32) {
+	var ifname string
+	if index == 0 {
+		ifname = "SYNTROPY_PUBLIC"
+	} else {
+		ifname = fmt.Sprintf("SYNTROPY_SDN%d", index)
+	}
+
+	ip := generateIP(index)
+	crs.peerlist.AddPeer(ifname, "PublicKey", ip, index, false)
+
+	peer, ok := crs.peerlist.GetPeer(ip)
+	if ok {
+		peer.ResetFlags()
+		for i := 0; i < int(crs.config.AverageSize); i++ {
+			peer.Add(latency, loss)
+		}
+	}
+}
+
+func TestRouteSelectorCost(t *testing.T) {
+	cfg := routeselector.RouteSelectorConfig{
+		AverageSize:              10,
+		RouteStrategy:            config.RouteStrategyCost,
+		RerouteRatio:             1.1,
+		RerouteDiff:              20,
+		RouteDeleteLossThreshold: 0,
+	}
+
+	testData := []testEntry{
+		{
+			// no costs - the same as speed strategy
+			latency: [pathsCount]float32{20, 500, 300, 35},
+			best:    [pathsCount]int{0, 0, 0, 3},
+		},
+		{
+			// expensive public path is left only when others are much better
+			latency: [pathsCount]float32{20, 50, 60, 100},
+			cost:    [pathsCount]float32{100, 0, 0, 0},
+			best:    [pathsCount]int{1, 1, 2, 1},
+		},
+		{
+			// cheap path is kept, even if it is a little bit slower
+			latency: [pathsCount]float32{100, 90, 85, 82},
+			cost:    [pathsCount]float32{0, 10, 10, 10},
+			best:    [pathsCount]int{0, 1, 2, 3},
+		},
+		{
+			// packet loss makes cheap path unusable
+			latency: [pathsCount]float32{20, 50, 60, 100},
+			loss:    [pathsCount]float32{0.1, 0, 0, 0},
+			cost:    [pathsCount]float32{0, 20, 20, 20},
+			best:    [pathsCount]int{1, 1, 2, 1},
+		},
+	}
+
+	for testIndex, test := range testData {
+		cfg.ConnectionCosts = make(map[int]float32)
+		for i, cost := range test.cost {
+			cfg.ConnectionCosts[i] = cost
+		}
+
+		rs := New(peerlist.NewPeerList(cfg.AverageSize), &cfg)
+		crs := rs.(*costRouteSelector)
+
+		for i, latency := range test.latency {
+			crs.fillStats(i, latency, test.loss[i])
+		}
+
+		for j := 0; j < pathsCount; j++ {
+			crs.bestRoute = generateIP(j)
+
+			best := rs.BestPath()
+			if best.IP != generateIP(test.best[j]).Addr() {
+				t.Errorf("Cost route selector test %d/%d failed (%s vs %s)",
+					testIndex, j, best.IP, generateIP(test.best[j]))
+			}
+		}
+	}
+}
+
+func TestRouteSelectorInterfaceCost(t *testing.T) {
+	cfg := routeselector.RouteSelectorConfig{
+		AverageSize:    10,
+		RouteStrategy:  config.RouteStrategyCost,
+		RerouteRatio:   1,
+		InterfaceCosts: map[string]float32{"SYNTROPY_PUBLIC": 50},
+	}
+
+	rs := New(peerlist.NewPeerList(cfg.AverageSize), &cfg)
+	crs := rs.(*costRouteSelector)
+	crs.fillStats(0, 10, 0)
+	crs.fillStats(1, 40, 0)
+
+	best := rs.BestPath()
+	if best.IP != generateIP(1).Addr() {
+		t.Errorf("Interface cost was ignored (%s vs %s)", best.IP, generateIP(1))
+	}
+}
@@ -11,3 +11,16 @@ type SelectedRoute struct {
 type PathSelector interface {
 	BestPath() *SelectedRoute
 }
+
+// Path is one of several paths, used for multipath routing
+type Path struct {
+	IP     netip.Addr // path IP address
+	ID     int        // ConnectionID of the path
+	Weight int        // relative path weight. Better paths have higher weights
+}
+
+// MultiPathSelector selects all healthy paths, that can be used simultaneously
+type MultiPathSelector interface {
+	PathSelector
+	Paths() []Path
+}
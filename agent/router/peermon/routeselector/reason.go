@@ -8,6 +8,7 @@ const (
 	ReasonLoss
 	ReasonLatency
 	ReasonRouteDelete
+	ReasonScore
 )
 
 type RouteChangeReason struct {
@@ -42,11 +43,18 @@ func (rr *RouteChangeReason) Reason() string {
 		return "latency"
 	case ReasonRouteDelete:
 		return "delete"
+	case ReasonScore:
+		return "score"
 	default:
 		return "unknown"
 	}
 }
 
+// Changed returns false if route was not changed
+func (rr *RouteChangeReason) Changed() bool {
+	return rr != nil && rr.reason != ReasonNoChange
+}
+
 func (rr *RouteChangeReason) Value() float32 {
 	return rr.newval
 }
@@ -34,6 +34,41 @@ func New(w io.Writer) *Router {
 			RerouteRatio:             ratio,
 			RerouteDiff:              diff,
 			RouteDeleteLossThreshold: float32(config.GetRouteDeleteThreshold()),
+			MultipathBand:            float32(config.GetMultipathBand()),
 		},
 	}
 }
+
+// Reconfigure rereads route selection settings (e.g. after configuration reload).
+// New settings are taken into account on next best path calculation
+func (r *Router) Reconfigure() {
+	r.Lock()
+	defer r.Unlock()
+
+	diff, ratio := config.RerouteThresholds()
+	r.pmCfg.AverageSize = config.PeerCheckWindow()
+	r.pmCfg.RouteStrategy = config.GetRouteStrategy()
+	r.pmCfg.RerouteRatio = ratio
+	r.pmCfg.RerouteDiff = diff
+	r.pmCfg.RouteDeleteLossThreshold = float32(config.GetRouteDeleteThreshold())
+	r.pmCfg.MultipathBand = float32(config.GetMultipathBand())
+
+	for _, group := range r.routes {
+		group.peerMonitor.Reconfigure()
+	}
+}
+
+// SetPathCosts updates connections and interfaces costs, used by cost route strategy
+// New costs are taken into account on next best path calculation
+// nil map keeps previous costs
+func (r *Router) SetPathCosts(connections map[int]float32, interfaces map[string]float32) {
+	r.Lock()
+	defer r.Unlock()
+
+	if connections != nil {
+		r.pmCfg.ConnectionCosts = connections
+	}
+	if interfaces != nil {
+		r.pmCfg.InterfaceCosts = interfaces
+	}
+}
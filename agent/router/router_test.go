@@ -0,0 +1,24 @@
+package router
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSetPathCosts(t *testing.T) {
+	r := New(&bytes.Buffer{})
+
+	r.SetPathCosts(map[int]float32{1: 10}, map[string]float32{"SYNTROPY_PUBLIC": 5})
+	// Missing costs are kept
+	r.SetPathCosts(map[int]float32{2: 20}, nil)
+	if len(r.pmCfg.ConnectionCosts) != 1 || r.pmCfg.ConnectionCosts[2] != 20 ||
+		r.pmCfg.InterfaceCosts["SYNTROPY_PUBLIC"] != 5 {
+		t.Errorf("Connection costs update failed %v %v", r.pmCfg.ConnectionCosts, r.pmCfg.InterfaceCosts)
+	}
+
+	// Empty costs clear previous ones
+	r.SetPathCosts(nil, map[string]float32{})
+	if len(r.pmCfg.InterfaceCosts) != 0 || r.pmCfg.ConnectionCosts[2] != 20 {
+		t.Errorf("Interface costs update failed %v %v", r.pmCfg.ConnectionCosts, r.pmCfg.InterfaceCosts)
+	}
+}
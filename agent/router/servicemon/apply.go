@@ -5,6 +5,7 @@ import (
 	"net/netip"
 
 	"github.com/SyntropyNet/syntropy-agent/agent/peeradata"
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector"
 	"github.com/SyntropyNet/syntropy-agent/agent/routestatus"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 	"github.com/SyntropyNet/syntropy-agent/pkg/netcfg"
@@ -16,6 +17,7 @@ func (sm *ServiceMonitor) Apply() ([]*routestatus.Connection, []*peeradata.Entry
 	var deleteIPs []netip.Prefix
 
 	bestRoute := sm.routeMonitor.BestPath()
+	paths := sm.paths()
 
 	for ip, rl := range sm.routes {
 		if rl.Disabled() {
@@ -45,9 +47,9 @@ func (sm *ServiceMonitor) Apply() ([]*routestatus.Connection, []*peeradata.Entry
 			// If route is valid - apply it.
 			// Invalid IP means delete current route
 			if bestRoute.IP.IsValid() {
-				rl.mergeRoutes(ip, &bestRoute.IP)
+				rl.mergeRoutes(ip, &bestRoute.IP, paths)
 			} else {
-				rl.mergeRoutes(ip, nil)
+				rl.mergeRoutes(ip, nil, paths)
 			}
 		}
 
@@ -141,6 +143,18 @@ func (rl *routeList) clearRoute(destination netip.Prefix) error {
 
 	logger.Debug().Println(pkgName, "Apply/ClearRoute", destination)
 
+	if len(rl.hops) > 0 {
+		rl.hops = nil
+		for _, r := range rl.list {
+			r.ClearFlags(rfActive)
+		}
+		err := netcfg.RouteDelMultipath(&destination)
+		if err != nil {
+			logger.Error().Println(pkgName, destination, "multipath route delete error", err)
+		}
+		return nil
+	}
+
 	route := rl.GetActive()
 	if route == nil {
 		return nil
@@ -155,7 +169,7 @@ func (rl *routeList) clearRoute(destination netip.Prefix) error {
 	return nil
 }
 
-func (rl *routeList) mergeRoutes(destination netip.Prefix, newgw *netip.Addr) error {
+func (rl *routeList) mergeRoutes(destination netip.Prefix, newgw *netip.Addr, paths []routeselector.Path) error {
 	logger.Debug().Println(pkgName, "Apply/MergeRoute ", destination)
 
 	activeRoute := rl.GetActive()
@@ -183,6 +197,16 @@ func (rl *routeList) mergeRoutes(destination netip.Prefix, newgw *netip.Addr) er
 	rl.list = newList
 	rl.resetPending()
 
+	// Deleted paths leave multipath route too
+	wasMultipath := len(rl.hops) > 0
+	if rl.rerouteMultipath(destination, paths) {
+		return nil
+	}
+	if wasMultipath {
+		// Multipath route was reduced to a single path route
+		activeRoute = rl.GetActive()
+	}
+
 	// Reuse reroute function to do actual job
 	return rl.Reroute(newRoute, activeRoute, destination)
 }
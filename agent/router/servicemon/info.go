@@ -0,0 +1,28 @@
+package servicemon
+
+import (
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+)
+
+// Info returns services routes state. Used by control socket.
+// NOTE: it is called under main Router lock
+func (sm *ServiceMonitor) Info() []ctlapi.RouteService {
+	rv := []ctlapi.RouteService{}
+
+	for ip, rl := range sm.routes {
+		srv := ctlapi.RouteService{
+			Address:  ip.String(),
+			Disabled: rl.Disabled(),
+			Routes:   []string{},
+		}
+		if active := rl.GetActive(); active != nil {
+			srv.Active = active.gateway.String()
+		}
+		for _, re := range rl.list {
+			srv.Routes = append(srv.Routes, re.String())
+		}
+		rv = append(rv, srv)
+	}
+
+	return rv
+}
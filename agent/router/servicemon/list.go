@@ -17,6 +17,8 @@ type routeList struct {
 	list    []*routeEntry
 	flags   uint16
 	GroupID int
+	// installed multipath route paths. Empty when single path route is used
+	hops []multipathHop
 }
 
 func newRouteList(gid int, disabled bool) *routeList {
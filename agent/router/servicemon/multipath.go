@@ -0,0 +1,136 @@
+package servicemon
+
+import (
+	"net/netip"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/netcfg"
+)
+
+// One path of installed multipath route
+type multipathHop struct {
+	route  *routeEntry
+	weight int
+}
+
+// paths returns healthy paths for multipath routing.
+// nil means multipath routing is disabled.
+func (sm *ServiceMonitor) paths() []routeselector.Path {
+	if !sm.multipath {
+		return nil
+	}
+	mps, ok := sm.routeMonitor.(routeselector.MultiPathSelector)
+	if !ok {
+		return nil
+	}
+	return mps.Paths()
+}
+
+// nextHops converts paths to this service route entries.
+// Wireguard routes by peers allowed IPs, so only one path per interface makes sense.
+func (rl *routeList) nextHops(paths []routeselector.Path) []multipathHop {
+	hops := []multipathHop{}
+	index := make(map[string]int)
+
+	for _, path := range paths {
+		route := rl.Find(path.IP)
+		if route == nil || route.CheckFlag(rfPendingDel) {
+			continue
+		}
+		if idx, ok := index[route.ifname]; ok {
+			if hops[idx].weight < path.Weight {
+				hops[idx] = multipathHop{route: route, weight: path.Weight}
+			}
+			continue
+		}
+		index[route.ifname] = len(hops)
+		hops = append(hops, multipathHop{route: route, weight: path.Weight})
+	}
+
+	return hops
+}
+
+func sameHops(a, b []multipathHop) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].route != b[i].route || a[i].weight != b[i].weight {
+			return false
+		}
+	}
+	return true
+}
+
+// rerouteMultipath installs multipath route via all usable paths.
+// Returns false if less than 2 paths are usable (or multipath route failed)
+// and single path routing must be used instead.
+func (rl *routeList) rerouteMultipath(destination netip.Prefix, paths []routeselector.Path) bool {
+	hops := rl.nextHops(paths)
+	if len(hops) < 2 {
+		rl.leaveMultipath(destination)
+		return false
+	}
+
+	if sameHops(hops, rl.hops) {
+		// Nothing to change
+		return true
+	}
+
+	nexthops := make([]netcfg.NextHop, 0, len(hops))
+	for _, hop := range hops {
+		nexthops = append(nexthops, netcfg.NextHop{
+			Ifname: hop.route.ifname,
+			Weight: hop.weight,
+		})
+	}
+	logger.Debug().Println(pkgName, "multipath route", destination, nexthops)
+	err := netcfg.RouteReplaceMultipath(&destination, nexthops)
+	if err != nil {
+		logger.Error().Println(pkgName, "could not set multipath route to", destination, err)
+		rl.leaveMultipath(destination)
+		return false
+	}
+
+	// all paths are active now
+	for _, r := range rl.list {
+		r.ClearFlags(rfActive)
+	}
+	for _, hop := range hops {
+		hop.route.SetFlag(rfActive)
+	}
+	rl.hops = hops
+
+	return true
+}
+
+// leaveMultipath reduces multipath route to a single path route.
+// The first still present path is kept active. Single path reroute will change it, if needed.
+func (rl *routeList) leaveMultipath(destination netip.Prefix) {
+	if len(rl.hops) == 0 {
+		return
+	}
+	rl.hops = nil
+
+	active := rl.GetActive()
+	for _, r := range rl.list {
+		r.ClearFlags(rfActive)
+	}
+
+	if active == nil {
+		logger.Debug().Println(pkgName, "remove multipath route", destination)
+		err := netcfg.RouteDelMultipath(&destination)
+		if err != nil {
+			logger.Error().Println(pkgName, "could not remove multipath route to", destination, err)
+		}
+		return
+	}
+
+	logger.Debug().Println(pkgName, "multipath route", destination, "reduced to", active.ifname)
+	err := netcfg.RouteReplace(active.ifname, nil, &destination)
+	if err != nil {
+		logger.Error().Println(pkgName, "could not change routes to", destination, "via", active.ifname)
+	}
+	active.SetFlag(rfActive)
+}
@@ -33,12 +33,17 @@ func (sm *ServiceMonitor) Reroute(selroute *routeselector.SelectedRoute) (rv *pe
 		return
 	}
 
+	paths := sm.paths()
 	for dest, routeList := range sm.routes {
 		if routeList.Disabled() {
 			// No reroute on IP conflicting routes list
 			continue
 		}
 
+		if routeList.rerouteMultipath(dest, paths) {
+			continue
+		}
+
 		currRoute := routeList.GetActive()
 		var newRoute *routeEntry = nil
 		if selroute != nil && selroute.IP.IsValid() {
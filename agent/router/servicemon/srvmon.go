@@ -6,6 +6,7 @@ import (
 
 	"github.com/SyntropyNet/syntropy-agent/agent/common"
 	"github.com/SyntropyNet/syntropy-agent/agent/router/peermon/routeselector"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 )
 
@@ -13,6 +14,7 @@ const pkgName = "ServiceMonitor. "
 
 // ServiceMonitor monitors routes to configured services
 // Does rerouting when PathSelector.BestPath() changes
+// In multipath mode services are routed via all healthy paths (MultiPathSelector.Paths())
 // ServiceMonitor is explicitely used in Router and is always under main Router lock
 // So no need for locking here
 type ServiceMonitor struct {
@@ -20,6 +22,7 @@ type ServiceMonitor struct {
 	routeMonitor       routeselector.PathSelector
 	groupID            int
 	activeConnectionID int
+	multipath          bool
 }
 
 func New(ps routeselector.PathSelector, gid int) *ServiceMonitor {
@@ -28,6 +31,7 @@ func New(ps routeselector.PathSelector, gid int) *ServiceMonitor {
 		routeMonitor:       ps,
 		groupID:            gid,
 		activeConnectionID: 0,
+		multipath:          config.RouteMultipath(),
 	}
 }
 
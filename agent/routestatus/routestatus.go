@@ -14,9 +14,17 @@ const (
 	pkgName = "WgRouteStatus. "
 )
 
+// ApplyError describes failed configuration apply
+type ApplyError struct {
+	Stage      string `json:"stage"`
+	Message    string `json:"msg"`
+	RolledBack bool   `json:"rolled_back"`
+}
+
 type Message struct {
 	common.MessageHeader
-	Data []*Connection `json:"data"`
+	Data  []*Connection `json:"data"`
+	Error *ApplyError   `json:"error,omitempty"`
 }
 
 func New() *Message {
@@ -31,7 +39,7 @@ func New() *Message {
 
 // Send message to controller (writer)
 func (msg *Message) Send(w io.Writer) error {
-	if len(msg.Data) == 0 {
+	if len(msg.Data) == 0 && msg.Error == nil {
 		return nil
 	}
 
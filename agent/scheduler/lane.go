@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LaneStats is lane queue metrics. Wait times are for the period since previous report.
+type LaneStats struct {
+	Lane       string  `json:"lane"`
+	Queued     int     `json:"queued"`
+	MaxQueued  int     `json:"max_queued"`
+	Processed  uint64  `json:"processed"`
+	Superseded uint64  `json:"superseded"`
+	Busy       bool    `json:"busy"`
+	WaitAvg    float32 `json:"wait_avg_ms"`
+	WaitMax    float32 `json:"wait_max_ms"`
+}
+
+// lane is a FIFO queue, processed by a single worker
+type lane struct {
+	sync.Mutex
+	queue  []*message
+	signal chan struct{}
+	stats  LaneStats
+	// wait time statistics since previous report
+	waitTotal time.Duration
+	waitMax   time.Duration
+	waitCount int
+}
+
+func newLane(name string) *lane {
+	return &lane{
+		signal: make(chan struct{}, 1),
+		stats:  LaneStats{Lane: name},
+	}
+}
+
+// push queues message and removes pending messages of superseded types.
+// Returns dropped messages.
+func (l *lane) push(msg *message, supersedes []string) []*message {
+	l.Lock()
+	defer l.Unlock()
+
+	var dropped []*message
+	if len(supersedes) > 0 {
+		types := make(map[string]bool)
+		for _, t := range supersedes {
+			types[t] = true
+		}
+		kept := l.queue[:0]
+		for _, m := range l.queue {
+			if types[m.header.MsgType] && !m.header.DryRun {
+				dropped = append(dropped, m)
+			} else {
+				kept = append(kept, m)
+			}
+		}
+		l.queue = kept
+		l.stats.Superseded += uint64(len(dropped))
+	}
+
+	l.queue = append(l.queue, msg)
+	if len(l.queue) > l.stats.MaxQueued {
+		l.stats.MaxQueued = len(l.queue)
+	}
+
+	select {
+	case l.signal <- struct{}{}:
+	default:
+	}
+
+	return dropped
+}
+
+func (l *lane) pop() *message {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.queue) == 0 {
+		return nil
+	}
+	msg := l.queue[0]
+	l.queue[0] = nil
+	l.queue = l.queue[1:]
+
+	wait := time.Since(msg.queued)
+	l.waitTotal += wait
+	l.waitCount++
+	if wait > l.waitMax {
+		l.waitMax = wait
+	}
+	l.stats.Busy = true
+
+	return msg
+}
+
+func (l *lane) done() {
+	l.Lock()
+	defer l.Unlock()
+
+	l.stats.Processed++
+	l.stats.Busy = false
+}
+
+func (l *lane) run(ctx context.Context, exec func(raw []byte)) {
+	for {
+		msg := l.pop()
+		if msg == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.signal:
+			}
+			continue
+		}
+
+		exec(msg.raw)
+		l.done()
+	}
+}
+
+// takeStats returns lane statistics and resets wait time and max queue length statistics
+func (l *lane) takeStats() LaneStats {
+	l.Lock()
+	defer l.Unlock()
+
+	rv := l.stats
+	rv.Queued = len(l.queue)
+	if l.waitCount > 0 {
+		rv.WaitAvg = float32(l.waitTotal.Microseconds()) / float32(l.waitCount) / 1000
+		rv.WaitMax = float32(l.waitMax.Microseconds()) / 1000
+	}
+
+	l.waitTotal = 0
+	l.waitMax = 0
+	l.waitCount = 0
+	l.stats.MaxQueued = len(l.queue)
+
+	return rv
+}
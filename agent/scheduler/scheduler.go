@@ -0,0 +1,176 @@
+// scheduler package decouples controller messages receiving from processing them.
+// Messages are processed in separate lanes: read-only queries are not delayed by
+// long configuration changes or long running queries. Configuration lane keeps messages order, but drops
+// pending configurations, that are superseded by a newer full configuration.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+const (
+	cmd         = "COMMAND_QUEUE_STATS"
+	pkgName     = "Scheduler. "
+	statsPeriod = time.Minute
+)
+
+// Lanes
+const (
+	// Read-only queries (GET_INFO, AUTO_PING, etc)
+	LaneQuery = iota
+	// Mutating configuration commands. Order is preserved.
+	LaneConfig
+	// Long running read-only queries (SUPPORT_INFO_CTA, etc). They must not delay short queries.
+	LaneLongQuery
+	laneCount
+)
+
+var laneNames = [laneCount]string{"query", "config", "long_query"}
+
+// Header is the part of message, scheduler is interested in
+type Header struct {
+	common.MessageHeader
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Classifier returns message lane and message types, which this message supersedes
+type Classifier func(h *Header) (lane int, supersedes []string)
+
+type message struct {
+	header Header
+	raw    []byte
+	queued time.Time
+}
+
+type Scheduler struct {
+	writer     io.Writer
+	exec       func(raw []byte)
+	classify   Classifier
+	superseded func(msg, by common.MessageHeader)
+	lanes      [laneCount]*lane
+}
+
+// New creates scheduler. exec processes the message.
+// superseded is called for every dropped message.
+func New(w io.Writer, exec func(raw []byte), classify Classifier,
+	superseded func(msg, by common.MessageHeader)) *Scheduler {
+	s := &Scheduler{
+		writer:     w,
+		exec:       exec,
+		classify:   classify,
+		superseded: superseded,
+	}
+	for i := range s.lanes {
+		s.lanes[i] = newLane(laneNames[i])
+	}
+	return s
+}
+
+func (s *Scheduler) Name() string {
+	return cmd
+}
+
+// Push schedules received message. Never blocks.
+func (s *Scheduler) Push(raw []byte) {
+	msg := &message{
+		raw:    raw,
+		queued: time.Now(),
+	}
+	err := json.Unmarshal(raw, &msg.header)
+	if err != nil {
+		// processing will report the error
+		s.lanes[LaneQuery].push(msg, nil)
+		return
+	}
+
+	l, supersedes := s.classify(&msg.header)
+	if l < 0 || l >= laneCount {
+		l = LaneConfig
+	}
+	if msg.header.DryRun {
+		// dry-run does not apply configuration, thus cannot supersede anything
+		supersedes = nil
+	}
+
+	for _, dropped := range s.lanes[l].push(msg, supersedes) {
+		logger.Info().Println(pkgName, "Dropping", dropped.header.MsgType, dropped.header.ID,
+			"superseded by", msg.header.MsgType, msg.header.ID)
+		if s.superseded != nil {
+			s.superseded(dropped.header.MessageHeader, msg.header.MessageHeader)
+		}
+	}
+}
+
+// Run starts lanes workers and periodic statistics sending
+func (s *Scheduler) Run(ctx context.Context) error {
+	for _, l := range s.lanes {
+		go l.run(ctx, s.exec)
+	}
+
+	go func() {
+		ticker := time.NewTicker(statsPeriod)
+		defer ticker.Stop()
+		var lastProcessed uint64
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				stats := s.Stats()
+				var processed uint64
+				for _, ls := range stats {
+					processed += ls.Processed + ls.Superseded + uint64(ls.Queued)
+				}
+				// Nothing happened. Do not spam controller
+				if processed == lastProcessed {
+					continue
+				}
+				lastProcessed = processed
+				s.sendStats(stats)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stats returns lanes statistics. Wait time statistics are reset.
+func (s *Scheduler) Stats() []LaneStats {
+	rv := []LaneStats{}
+	for _, l := range s.lanes {
+		rv = append(rv, l.takeStats())
+	}
+	return rv
+}
+
+type statsMessage struct {
+	common.MessageHeader
+	Data []LaneStats `json:"data"`
+}
+
+func (s *Scheduler) sendStats(stats []LaneStats) {
+	msg := statsMessage{
+		MessageHeader: common.MessageHeader{
+			ID:      env.MessageDefaultID,
+			MsgType: cmd,
+		},
+		Data: stats,
+	}
+	msg.Now()
+
+	raw, err := json.Marshal(&msg)
+	if err != nil {
+		logger.Error().Println(pkgName, "stats json", err)
+		return
+	}
+	logger.Debug().Println(pkgName, "Sending: ", string(raw))
+	s.writer.Write(raw)
+}
@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+)
+
+func testClassifier(h *Header) (int, []string) {
+	switch h.MsgType {
+	case "GET_INFO":
+		return LaneQuery, nil
+	case "SUPPORT_INFO_CTA":
+		return LaneLongQuery, nil
+	case "CONFIG_INFO":
+		return LaneConfig, []string{"CONFIG_INFO", "WG_CONF"}
+	default:
+		return LaneConfig, nil
+	}
+}
+
+func testMessage(id, msgType string, dryRun bool) []byte {
+	return []byte(fmt.Sprintf(`{"id":"%s","type":"%s","dry_run":%t}`, id, msgType, dryRun))
+}
+
+func TestScheduler(t *testing.T) {
+	var mutex sync.Mutex
+	var processed, dropped []string
+	block := make(chan struct{})
+	started := make(chan struct{})
+	queryDone := make(chan struct{})
+
+	exec := func(raw []byte) {
+		switch {
+		case bytes.Contains(raw, []byte(`"id":"1"`)):
+			// Long configuration apply
+			close(started)
+			<-block
+		case bytes.Contains(raw, []byte(`"id":"7"`)):
+			// Long query
+			<-block
+		case bytes.Contains(raw, []byte(`"GET_INFO"`)):
+			defer close(queryDone)
+		}
+		mutex.Lock()
+		processed = append(processed, string(raw[7:8]))
+		mutex.Unlock()
+	}
+	superseded := func(msg, by common.MessageHeader) {
+		dropped = append(dropped, msg.ID+">"+by.ID)
+	}
+
+	var buf bytes.Buffer
+	s := New(&buf, exec, testClassifier, superseded)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Run(ctx)
+
+	s.Push(testMessage("1", "CONFIG_INFO", false))
+	<-started
+	s.Push(testMessage("2", "WG_CONF", false))
+	s.Push(testMessage("3", "CONFIG_INFO", true))
+	s.Push(testMessage("4", "SET_SETTINGS", false))
+	s.Push(testMessage("5", "CONFIG_INFO", false))
+	s.Push(testMessage("7", "SUPPORT_INFO_CTA", false))
+	s.Push(testMessage("6", "GET_INFO", false))
+
+	// Query must not wait for configuration or long query
+	select {
+	case <-queryDone:
+	case <-time.After(time.Second):
+		t.Fatal("Query is blocked by configuration")
+	}
+
+	if len(dropped) != 1 || dropped[0] != "2>5" {
+		t.Errorf("Invalid superseded messages %v", dropped)
+	}
+
+	close(block)
+	deadline := time.Now().Add(time.Second)
+	for {
+		mutex.Lock()
+		count := len(processed)
+		mutex.Unlock()
+		if count == 6 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Not all messages processed %v", processed)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Long query is processed in parallel to configuration, which keeps its order
+	configs := []string{}
+	for _, id := range processed {
+		if id != "6" && id != "7" {
+			configs = append(configs, id)
+		}
+	}
+	if processed[0] != "6" || fmt.Sprint(configs) != "[1 3 4 5]" {
+		t.Errorf("Invalid processing order %v", processed)
+	}
+
+	stats := s.Stats()
+	if stats[LaneConfig].Processed != 4 || stats[LaneConfig].Superseded != 1 ||
+		stats[LaneConfig].MaxQueued != 3 || stats[LaneQuery].Processed != 1 || stats[LaneLongQuery].Processed != 1 {
+		t.Errorf("Invalid statistics %+v", stats)
+	}
+}
@@ -24,3 +24,12 @@ func (a *Agent) stopServices() {
 	logger.Info().Printf("%s Stopping services.\n", pkgName)
 	a.cancel()
 }
+
+// Returns names of all running services
+func (a *Agent) serviceNames() []string {
+	rv := []string{}
+	for _, s := range a.services {
+		rv = append(rv, s.Name())
+	}
+	return rv
+}
@@ -2,22 +2,42 @@ package settings
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
 
 	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/agent/router"
 	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 )
 
 type setSettings struct {
+	writer io.Writer
+	router *router.Router
+	// pushes changed settings to components
+	apply func()
 }
 
-func New() common.Command {
-	return &setSettings{}
+func New(w io.Writer, r *router.Router, apply func()) common.Command {
+	return &setSettings{
+		writer: w,
+		router: r,
+		apply:  apply,
+	}
 }
 
 func (s *setSettings) Name() string {
 	return cmd
 }
 
+// Version 2 supports all runtime settings and replies with values in effect
+func (s *setSettings) Version() int {
+	return 2
+}
+
 func (s *setSettings) Exec(raw []byte) error {
 	var req settingsMessage
 	err := json.Unmarshal(raw, &req)
@@ -25,6 +45,124 @@ func (s *setSettings) Exec(raw []byte) error {
 		return err
 	}
 
-	config.SetRerouteThresholds(req.Data.Thresholds.Diff, req.Data.Thresholds.Ratio)
+	// Every setting is validated and applied separately.
+	// Invalid settings are reported as failed items, other settings are applied.
+	failures := &common.PartialError{}
+	data := &req.Data
+
+	if data.Thresholds != nil {
+		failures.Add("rerouting_threshold", config.SetRerouteThresholds(data.Thresholds.Diff, data.Thresholds.Ratio))
+	}
+	if data.RouteStrategy != nil {
+		failures.Add("route_strategy", config.SetRouteStrategy(*data.RouteStrategy))
+	}
+	if data.PeerCheckTime != nil {
+		failures.Add("peer_check_time", config.SetPeerCheckTime(*data.PeerCheckTime))
+	}
+	if data.PeerCheckWindow != nil {
+		failures.Add("peer_check_window", config.SetPeerCheckWindow(*data.PeerCheckWindow))
+	}
+	if data.RouteDeleteThreshold != nil {
+		failures.Add("route_delete_threshold", config.SetRouteDeleteThreshold(*data.RouteDeleteThreshold))
+	}
+	if data.LogLevel != nil {
+		failures.Add("log_level", config.SetDebugLevel(*data.LogLevel))
+	}
+	if data.ExporterPort != nil {
+		failures.Add("exporter_port", config.SetMetricsExporterPort(*data.ExporterPort))
+	}
+	if data.ServicesStatus != nil {
+		config.SetServicesStatus(*data.ServicesStatus)
+		failures.Add("services_status", nil)
+	}
+	if data.Tags != nil {
+		failures.Add("tags", config.SetAgentTags(data.Tags))
+	}
+	if data.Location != nil {
+		failures.Add("location", config.SetLocation(data.Location.Latitude, data.Location.Longitude))
+	}
+
+	// Costs are optional. Keep previous costs, if controller does not send them.
+	var connections map[int]float32
+	if data.ConnectionCosts != nil {
+		connections = make(map[int]float32)
+		for _, e := range data.ConnectionCosts {
+			err := validateCost(e.Cost)
+			failures.Add(fmt.Sprintf("connection_costs[%d]", e.ConnectionID), err)
+			if err == nil {
+				connections[e.ConnectionID] = e.Cost
+			}
+		}
+		logger.Info().Println(pkgName, "Connection costs updated:", len(connections))
+	}
+	var interfaces map[string]float32
+	if data.InterfaceCosts != nil {
+		interfaces = make(map[string]float32)
+		for _, e := range data.InterfaceCosts {
+			ifname := e.IfName
+			if !strings.HasPrefix(ifname, env.InterfaceNamePrefix) {
+				ifname = env.InterfaceNamePrefix + ifname
+			}
+			err := validateCost(e.Cost)
+			failures.Add("interface_costs["+e.IfName+"]", err)
+			if err == nil {
+				interfaces[ifname] = e.Cost
+			}
+		}
+		logger.Info().Println(pkgName, "Interface costs updated:", len(interfaces))
+	}
+	s.router.SetPathCosts(connections, interfaces)
+
+	for _, f := range failures.Failures {
+		logger.Warning().Println(pkgName, "Invalid setting", f.Item, ":", f.Error)
+	}
+
+	if s.apply != nil {
+		s.apply()
+	}
+	s.reply(req.ID)
+
+	return failures.Err()
+}
+
+// validateCost rejects costs, that cannot be added to a route score
+func validateCost(cost float32) error {
+	if math.IsNaN(float64(cost)) || cost < 0 {
+		return fmt.Errorf("invalid cost %v", cost)
+	}
 	return nil
 }
+
+// reply confirms settings values in effect
+func (s *setSettings) reply(id string) {
+	resp := settingsReply{
+		MessageHeader: common.MessageHeader{
+			ID:      id,
+			MsgType: cmd,
+		},
+	}
+	resp.Now()
+
+	diff, ratio := config.RerouteThresholds()
+	resp.Data.Thresholds = thresholdsEntry{Diff: diff, Ratio: ratio}
+	resp.Data.RouteStrategy = config.RouteStrategyName(config.GetRouteStrategy())
+	resp.Data.PeerCheckTime = uint(config.PeerCheckTime().Seconds())
+	resp.Data.PeerCheckWindow = config.PeerCheckWindow()
+	resp.Data.RouteDeleteThreshold = config.GetRouteDeleteThreshold()
+	resp.Data.LogLevel = logger.LevelName(config.GetDebugLevel())
+	resp.Data.ExporterPort = config.MetricsExporterPort()
+	resp.Data.ServicesStatus = config.GetServicesStatus()
+	resp.Data.Tags = config.GetAgentTags()
+	resp.Data.Location = locationEntry{
+		Latitude:  config.GetLocationLatitude(),
+		Longitude: config.GetLocationLongitude(),
+	}
+
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		logger.Error().Println(pkgName, "reply json", err)
+		return
+	}
+	logger.Message().Println(pkgName, "Sending: ", string(arr))
+	s.writer.Write(arr)
+}
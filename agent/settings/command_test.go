@@ -0,0 +1,111 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+	"github.com/SyntropyNet/syntropy-agent/agent/router"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+)
+
+func TestSetSettings(t *testing.T) {
+	var buf bytes.Buffer
+	applied := false
+	s := New(&buf, router.New(&buf), func() { applied = true })
+	buf.Reset()
+
+	err := s.Exec([]byte(`{"id":"7","type":"SET_SETTINGS","data":{
+		"route_strategy":"dr", "peer_check_time":70, "peer_check_window":12,
+		"log_level":"debug", "exporter_port":9100, "tags":["a","b"],
+		"location":{"latitude":54.5,"longitude":25.3}}}`))
+
+	var partial *common.PartialError
+	if !errors.As(err, &partial) || partial.Total != 7 || len(partial.Failures) != 1 ||
+		partial.Failures[0].Item != "peer_check_time" {
+		t.Errorf("Invalid settings result %v", err)
+	}
+	if !applied {
+		t.Errorf("Settings were not applied")
+	}
+
+	var reply settingsReply
+	err = json.Unmarshal(buf.Bytes(), &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.ID != "7" || reply.Data.RouteStrategy != "dr" || reply.Data.PeerCheckWindow != 12 ||
+		reply.Data.LogLevel != "DEBUG" || reply.Data.ExporterPort != 9100 || len(reply.Data.Tags) != 2 ||
+		reply.Data.Location.Latitude != 54.5 {
+		t.Errorf("Invalid reply %+v", reply.Data)
+	}
+	if reply.Data.PeerCheckTime != uint(config.PeerCheckTime().Seconds()) {
+		t.Errorf("Invalid setting must not be changed")
+	}
+}
+
+func TestSetSettingsCosts(t *testing.T) {
+	var buf bytes.Buffer
+	s := New(&buf, router.New(&buf), nil)
+
+	err := s.Exec([]byte(`{"id":"8","type":"SET_SETTINGS","data":{
+		"connection_costs":[{"connection_id":1,"cost":10},{"connection_id":2,"cost":-1}],
+		"interface_costs":[{"ifname":"PUBLIC","cost":0},{"ifname":"SDN1","cost":-0.5}]}}`))
+
+	var partial *common.PartialError
+	if !errors.As(err, &partial) || partial.Total != 4 || len(partial.Failures) != 2 ||
+		partial.Failures[0].Item != "connection_costs[2]" || partial.Failures[1].Item != "interface_costs[SDN1]" {
+		t.Errorf("Invalid costs result %v", err)
+	}
+}
+
+func TestValidateCost(t *testing.T) {
+	for _, cost := range []float32{-1, float32(math.NaN())} {
+		if validateCost(cost) == nil {
+			t.Errorf("Cost %v must be rejected", cost)
+		}
+	}
+	for _, cost := range []float32{0, 1.5} {
+		if validateCost(cost) != nil {
+			t.Errorf("Cost %v must be accepted", cost)
+		}
+	}
+}
+
+func TestSetSettingsThresholds(t *testing.T) {
+	tests := []struct {
+		diff  float32
+		ratio float32
+		valid bool
+	}{
+		{0, 1, true},
+		{15, 1.2, true},
+		{-0.1, 1.2, false},
+		{15, 0.9, false},
+		{15, 0, false},
+	}
+
+	var buf bytes.Buffer
+	s := New(&buf, router.New(&buf), nil)
+	for _, tt := range tests {
+		config.SetRerouteThresholds(10, 1.1)
+		msg := fmt.Sprintf(`{"id":"9","type":"SET_SETTINGS","data":{
+			"rerouting_threshold":{"latency_diff":%v,"latency_ratio":%v}}}`, tt.diff, tt.ratio)
+		err := s.Exec([]byte(msg))
+		if (err == nil) != tt.valid {
+			t.Errorf("Thresholds %v/%v result %v", tt.diff, tt.ratio, err)
+		}
+
+		diff, ratio := config.RerouteThresholds()
+		switch {
+		case tt.valid && (diff != tt.diff || ratio != tt.ratio):
+			t.Errorf("Thresholds %v/%v were not set", tt.diff, tt.ratio)
+		case !tt.valid && (diff != 10 || ratio != 1.1):
+			t.Errorf("Invalid thresholds %v/%v must not be set", tt.diff, tt.ratio)
+		}
+	}
+}
@@ -12,9 +12,59 @@ type thresholdsEntry struct {
 	Ratio float32 `json:"latency_ratio"`
 }
 
+// Path cost is expressed in milliseconds of latency
+type connectionCostEntry struct {
+	ConnectionID int     `json:"connection_id"`
+	Cost         float32 `json:"cost"`
+}
+
+type interfaceCostEntry struct {
+	IfName string  `json:"ifname"`
+	Cost   float32 `json:"cost"`
+}
+
+type locationEntry struct {
+	Latitude  float32 `json:"latitude"`
+	Longitude float32 `json:"longitude"`
+}
+
+// All settings are optional. Missing settings are not changed.
 type settingsMessage struct {
 	common.MessageHeader
 	Data struct {
-		Thresholds thresholdsEntry `json:"rerouting_threshold"`
+		Thresholds      *thresholdsEntry      `json:"rerouting_threshold,omitempty"`
+		ConnectionCosts []connectionCostEntry `json:"connection_costs,omitempty"`
+		InterfaceCosts  []interfaceCostEntry  `json:"interface_costs,omitempty"`
+		// speed, dr or cost
+		RouteStrategy *string `json:"route_strategy,omitempty"`
+		// Peers ping period (seconds) and moving average window (ping periods)
+		PeerCheckTime   *uint `json:"peer_check_time,omitempty"`
+		PeerCheckWindow *uint `json:"peer_check_window,omitempty"`
+		// Packet loss (percents), when route is deleted. 0 - never delete.
+		RouteDeleteThreshold *uint `json:"route_delete_threshold,omitempty"`
+		// DEBUG, MESSAGE, INFO, WARNING, ERROR
+		LogLevel *string `json:"log_level,omitempty"`
+		// Prometheus exporter port. 0 - disabled.
+		ExporterPort   *uint          `json:"exporter_port,omitempty"`
+		ServicesStatus *bool          `json:"services_status,omitempty"`
+		Tags           []string       `json:"tags,omitempty"`
+		Location       *locationEntry `json:"location,omitempty"`
+	} `json:"data"`
+}
+
+// Reply confirms settings values in effect
+type settingsReply struct {
+	common.MessageHeader
+	Data struct {
+		Thresholds           thresholdsEntry `json:"rerouting_threshold"`
+		RouteStrategy        string          `json:"route_strategy"`
+		PeerCheckTime        uint            `json:"peer_check_time"`
+		PeerCheckWindow      uint            `json:"peer_check_window"`
+		RouteDeleteThreshold uint            `json:"route_delete_threshold"`
+		LogLevel             string          `json:"log_level"`
+		ExporterPort         uint16          `json:"exporter_port"`
+		ServicesStatus       bool            `json:"services_status"`
+		Tags                 []string        `json:"tags"`
+		Location             locationEntry   `json:"location"`
 	} `json:"data"`
 }
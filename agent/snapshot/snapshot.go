@@ -0,0 +1,327 @@
+// snapshot package persists the last applied controller configuration on disk.
+// On agent restart (while controller is unreachable) it is replayed,
+// so the previous network setup is restored without waiting for a fresh CONFIG_INFO.
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/swireguard"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+const (
+	pkgName = "Snapshot. "
+	// Increase this value on every incompatible payload change
+	snapshotVersion = 1
+	snapshotPath    = env.AgentConfigDir + "/snapshot.json"
+	// Limits WG_CONF entries kept on top of CONFIG_INFO.
+	// Past it snapshot is dropped until the next CONFIG_INFO (which is a full configuration anyway)
+	maxWgConfEntries = 1000
+)
+
+var ErrNoSnapshot = errors.New("no snapshot")
+
+// Payload is the snapshoted configuration
+type Payload struct {
+	Timestamp time.Time `json:"timestamp"`
+	// The last successfully applied CONFIG_INFO message
+	ConfigInfo json.RawMessage `json:"config_info"`
+	// WG_CONF messages, received after CONFIG_INFO
+	WgConf []json.RawMessage `json:"wg_conf,omitempty"`
+	// Wireguard interfaces private keys (by interface name)
+	// Without them peers would not accept recreated (after reboot) interfaces
+	Keys map[string]string `json:"keys,omitempty"`
+	// Peers preshared keys (by swireguard.PeerKey).
+	// Controller messages do not contain agent generated ones.
+	PresharedKeys map[string]string `json:"preshared_keys,omitempty"`
+}
+
+// on disk file format
+type snapshotFile struct {
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum"` // sha256 of payload
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// Store keeps snapshot in memory and syncs it to disk
+// A nil *Store is valid and does nothing (snapshots disabled)
+type Store struct {
+	sync.Mutex
+	path    string
+	payload *Payload
+}
+
+func New() *Store {
+	return &Store{
+		path: snapshotPath,
+	}
+}
+
+// Load reads and validates snapshot from disk
+func (s *Store) Load() (*Payload, error) {
+	if s == nil {
+		return nil, ErrNoSnapshot
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSnapshot
+		}
+		return nil, err
+	}
+
+	var file snapshotFile
+	err = json.Unmarshal(raw, &file)
+	if err != nil {
+		return nil, fmt.Errorf("corrupted snapshot: %s", err)
+	}
+	if file.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", file.Version)
+	}
+	if checksum(file.Payload) != file.Checksum {
+		return nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+
+	payload := &Payload{}
+	err = json.Unmarshal(file.Payload, payload)
+	if err != nil {
+		return nil, fmt.Errorf("corrupted snapshot payload: %s", err)
+	}
+	if len(payload.ConfigInfo) == 0 {
+		return nil, ErrNoSnapshot
+	}
+
+	s.payload = payload
+	return payload, nil
+}
+
+// SetConfigInfo supersedes the snapshot with a newer CONFIG_INFO
+// Previous WG_CONF deltas are dropped, because new CONFIG_INFO is a full configuration
+func (s *Store) SetConfigInfo(data json.RawMessage, keys map[string]string) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	s.payload = &Payload{
+		Timestamp:  time.Now(),
+		ConfigInfo: data,
+		Keys:       keys,
+	}
+	s.save()
+}
+
+// AddWgConf appends WG_CONF delta to the snapshot.
+// Older entries of the same peers are dropped, only the latest add/remove of a peer matters.
+func (s *Store) AddWgConf(data json.RawMessage) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	if s.payload == nil {
+		// No base CONFIG_INFO - delta is useless
+		return
+	}
+
+	_, entries, err := parseWgConf(data)
+	if err != nil {
+		logger.Error().Println(pkgName, "WG_CONF parse", err)
+		return
+	}
+	peers := make(map[string]bool)
+	for _, e := range entries {
+		if key := peerEntryKey(e); key != "" {
+			peers[key] = true
+		}
+	}
+
+	deltas := []json.RawMessage{}
+	count := len(entries)
+	for _, delta := range s.payload.WgConf {
+		delta, n, err := dropPeerEntries(delta, peers)
+		if err != nil {
+			logger.Error().Println(pkgName, "WG_CONF compact", err)
+			return
+		}
+		if n > 0 {
+			deltas = append(deltas, delta)
+			count += n
+		}
+	}
+	if len(entries) > 0 {
+		deltas = append(deltas, data)
+	}
+
+	if count > maxWgConfEntries {
+		logger.Warning().Println(pkgName, "too many WG_CONF entries", count, "snapshot is dropped until next CONFIG_INFO")
+		s.remove()
+		return
+	}
+
+	s.payload.Timestamp = time.Now()
+	s.payload.WgConf = deltas
+	s.save()
+}
+
+// AddPresharedKeys keeps preshared keys of configured peers.
+// Agent reuses them, when peers are recreated after reboot.
+// Keys are replaced with the next CONFIG_INFO, which is followed by keys of its peers.
+func (s *Store) AddPresharedKeys(psks map[string]string) {
+	if s == nil || len(psks) == 0 {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	if s.payload == nil {
+		return
+	}
+	if s.payload.PresharedKeys == nil {
+		s.payload.PresharedKeys = make(map[string]string)
+	}
+	for k, v := range psks {
+		s.payload.PresharedKeys[k] = v
+	}
+	s.save()
+}
+
+// Remove deletes snapshot (e.g. agent was deleted from controller)
+func (s *Store) Remove() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	s.remove()
+}
+
+func (s *Store) remove() {
+	s.payload = nil
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Error().Println(pkgName, "remove", err)
+	}
+}
+
+func (s *Store) save() {
+	payload, err := json.Marshal(s.payload)
+	if err != nil {
+		logger.Error().Println(pkgName, "json marshal", err)
+		return
+	}
+
+	raw, err := json.Marshal(snapshotFile{
+		Version:  snapshotVersion,
+		Checksum: checksum(payload),
+		Payload:  payload,
+	})
+	if err != nil {
+		logger.Error().Println(pkgName, "json marshal", err)
+		return
+	}
+
+	// Write to temporary file and rename it.
+	// This way a crash during write will not corrupt the previous snapshot
+	tmpPath := s.path + ".tmp"
+	err = os.WriteFile(tmpPath, raw, 0600)
+	if err == nil {
+		err = os.Rename(tmpPath, s.path)
+	}
+	if err != nil {
+		logger.Error().Println(pkgName, "save", err)
+		return
+	}
+	logger.Debug().Println(pkgName, "saved. WG_CONF deltas:", len(s.payload.WgConf))
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseWgConf splits WG_CONF message into fields and data entries
+func parseWgConf(raw json.RawMessage) (map[string]json.RawMessage, []json.RawMessage, error) {
+	msg := make(map[string]json.RawMessage)
+	err := json.Unmarshal(raw, &msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := []json.RawMessage{}
+	if data, ok := msg["data"]; ok {
+		err = json.Unmarshal(data, &entries)
+	}
+	return msg, entries, err
+}
+
+// dropPeerEntries removes add/remove entries of peers from WG_CONF message
+// Returns updated message and count of entries left in it
+func dropPeerEntries(raw json.RawMessage, peers map[string]bool) (json.RawMessage, int, error) {
+	msg, entries, err := parseWgConf(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	kept := []json.RawMessage{}
+	for _, e := range entries {
+		if !peers[peerEntryKey(e)] {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(entries) {
+		return raw, len(kept), nil
+	}
+
+	msg["data"], err = json.Marshal(kept)
+	if err != nil {
+		return nil, 0, err
+	}
+	raw, err = json.Marshal(msg)
+	return raw, len(kept), err
+}
+
+// peerEntryKey returns swireguard.PeerKey for add_peer/remove_peer entries and empty string for others
+func peerEntryKey(raw json.RawMessage) string {
+	var entry struct {
+		Function string `json:"fn"`
+		Args     struct {
+			IfName    string `json:"ifname"`
+			PublicKey string `json:"public_key"`
+		} `json:"args"`
+	}
+	if json.Unmarshal(raw, &entry) != nil || entry.Args.PublicKey == "" {
+		return ""
+	}
+	switch entry.Function {
+	case "add_peer", "remove_peer":
+		return swireguard.PeerKey(entry.Args.IfName, entry.Args.PublicKey)
+	}
+	return ""
+}
+
+// InterfaceKeys collects private keys of configured wireguard interfaces
+func InterfaceKeys(devices []*swireguard.InterfaceInfo) map[string]string {
+	keys := make(map[string]string)
+	for _, dev := range devices {
+		if key := dev.PrivateKey(); key != "" {
+			keys[dev.IfName] = key
+		}
+	}
+	return keys
+}
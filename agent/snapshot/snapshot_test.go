@@ -0,0 +1,83 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/swireguard"
+)
+
+func TestSnapshot(t *testing.T) {
+	s := &Store{path: filepath.Join(t.TempDir(), "snapshot.json")}
+
+	if _, err := s.Load(); err != ErrNoSnapshot {
+		t.Fatalf("Missing snapshot load: %v", err)
+	}
+
+	// Deltas without base config are ignored
+	s.AddWgConf([]byte(`{"id":"0"}`))
+	if _, err := os.Stat(s.path); !os.IsNotExist(err) {
+		t.Fatalf("Snapshot without CONFIG_INFO was saved")
+	}
+
+	s.SetConfigInfo([]byte(`{"id":"1"}`), map[string]string{"SYNTROPY_PUBLIC": "key"})
+	s.AddWgConf([]byte(`{"id":"2","data":[{"fn":"add_peer","args":{"ifname":"SYNTROPY_PUBLIC","public_key":"a"}}]}`))
+	s.AddWgConf([]byte(`{"id":"3","data":[{"fn":"add_peer","args":{"ifname":"SYNTROPY_PUBLIC","public_key":"b"}}]}`))
+	s.AddPresharedKeys(map[string]string{swireguard.PeerKey("SYNTROPY_PUBLIC", "peer"): "psk"})
+
+	p, err := (&Store{path: s.path}).Load()
+	if err != nil {
+		t.Fatalf("Snapshot load: %s", err)
+	}
+	if string(p.ConfigInfo) != `{"id":"1"}` || len(p.WgConf) != 2 ||
+		p.Keys["SYNTROPY_PUBLIC"] != "key" || p.PresharedKeys[swireguard.PeerKey("SYNTROPY_PUBLIC", "peer")] != "psk" {
+		t.Errorf("Snapshot content mismatch %+v", p)
+	}
+
+	// Only the latest entry of a peer is kept, emptied deltas are dropped
+	s.AddWgConf([]byte(`{"id":"6","data":[{"fn":"remove_peer","args":{"ifname":"SYNTROPY_PUBLIC","public_key":"a"}}]}`))
+	p, err = (&Store{path: s.path}).Load()
+	if err != nil || len(p.WgConf) != 2 ||
+		string(p.WgConf[0]) != `{"id":"3","data":[{"fn":"add_peer","args":{"ifname":"SYNTROPY_PUBLIC","public_key":"b"}}]}` {
+		t.Errorf("Snapshot compact failed %+v %v", p, err)
+	}
+
+	// Newer CONFIG_INFO supersedes deltas
+	s.SetConfigInfo([]byte(`{"id":"4"}`), nil)
+	p, err = (&Store{path: s.path}).Load()
+	if err != nil || string(p.ConfigInfo) != `{"id":"4"}` || len(p.WgConf) != 0 || len(p.PresharedKeys) != 0 {
+		t.Errorf("Snapshot supersede failed %+v %v", p, err)
+	}
+
+	// Corrupted payload must be rejected
+	raw, _ := os.ReadFile(s.path)
+	os.WriteFile(s.path, bytes.Replace(raw, []byte(`{"id":"4"}`), []byte(`{"id":"5"}`), 1), 0600)
+	if _, err = (&Store{path: s.path}).Load(); err == nil {
+		t.Errorf("Corrupted snapshot was accepted")
+	}
+
+	// Too many deltas drop the snapshot
+	s.SetConfigInfo([]byte(`{"id":"7"}`), nil)
+	for i := 0; i <= maxWgConfEntries; i++ {
+		s.AddWgConf([]byte(fmt.Sprintf(`{"data":[{"fn":"add_peer","args":{"ifname":"SYNTROPY_PUBLIC","public_key":"%d"}}]}`, i)))
+	}
+	if _, err = s.Load(); err != ErrNoSnapshot {
+		t.Errorf("Oversized snapshot load: %v", err)
+	}
+
+	s.SetConfigInfo([]byte(`{"id":"8"}`), nil)
+	s.Remove()
+	if _, err = s.Load(); err != ErrNoSnapshot {
+		t.Errorf("Removed snapshot load: %v", err)
+	}
+
+	// nil store is valid and does nothing
+	var nilStore *Store
+	nilStore.SetConfigInfo([]byte(`{}`), nil)
+	nilStore.AddWgConf([]byte(`{}`))
+	nilStore.AddPresharedKeys(map[string]string{"a": "b"})
+	nilStore.Remove()
+}
@@ -41,6 +41,15 @@ func (obj *supportInfo) Name() string {
 	return cmd
 }
 
+func (obj *supportInfo) ReadOnly() bool {
+	return true
+}
+
+// Support info runs shell commands and may take long
+func (obj *supportInfo) LongRunning() bool {
+	return true
+}
+
 func (obj *supportInfo) getSupportInfoEntries() []*common.KeyValue {
 	var entries []*common.KeyValue
 
@@ -68,6 +68,7 @@ func (wg *Wireguard) peerCacheAdd(pi *PeerInfo) {
 	for _, p := range dev.peers {
 		// PublicKey should be unique per peer
 		if p.PublicKey == pi.PublicKey {
+			p.PresharedKey = pi.PresharedKey
 			return
 		}
 	}
@@ -4,26 +4,37 @@ import (
 	"fmt"
 	"os/exec"
 
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 	"github.com/vishvananda/netlink"
 )
 
 func (wg *Wireguard) deleteInterface(ifname string) error {
+	// Closing embedded userspace device also removes its TUN interface
+	if wg.userspace.remove(ifname) {
+		return nil
+	}
+
 	iface, err := netlink.LinkByName(ifname)
 	if err != nil {
 		return fmt.Errorf("failed to lookup interface %s", ifname)
 	}
 
-	// ip link del dev <interface> works for both - kernel and userspace WG implementations.
+	// ip link del dev <interface> works for both - kernel and external userspace WG implementations.
 	return netlink.LinkDel(iface)
 }
 
 func (wg *Wireguard) createInterface(ifname string) error {
+	if config.GetWireguardMode() == config.WireguardUserspace {
+		return wg.userspace.create(ifname)
+	}
+
 	// XXX vishvananda netlink package is not (yet) capable of creating wireguard interface type
 	err := exec.Command("ip", "link", "add", "dev", ifname, "type", "wireguard").Run()
-	if err != nil {
-		logger.Warning().Println(pkgName, "Could not create kernel wireguard interface: ", err)
-		err = exec.Command("wireguard-go", ifname).Run()
+	if err != nil && config.GetWireguardMode() == config.WireguardAuto {
+		logger.Warning().Println(pkgName, "Could not create kernel wireguard interface: ", err,
+			". Falling back to embedded userspace implementation.")
+		err = wg.userspace.create(ifname)
 	}
 	return err
 }
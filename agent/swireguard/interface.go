@@ -14,7 +14,9 @@ type InterfaceInfo struct {
 	privateKey string
 	IP         netip.Addr
 	Port       int
-	peers      []*PeerInfo
+	// Wireguard implementation in use: kernel or userspace
+	Implementation string
+	peers          []*PeerInfo
 }
 
 func (ii *InterfaceInfo) Peers() []*PeerInfo {
@@ -23,6 +25,29 @@ func (ii *InterfaceInfo) Peers() []*PeerInfo {
 	return rv
 }
 
+// PrivateKey returns interface private key (it is known only after interface is created)
+func (ii *InterfaceInfo) PrivateKey() string {
+	return ii.privateKey
+}
+
+// SetPrivateKey presets private key, which will be used when creating a missing interface
+// (e.g. when restoring previous configuration after reboot)
+func (ii *InterfaceInfo) SetPrivateKey(key string) {
+	ii.privateKey = key
+}
+
+// Clone returns a deep copy of interface (including private key and peers)
+func (ii *InterfaceInfo) Clone() *InterfaceInfo {
+	rv := *ii
+	rv.peers = make([]*PeerInfo, 0, len(ii.peers))
+	for _, p := range ii.peers {
+		peer := *p
+		peer.AllowedIPs = append([]netip.Prefix{}, p.AllowedIPs...)
+		rv.peers = append(rv.peers, &peer)
+	}
+	return &rv
+}
+
 // Remove all peers
 func (ii *InterfaceInfo) flushPeers() {
 	ii.peers = ii.peers[:0]
@@ -71,9 +96,20 @@ func (wg *Wireguard) CreateInterface(ii *InterfaceInfo) error {
 		if err != nil {
 			return fmt.Errorf("create wg interface failed: %s", err.Error())
 		}
-		privKey, err = wgtypes.GeneratePrivateKey()
-		if err != nil {
-			return fmt.Errorf("generate private key error: %s", err.Error())
+		if newDev, err := wg.wgc.Device(ii.IfName); err == nil {
+			logger.Info().Println(pkgName, "Created", ii.IfName, "using", implementationName(newDev.Type), "wireguard")
+		}
+		if ii.privateKey != "" {
+			privKey, err = wgtypes.ParseKey(ii.privateKey)
+			if err != nil {
+				logger.Warning().Println(pkgName, "invalid preset private key for", ii.IfName, err)
+			}
+		}
+		if ii.privateKey == "" || err != nil {
+			privKey, err = wgtypes.GeneratePrivateKey()
+			if err != nil {
+				return fmt.Errorf("generate private key error: %s", err.Error())
+			}
 		}
 		port = findFreePort(ii.Port)
 	} else {
@@ -123,12 +159,14 @@ func (wg *Wireguard) CheckInterface(ii *InterfaceInfo) error {
 		myDev.Port = osDev.ListenPort
 		myDev.privateKey = osDev.PrivateKey.String()
 		myDev.PublicKey = osDev.PublicKey.String()
+		myDev.Implementation = implementationName(osDev.Type)
 	} else {
 		logger.Error().Println(pkgName, "interfaces cache is broken for", ii.IfName)
 	}
 
 	ii.Port = osDev.ListenPort
 	ii.PublicKey = osDev.PublicKey.String()
+	ii.Implementation = implementationName(osDev.Type)
 	return nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 	"pault.ag/go/modprobe"
 )
@@ -39,7 +40,7 @@ func loadKernelModule() {
 	defer func() {
 		if err := recover(); err != nil {
 			// I intentionally do not print error message here.
-			// Because I did a recover and will fallback to userspace WG implementation
+			// Because I did a recover and will fallback to embedded userspace WG implementation
 			// And those error messages usuly sound very scary and may insult users.
 			logger.Error().Println(pkgName, "error loading kernel module (an OS reboot may be required)")
 		}
@@ -52,9 +53,14 @@ func loadKernelModule() {
 }
 
 func (wg *Wireguard) LogInfo() {
-	if isKernelModuleLoaded() {
+	switch {
+	case config.GetWireguardMode() == config.WireguardUserspace:
+		logger.Info().Println(pkgName, "Using embedded userspace Wireguard implementation")
+	case isKernelModuleLoaded():
 		logger.Info().Println(pkgName, "Using Wireguard implementation in-kernel")
-	} else {
-		logger.Info().Println(pkgName, "Using userspace Wireguard implementation `wireguard-go`")
+	case config.GetWireguardMode() == config.WireguardKernel:
+		logger.Error().Println(pkgName, "Wireguard kernel module is not loaded. Interfaces will not be created.")
+	default:
+		logger.Info().Println(pkgName, "Wireguard kernel module is not loaded. Using embedded userspace implementation")
 	}
 }
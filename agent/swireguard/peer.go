@@ -1,6 +1,8 @@
 package swireguard
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/netip"
@@ -22,9 +24,109 @@ type PeerInfo struct {
 	Port         int
 	Gateway      netip.Addr
 	AllowedIPs   []netip.Prefix
+	// Optional base64 encoded preshared key. Empty - no preshared key.
+	// NOTE: is a secret, same as private key. Never log it.
+	PresharedKey string
 	Stats        PeerStats
 }
 
+// presharedKey parses preshared key. Zero key means preshared key is not used.
+func (pi *PeerInfo) presharedKey() (wgtypes.Key, error) {
+	if pi.PresharedKey == "" {
+		return wgtypes.Key{}, nil
+	}
+	key, err := wgtypes.ParseKey(pi.PresharedKey)
+	if err != nil {
+		// Do not leak key value to error message
+		return key, fmt.Errorf("invalid preshared key for peer %s", pi.PublicKey)
+	}
+	return key, nil
+}
+
+// PresharedKeyID returns short preshared key fingerprint, which is safe to show.
+// Empty if preshared key is not used.
+func (pi *PeerInfo) PresharedKeyID() string {
+	if pi.PresharedKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(pi.PresharedKey))
+	return hex.EncodeToString(sum[:4])
+}
+
+// GeneratePresharedKey generates a new random preshared key
+func GeneratePresharedKey() (string, error) {
+	key, err := wgtypes.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	return key.String(), nil
+}
+
+// PeerKey is a preshared keys map key
+func PeerKey(ifname, publicKey string) string {
+	return ifname + " " + publicKey
+}
+
+// PresharedKeys collects preshared keys of configured peers (by PeerKey)
+func PresharedKeys(devices []*InterfaceInfo) map[string]string {
+	psks := make(map[string]string)
+	for _, dev := range devices {
+		for _, peer := range dev.Peers() {
+			if peer.PresharedKey != "" {
+				psks[PeerKey(peer.IfName, peer.PublicKey)] = peer.PresharedKey
+			}
+		}
+	}
+	return psks
+}
+
+// PresetPresharedKey sets preshared key of a peer, that asked agent to generate it.
+// Key, already used by peer, is kept. Changing it would break the tunnel,
+// until controller delivers the new key to the other side.
+// Otherwise known key (e.g. restored from snapshot) is used.
+// New key is generated only if there is none or controller requested rotation,
+// and only when generate is allowed (it is not in dry-run and snapshot restore).
+// Returns true, if a new key was generated and must be reported to controller.
+func (wg *Wireguard) PresetPresharedKey(pi *PeerInfo, known map[string]string, generate, rotate bool) (bool, error) {
+	if pi.PresharedKey != "" {
+		// delivered by controller
+		return false, nil
+	}
+	return presetPresharedKey(pi, wg.peerPresharedKey(pi.IfName, pi.PublicKey), known, generate, rotate)
+}
+
+func presetPresharedKey(pi *PeerInfo, current string, known map[string]string, generate, rotate bool) (bool, error) {
+	pi.PresharedKey = current
+	if pi.PresharedKey == "" {
+		pi.PresharedKey = known[PeerKey(pi.IfName, pi.PublicKey)]
+	}
+	if !generate || pi.PresharedKey != "" && !rotate {
+		return false, nil
+	}
+
+	psk, err := GeneratePresharedKey()
+	if err != nil {
+		return false, err
+	}
+	pi.PresharedKey = psk
+	return true, nil
+}
+
+// peerPresharedKey returns preshared key, configured on wireguard device.
+// Cache cannot be used, because it is flushed by full configuration (CONFIG_INFO).
+func (wg *Wireguard) peerPresharedKey(ifname, publicKey string) string {
+	dev, err := wg.wgc.Device(ifname)
+	if err != nil {
+		return ""
+	}
+	for _, peer := range dev.Peers {
+		if peer.PublicKey.String() == publicKey && peer.PresharedKey != (wgtypes.Key{}) {
+			return peer.PresharedKey.String()
+		}
+	}
+	return ""
+}
+
 // Structure conversion helper
 func (pi *PeerInfo) asPeerConfig() (*wgtypes.PeerConfig, error) {
 	var err error
@@ -34,6 +136,12 @@ func (pi *PeerInfo) asPeerConfig() (*wgtypes.PeerConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Always set preshared key. Zero key removes previously configured one.
+	psk, err := pi.presharedKey()
+	if err != nil {
+		return nil, err
+	}
+	pcfg.PresharedKey = &psk
 	if pi.IP.IsValid() && pi.Port > 0 {
 		pcfg.Endpoint = &net.UDPAddr{
 			IP:   pi.IP.AsSlice(),
@@ -78,6 +186,7 @@ func (wg *Wireguard) AddPeer(pi *PeerInfo) error {
 	}
 
 	// Add peer to cache
+	// Existing peer is updated in place (e.g. preshared key rotation) and its sessions are kept.
 	wg.peerCacheAdd(pi)
 
 	return nil
@@ -130,6 +239,15 @@ func (wg *Wireguard) applyPeers(ii *InterfaceInfo) (allowedIPs []netip.Prefix, e
 		for _, osPeer := range dev.Peers {
 			if myPeer.PublicKey == osPeer.PublicKey.String() {
 				addPeer = false
+				// update changed preshared key, without touching other peer settings
+				psk, err := myPeer.presharedKey()
+				if err == nil && psk != osPeer.PresharedKey {
+					wgconf.Peers = append(wgconf.Peers, wgtypes.PeerConfig{
+						PublicKey:    osPeer.PublicKey,
+						UpdateOnly:   true,
+						PresharedKey: &psk,
+					})
+				}
 				break
 			}
 		}
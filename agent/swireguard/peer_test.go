@@ -0,0 +1,62 @@
+package swireguard
+
+import (
+	"testing"
+)
+
+func TestPresetPresharedKey(t *testing.T) {
+	known := map[string]string{PeerKey("SYNTROPY_PUBLIC", "known"): "known-psk"}
+
+	tests := []struct {
+		name      string
+		publicKey string
+		current   string
+		generate  bool
+		rotate    bool
+		generated bool
+		psk       string // expected key, empty means random
+	}{
+		{"peer key is reused", "peer", "current-psk", true, false, false, "current-psk"},
+		{"known key is reused", "known", "", true, false, false, "known-psk"},
+		{"new peer", "peer", "", true, false, true, ""},
+		{"rotation", "peer", "current-psk", true, true, true, ""},
+		{"dry-run rotation", "peer", "current-psk", false, true, false, "current-psk"},
+		{"dry-run new peer", "peer", "", false, false, false, ""},
+	}
+
+	for _, tt := range tests {
+		pi := &PeerInfo{IfName: "SYNTROPY_PUBLIC", PublicKey: tt.publicKey}
+		generated, err := presetPresharedKey(pi, tt.current, known, tt.generate, tt.rotate)
+		if err != nil || generated != tt.generated {
+			t.Errorf("%s: generated %v, error %v", tt.name, generated, err)
+		}
+		switch {
+		case tt.generated && (pi.PresharedKey == "" || pi.PresharedKey == tt.current):
+			t.Errorf("%s: key was not generated", tt.name)
+		case !tt.generated && pi.PresharedKey != tt.psk:
+			t.Errorf("%s: invalid key %s", tt.name, pi.PresharedKey)
+		}
+	}
+}
+
+func TestUpdateAgentConfigSync(t *testing.T) {
+	dev := &InterfaceInfo{IfName: "SYNTROPY_PUBLIC", PublicKey: "dev", Port: 1000}
+	dev.peers = []*PeerInfo{
+		{IfName: "SYNTROPY_PUBLIC", PublicKey: "applied", PresharedKey: "new"},
+		{IfName: "SYNTROPY_PUBLIC", PublicKey: "rolledback", PresharedKey: "old"},
+	}
+
+	msg := NewUpdateAgentConfigMsg("1")
+	msg.AddInterface(&InterfaceInfo{IfName: "SYNTROPY_PUBLIC", PublicKey: "wanted", Port: 2000})
+	msg.AddInterface(&InterfaceInfo{IfName: "SYNTROPY_SDN1"})
+	msg.AddPresharedKey(&PeerInfo{IfName: "SYNTROPY_PUBLIC", PublicKey: "applied", PresharedKey: "new"})
+	msg.AddPresharedKey(&PeerInfo{IfName: "SYNTROPY_PUBLIC", PublicKey: "rolledback", PresharedKey: "new"})
+	msg.AddPresharedKey(&PeerInfo{IfName: "SYNTROPY_PUBLIC", PublicKey: "removed", PresharedKey: "new"})
+	msg.Sync([]*InterfaceInfo{dev})
+
+	if len(msg.Data) != 2 ||
+		msg.Data[0].Function != "create_interface" || msg.Data[0].Data.PublicKey != "dev" || msg.Data[0].Data.Port != 1000 ||
+		msg.Data[1].Function != "set_preshared_key" || msg.Data[1].Data.PublicKey != "applied" {
+		t.Errorf("Invalid synced message %+v", msg.Data)
+	}
+}
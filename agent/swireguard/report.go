@@ -0,0 +1,104 @@
+package swireguard
+
+import (
+	"github.com/SyntropyNet/syntropy-agent/agent/common"
+)
+
+// UPDATE_AGENT_CONFIG reports agent side configuration to controller:
+// created interfaces (their keys and ports) and agent generated preshared keys.
+// Is sent by both CONFIG_INFO and WG_CONF.
+const cmdUpdateAgentConfig = "UPDATE_AGENT_CONFIG"
+
+type UpdateAgentConfigEntry struct {
+	Function string `json:"fn"`
+	Data     struct {
+		IfName    string `json:"ifname"`
+		PublicKey string `json:"public_key"`
+		IP        string `json:"internal_ip"`
+		Port      int    `json:"listen_port"`
+		// Wireguard implementation (kernel or userspace). Is reported for interfaces only.
+		Implementation string `json:"implementation,omitempty"`
+		// Agent generated preshared key. Is reported for set_preshared_key only.
+		PresharedKey string `json:"preshared_key,omitempty"`
+	} `json:"data"`
+}
+
+type UpdateAgentConfigMsg struct {
+	common.MessageHeader
+	Data []UpdateAgentConfigEntry `json:"data"`
+}
+
+// NewUpdateAgentConfigMsg creates a reply to controller message with id
+func NewUpdateAgentConfigMsg(id string) *UpdateAgentConfigMsg {
+	return &UpdateAgentConfigMsg{
+		MessageHeader: common.MessageHeader{
+			ID:      id,
+			MsgType: cmdUpdateAgentConfig,
+		},
+		Data: []UpdateAgentConfigEntry{},
+	}
+}
+
+func (msg *UpdateAgentConfigMsg) AddInterface(data *InterfaceInfo) {
+	e := UpdateAgentConfigEntry{Function: "create_interface"}
+	e.Data.IfName = data.IfName
+	e.Data.IP = data.IP.String()
+	e.Data.PublicKey = data.PublicKey
+	e.Data.Port = data.Port
+	e.Data.Implementation = data.Implementation
+
+	msg.Data = append(msg.Data, e)
+}
+
+func (msg *UpdateAgentConfigMsg) AddPeer(data *PeerInfo) {
+	e := UpdateAgentConfigEntry{Function: "add_peer"}
+	e.Data.IfName = data.IfName
+	e.Data.IP = data.IP.String()
+	e.Data.PublicKey = data.PublicKey
+	e.Data.Port = data.Port
+
+	msg.Data = append(msg.Data, e)
+}
+
+// AddPresharedKey reports agent generated preshared key.
+// Controller delivers it to the other side of connection.
+func (msg *UpdateAgentConfigMsg) AddPresharedKey(data *PeerInfo) {
+	e := UpdateAgentConfigEntry{Function: "set_preshared_key"}
+	e.Data.IfName = data.IfName
+	e.Data.PublicKey = data.PublicKey
+	e.Data.PresharedKey = data.PresharedKey
+
+	msg.Data = append(msg.Data, e)
+}
+
+// Sync updates reported interfaces and preshared keys with the applied configuration.
+// Entries of interfaces and peers, that are not configured (e.g. after rollback), are dropped.
+func (msg *UpdateAgentConfigMsg) Sync(devices []*InterfaceInfo) {
+	devs := make(map[string]*InterfaceInfo)
+	for _, dev := range devices {
+		devs[dev.IfName] = dev
+	}
+	psks := PresharedKeys(devices)
+
+	entries := []UpdateAgentConfigEntry{}
+	for _, e := range msg.Data {
+		switch e.Function {
+		case "create_interface":
+			dev, ok := devs[e.Data.IfName]
+			if !ok {
+				continue
+			}
+			e.Data.IP = dev.IP.String()
+			e.Data.PublicKey = dev.PublicKey
+			e.Data.Port = dev.Port
+			e.Data.Implementation = dev.Implementation
+		case "set_preshared_key":
+			psk, ok := psks[PeerKey(e.Data.IfName, e.Data.PublicKey)]
+			if !ok || psk != e.Data.PresharedKey {
+				continue
+			}
+		}
+		entries = append(entries, e)
+	}
+	msg.Data = entries
+}
@@ -0,0 +1,142 @@
+package swireguard
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/ipc"
+	"golang.zx2c4.com/wireguard/tun"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Wireguard implementation names, reported to controller
+const (
+	ImplementationKernel    = "kernel"
+	ImplementationUserspace = "userspace"
+)
+
+func implementationName(t wgtypes.DeviceType) string {
+	if t == wgtypes.Userspace {
+		return ImplementationUserspace
+	}
+	return ImplementationKernel
+}
+
+// userspaceDevice is an in-process wireguard device (same code as `wireguard-go`).
+// It is configured via UAPI socket (/var/run/wireguard/<ifname>.sock),
+// so wgctrl client works with it the same way as with kernel interfaces.
+// NOTE: device lives as long as agent process does.
+type userspaceDevice struct {
+	device *device.Device
+	uapi   net.Listener
+}
+
+func newUserspaceDevice(ifname string) (*userspaceDevice, error) {
+	mtu := int(config.GetInterfaceMTU())
+	if mtu == 0 {
+		mtu = device.DefaultMTU
+	}
+
+	tunDev, err := tun.CreateTUN(ifname, mtu)
+	if err != nil {
+		return nil, fmt.Errorf("create TUN %s: %s", ifname, err)
+	}
+
+	uapiFile, err := ipc.UAPIOpen(ifname)
+	if err != nil {
+		tunDev.Close()
+		return nil, fmt.Errorf("open UAPI %s: %s", ifname, err)
+	}
+
+	prefix := pkgName + ifname + " "
+	wgLogger := &device.Logger{
+		Verbosef: func(format string, args ...interface{}) {
+			logger.Debug().Printf(prefix+format+"\n", args...)
+		},
+		Errorf: func(format string, args ...interface{}) {
+			logger.Error().Printf(prefix+format+"\n", args...)
+		},
+	}
+	// NOTE: device takes ownership of TUN and closes it together with device
+	dev := device.NewDevice(tunDev, conn.NewDefaultBind(), wgLogger)
+
+	uapi, err := ipc.UAPIListen(ifname, uapiFile)
+	if err != nil {
+		uapiFile.Close()
+		dev.Close()
+		return nil, fmt.Errorf("listen UAPI %s: %s", ifname, err)
+	}
+
+	go func() {
+		for {
+			c, err := uapi.Accept()
+			if err != nil {
+				// listener was closed
+				return
+			}
+			go dev.IpcHandle(c)
+		}
+	}()
+
+	return &userspaceDevice{
+		device: dev,
+		uapi:   uapi,
+	}, nil
+}
+
+// Close removes UAPI socket and TUN interface
+func (ud *userspaceDevice) Close() {
+	ud.uapi.Close()
+	ud.device.Close()
+}
+
+// userspaceDevices keeps embedded devices, created by agent.
+// It has its own lock, because interfaces are deleted while holding wireguard cache lock.
+type userspaceDevices struct {
+	sync.Mutex
+	devices map[string]*userspaceDevice
+}
+
+func (ud *userspaceDevices) create(ifname string) error {
+	dev, err := newUserspaceDevice(ifname)
+	if err != nil {
+		return err
+	}
+
+	ud.Lock()
+	defer ud.Unlock()
+	if ud.devices == nil {
+		ud.devices = make(map[string]*userspaceDevice)
+	}
+	ud.devices[ifname] = dev
+	return nil
+}
+
+// remove closes userspace device. Returns false, if ifname is not an embedded userspace device.
+func (ud *userspaceDevices) remove(ifname string) bool {
+	ud.Lock()
+	defer ud.Unlock()
+
+	dev, ok := ud.devices[ifname]
+	if !ok {
+		return false
+	}
+	dev.Close()
+	delete(ud.devices, ifname)
+	return true
+}
+
+func (ud *userspaceDevices) close() {
+	ud.Lock()
+	defer ud.Unlock()
+
+	for name, dev := range ud.devices {
+		dev.Close()
+		delete(ud.devices, name)
+	}
+}
@@ -27,6 +27,8 @@ type Wireguard struct {
 	// NOTE: caching wireguard setup may sound like an overhead at first.
 	// But in future we may need to add checking/syncing/recreating delete interfaces
 	devices []*InterfaceInfo
+	// embedded userspace wireguard devices
+	userspace userspaceDevices
 }
 
 // New creates new instance of Wireguard configurer and monitor
@@ -41,7 +43,9 @@ func New() (*Wireguard, error) {
 		RemoveNonSyntropyInterfaces: false,
 	}
 
-	loadKernelModule()
+	if config.GetWireguardMode() != config.WireguardUserspace {
+		loadKernelModule()
+	}
 
 	return &wg, nil
 }
@@ -65,6 +69,8 @@ func (wg *Wireguard) Close() error {
 			wg.RemoveInterface(dev)
 		}
 	}
+	// Embedded userspace interfaces cannot outlive agent process
+	wg.userspace.close()
 
 	return wg.wgc.Close()
 }
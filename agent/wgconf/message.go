@@ -30,8 +30,16 @@ type wgConfEntry struct {
 		PublicKey    string   `json:"public_key,omitempty"`
 		AllowedIPs   []string `json:"allowed_ips,omitempty"`
 		GatewayIPv4  string   `json:"gw_ipv4,omitempty"`
+		GatewayIPv6  string   `json:"gw_ipv6,omitempty"`
 		EndpointIPv4 string   `json:"endpoint_ipv4,omitempty"`
+		EndpointIPv6 string   `json:"endpoint_ipv6,omitempty"`
 		EndpointPort int      `json:"endpoint_port,omitempty"`
+		// Optional preshared key. If missing and generation is requested,
+		// agent reuses peer's key or generates a new one and reports it back to controller.
+		PresharedKey         string `json:"preshared_key,omitempty"`
+		GeneratePresharedKey bool   `json:"preshared_key_generate,omitempty"`
+		// Generate a new key, even if peer already has one
+		RotatePresharedKey bool `json:"preshared_key_rotate,omitempty"`
 	}
 	Metadata struct {
 		// Interface configuration
@@ -47,7 +55,8 @@ type wgConfEntry struct {
 	}
 }
 
-func (e *wgConfEntry) asPeerInfo() (*swireguard.PeerInfo, error) {
+// families are host reachable IP families, used to select one of dual-stack addresses
+func (e *wgConfEntry) asPeerInfo(families common.AddrFamilies) (*swireguard.PeerInfo, error) {
 	var ifname string
 	if strings.HasPrefix(e.Args.IfName, env.InterfaceNamePrefix) {
 		ifname = e.Args.IfName
@@ -62,12 +71,13 @@ func (e *wgConfEntry) asPeerInfo() (*swireguard.PeerInfo, error) {
 		GroupID:      e.Metadata.GroupID,
 		AgentID:      e.Metadata.AgentID,
 		Port:         e.Args.EndpointPort,
+		PresharedKey: e.Args.PresharedKey,
 	}
 
 	// These values may be absent on peer delete messages. Ignore errors.
 	// Don't worry about values - they will be taken from cache
-	pi.IP, _ = netip.ParseAddr(e.Args.EndpointIPv4)
-	pi.Gateway, _ = netip.ParseAddr(e.Args.GatewayIPv4)
+	pi.IP = families.ParseDualStackAddr(e.Args.EndpointIPv4, e.Args.EndpointIPv6)
+	pi.Gateway = families.ParseDualStackAddr(e.Args.GatewayIPv4, e.Args.GatewayIPv6)
 
 	for _, ipStr := range e.Args.AllowedIPs {
 		aip, err := netip.ParsePrefix(ipStr)
@@ -105,5 +115,7 @@ func (e *wgConfEntry) asNetworkPath() (*common.SdnNetworkPath, error) {
 
 type wgConfMsg struct {
 	common.MessageHeader
-	Data []wgConfEntry `json:"data"`
+	// Only plan configuration changes, do not apply them
+	DryRun bool          `json:"dry_run,omitempty"`
+	Data   []wgConfEntry `json:"data"`
 }
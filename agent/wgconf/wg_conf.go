@@ -2,10 +2,15 @@ package wgconf
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 
 	"github.com/SyntropyNet/syntropy-agent/agent/common"
 	"github.com/SyntropyNet/syntropy-agent/agent/mole"
+	"github.com/SyntropyNet/syntropy-agent/agent/snapshot"
+	"github.com/SyntropyNet/syntropy-agent/agent/swireguard"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 )
 
@@ -15,21 +20,68 @@ const (
 )
 
 type wgConf struct {
-	writer io.Writer
-	mole   *mole.Mole
+	writer   io.Writer
+	mole     *mole.Mole
+	snapshot *snapshot.Store
+	// peers preshared keys, used only when restoring from snapshot
+	restore bool
+	psks    map[string]string
 }
 
-func New(w io.Writer, m *mole.Mole) common.Command {
+func New(w io.Writer, m *mole.Mole, s *snapshot.Store) common.Command {
 	return &wgConf{
-		writer: w,
-		mole:   m,
+		writer:   w,
+		mole:     m,
+		snapshot: s,
 	}
 }
 
+// Restore replays WG_CONF message from snapshot.
+// mole.Apply() is left for the caller.
+func Restore(m *mole.Mole, raw []byte, psks map[string]string) error {
+	obj := &wgConf{
+		mole:    m,
+		restore: true,
+		psks:    psks,
+	}
+
+	var req wgConfMsg
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		return err
+	}
+
+	obj.configure(m, &req, &swireguard.UpdateAgentConfigMsg{})
+	return nil
+}
+
+// Plan calculates changes WG_CONF message would do, without applying them
+func (obj *wgConf) Plan(raw []byte) (*ctlapi.Plan, error) {
+	var req wgConfMsg
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.plan(&req), nil
+}
+
+func (obj *wgConf) plan(req *wgConfMsg) *ctlapi.Plan {
+	planner := obj.mole.NewPlanner()
+	obj.configure(planner, req, &swireguard.UpdateAgentConfigMsg{})
+	return planner.Plan(cmd)
+}
+
 func (obj *wgConf) Name() string {
 	return cmd
 }
 
+// Version 2 supports dry_run (plan) flag
+// Version 3 supports peers preshared keys (delivered or generated)
+func (obj *wgConf) Version() int {
+	return 3
+}
+
 func (obj *wgConf) Exec(raw []byte) error {
 	var req wgConfMsg
 	err := json.Unmarshal(raw, &req)
@@ -37,52 +89,122 @@ func (obj *wgConf) Exec(raw []byte) error {
 		return err
 	}
 
+	if req.DryRun || config.DryRun() {
+		logger.Info().Println(pkgName, "Dry-run. Configuration is not applied.")
+		arr, err := json.Marshal(common.NewPlanResponse(req.ID, obj.plan(&req)))
+		if err != nil {
+			return err
+		}
+		logger.Message().Println(pkgName, "Sending: ", string(arr))
+		obj.writer.Write(arr)
+		return nil
+	}
+
+	resp := swireguard.NewUpdateAgentConfigMsg(req.ID)
+
+	tx := obj.mole.Begin()
+	failures := obj.configure(obj.mole, &req, resp)
+
+	// sync and merge everything between controller and OS
+	commitErr := obj.mole.Commit(tx)
+
+	// Generated preshared keys are delivered to other peers by controller.
+	// Keys of rolled back peers are not reported.
+	resp.Sync(obj.mole.Wireguard().Devices())
+	if len(resp.Data) > 0 {
+		resp.Now()
+		arr, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		logger.Message().Println(pkgName, "Sending: ", string(arr))
+		obj.writer.Write(arr)
+	}
+
+	if commitErr != nil {
+		return commitErr
+	}
+
+	// Keep applied changes for cold start
+	obj.snapshot.AddWgConf(raw)
+	obj.snapshot.AddPresharedKeys(swireguard.PresharedKeys(obj.mole.Wireguard().Devices()))
+
+	// Changes are applied, but some peers may have failed
+	return failures.Err()
+}
+
+// configure adds and removes peers. Returns failed peers.
+// Generated preshared keys are added to resp.
+// target is either mole or dry-run planner
+func (obj *wgConf) configure(target mole.Configurer, req *wgConfMsg, resp *swireguard.UpdateAgentConfigMsg) *common.PartialError {
+	failures := &common.PartialError{}
+	// Preshared keys are never generated in dry-run (plan would show a random change)
+	// and when restoring (nobody would deliver a new key to the other side)
+	_, planning := target.(*mole.Planner)
+	generate := !planning && !obj.restore
 	addPeerCount := 0
 	delPeerCount := 0
+	families := common.ReachableFamilies()
 	for _, cmd := range req.Data {
+		item := fmt.Sprintf("%s %s connection %d", cmd.Function, cmd.Args.IfName, cmd.Metadata.ConnectionID)
 		switch cmd.Function {
 		case "add_peer":
-			pi, err := cmd.asPeerInfo()
+			pi, err := cmd.asPeerInfo(families)
 			if err != nil {
 				logger.Warning().Println(pkgName, err)
+				failures.Add(item, err)
 				continue
 			}
 			netpath, err := cmd.asNetworkPath()
 			if err != nil {
 				logger.Warning().Println(pkgName, err)
+				failures.Add(item, err)
 				continue
 			}
-			err = obj.mole.AddPeer(pi, netpath)
+			generated := false
+			if cmd.Args.GeneratePresharedKey {
+				generated, err = obj.mole.Wireguard().PresetPresharedKey(pi, obj.psks,
+					generate, cmd.Args.RotatePresharedKey)
+				if err != nil {
+					logger.Error().Println(pkgName, "generate preshared key", err)
+					failures.Add(item, err)
+					continue
+				}
+			}
+			err = target.AddPeer(pi, netpath)
 			if err == nil {
 				addPeerCount++
+				if generated {
+					resp.AddPresharedKey(pi)
+				}
+			} else {
+				logger.Error().Println(pkgName, cmd.Function, err)
 			}
+			failures.Add(item, err)
 
 		case "remove_peer":
-			pi, err := cmd.asPeerInfo()
+			pi, err := cmd.asPeerInfo(families)
 			if err != nil {
 				logger.Warning().Println(pkgName, err)
+				failures.Add(item, err)
 				continue
 			}
 			netpath, err := cmd.asNetworkPath()
 			if err != nil {
 				logger.Warning().Println(pkgName, err)
+				failures.Add(item, err)
 				continue
 			}
-			err = obj.mole.RemovePeer(pi, netpath)
+			err = target.RemovePeer(pi, netpath)
 			if err == nil {
 				delPeerCount++
+			} else {
+				logger.Error().Println(pkgName, cmd.Function, err)
 			}
-
-		}
-
-		if err != nil {
-			logger.Error().Println(pkgName, cmd.Function, err)
+			failures.Add(item, err)
 		}
 	}
 
 	logger.Info().Println(pkgName, "Added:", addPeerCount, " Deleted:", delPeerCount, "peers")
-	// sync and merge everything between controller and OS
-	obj.mole.Apply()
-
-	return nil
+	return failures
 }
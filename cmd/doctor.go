@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/SyntropyNet/syntropy-agent/agent/doctor"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/proxy"
+)
+
+// runDoctor runs preflight diagnostics and returns process exit code.
+// Agent is not started and no system configuration is changed.
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Print report in JSON format")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Only report is printed. Logs of used components would garble it.
+	logger.SetupGlobalLoger(nil, logger.ExecutableLevel)
+
+	config.Init()
+	defer config.Close()
+	proxy.Setup(config.GetProxy(), config.GetNoProxy())
+
+	report := doctor.Run(context.Background())
+	if *jsonOutput {
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	} else {
+		report.WriteText(os.Stdout)
+	}
+
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
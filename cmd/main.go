@@ -15,6 +15,7 @@ import (
 	"github.com/SyntropyNet/syntropy-agent/internal/config"
 	"github.com/SyntropyNet/syntropy-agent/internal/env"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/proxy"
 	"github.com/beevik/ntp"
 	"golang.org/x/sys/unix"
 )
@@ -85,6 +86,11 @@ func main() {
 
 	execName := os.Args[0]
 
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		exitCode = runDoctor(os.Args[2:])
+		return
+	}
+
 	showVersionAndExit := flag.Bool("version", false, "Show version and exit")
 
 	flag.Parse()
@@ -126,9 +132,16 @@ func main() {
 
 	logger.SetupGlobalLoger(nil, config.GetDebugLevel(), os.Stdout)
 
+	// Outbound proxy must be configured before controller is created
+	err = proxy.Setup(config.GetProxy(), config.GetNoProxy())
+	if err != nil {
+		logger.Error().Println(fullAppName, "Invalid proxy configuration. Using proxy environment variables.", err)
+	}
+
 	syntropyNetAgent, err := agent.New(config.GetControllerType())
 	if err != nil {
 		logger.Error().Println(fullAppName, "Could not create agent", err)
+		logger.Error().Println(fullAppName, "Run `"+execName+" doctor` to diagnose the host")
 		checkKernelVersion()
 		exitCode = -int(unix.ENOMEM)
 		return
@@ -149,9 +162,20 @@ func main() {
 	go syntropyNetAgent.Run()
 	defer syntropyNetAgent.Close()
 
+	// SIGHUP reloads configuration file
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	// Wait for SIGINT or SIGKILL to terminate app
 	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate, syscall.SIGINT, syscall.SIGTERM)
-	<-terminate
-	logger.Exec().Println(fullAppName, " terminating")
+	for {
+		select {
+		case <-reload:
+			syntropyNetAgent.Reload()
+		case <-terminate:
+			logger.Exec().Println(fullAppName, " terminating")
+			return
+		}
+	}
 }
@@ -0,0 +1,254 @@
+// syntropyctl is a small helper to inspect a running syntropy agent
+// It talks to agent over local control socket
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/ctlapi"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
+)
+
+const usage = `Usage: syntropyctl [options] <command>
+
+Commands:
+  interfaces   list wireguard interfaces
+  peers        list wireguard peers
+  routes       list route groups with monitored peers, services and selected path
+  services     list running agent services
+  plan <file>  dry-run CONFIG_INFO or WG_CONF message from file ("-" for stdin)
+               and show changes it would do
+  bandwidth <address|connection_id>...
+               probe achievable bandwidth of paths (wireguard peers)
+               Requires SYNTROPY_BWPROBE_PORT on both peers
+
+Options:
+`
+
+var errUsage = errors.New("invalid arguments")
+
+type command struct {
+	path string
+	// request builds POST request body from command arguments. nil means GET request
+	request func(args []string) (io.Reader, error)
+	// request timeout. Default is used if not set
+	timeout time.Duration
+	value   func() interface{}
+	print   func(w *tabwriter.Writer, v interface{})
+}
+
+var commands = map[string]command{
+	"interfaces": {
+		path:  ctlapi.PathInterfaces,
+		value: func() interface{} { return &[]ctlapi.Interface{} },
+		print: printInterfaces,
+	},
+	"peers": {
+		path:  ctlapi.PathPeers,
+		value: func() interface{} { return &[]ctlapi.Peer{} },
+		print: printPeers,
+	},
+	"routes": {
+		path:  ctlapi.PathRoutes,
+		value: func() interface{} { return &[]ctlapi.RouteGroup{} },
+		print: printRoutes,
+	},
+	"services": {
+		path:  ctlapi.PathServices,
+		value: func() interface{} { return &[]ctlapi.Service{} },
+		print: printServices,
+	},
+	"plan": {
+		path:    ctlapi.PathPlan,
+		request: fileRequest,
+		value:   func() interface{} { return &ctlapi.Plan{} },
+		print:   printPlan,
+	},
+	"bandwidth": {
+		path:    ctlapi.PathBandwidth,
+		request: bandwidthRequest,
+		timeout: 5 * time.Minute,
+		value:   func() interface{} { return &[]ctlapi.BandwidthResult{} },
+		print:   printBandwidth,
+	},
+}
+
+var probeDuration = flag.Int("duration", 0, "bandwidth probe duration in seconds (agent default if unset)")
+
+func main() {
+	socket := flag.String("socket", env.ControlSocket, "agent control socket path")
+	asJson := flag.Bool("json", false, "print raw json output")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	if cmd.request == nil && flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	v := cmd.value()
+	client := ctlapi.NewClient(*socket)
+	if cmd.timeout > 0 {
+		client.SetTimeout(cmd.timeout)
+	}
+	if cmd.request != nil {
+		var body io.Reader
+		body, err = cmd.request(flag.Args()[1:])
+		if err == errUsage {
+			flag.Usage()
+			os.Exit(2)
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		if c, ok := body.(io.Closer); ok {
+			defer c.Close()
+		}
+		err = client.Post(cmd.path, body, v)
+	} else {
+		err = client.Get(cmd.path, v)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	if *asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	cmd.print(w, v)
+	w.Flush()
+}
+
+// fileRequest reads request from file ("-" for stdin)
+func fileRequest(args []string) (io.Reader, error) {
+	if len(args) != 1 {
+		return nil, errUsage
+	}
+	if args[0] == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(args[0])
+}
+
+// bandwidthRequest treats numeric arguments as connection IDs and others as peer addresses
+func bandwidthRequest(args []string) (io.Reader, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	req := ctlapi.BandwidthRequest{Duration: *probeDuration}
+	for _, arg := range args {
+		if id, err := strconv.Atoi(arg); err == nil {
+			req.ConnectionIDs = append(req.ConnectionIDs, id)
+		} else if _, err := netip.ParseAddr(arg); err == nil {
+			req.Addresses = append(req.Addresses, arg)
+		} else {
+			return nil, fmt.Errorf("invalid address or connection id %s", arg)
+		}
+	}
+	raw, err := json.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(raw), nil
+}
+
+func printInterfaces(w *tabwriter.Writer, v interface{}) {
+	fmt.Fprintln(w, "IFNAME\tIP\tPORT\tPEERS\tPUBLIC KEY")
+	for _, e := range *v.(*[]ctlapi.Interface) {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", e.IfName, e.IP, e.Port, e.PeersCount, e.PublicKey)
+	}
+}
+
+func printPeers(w *tabwriter.Writer, v interface{}) {
+	fmt.Fprintln(w, "IFNAME\tCONN ID\tGROUP ID\tENDPOINT\tGATEWAY\tHANDSHAKE\tRX\tTX\tALLOWED IPS")
+	for _, e := range *v.(*[]ctlapi.Peer) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%d\t%d\t%s\n", e.IfName, e.ConnectionID, e.GroupID,
+			e.Endpoint, e.Gateway, e.LastHandshake, e.RxBytes, e.TxBytes, strings.Join(e.AllowedIPs, ","))
+	}
+}
+
+func printRoutes(w *tabwriter.Writer, v interface{}) {
+	for _, g := range *v.(*[]ctlapi.RouteGroup) {
+		fmt.Fprintf(w, "Group %d\n", g.GroupID)
+		if g.Selected != nil {
+			fmt.Fprintf(w, "  selected:\t%s (conn %d)\treason %s\n",
+				g.Selected.Gateway, g.Selected.ConnectionID, g.Selected.Details)
+		} else {
+			fmt.Fprintln(w, "  selected:\tnone")
+		}
+		for _, p := range g.Peers {
+			fmt.Fprintf(w, "  peer:\t%s\t%s\t(conn %d)\t[%s]\tloss %.2f%%\tlatency %.2fms\tjitter %.2fms\tp95 %.2fms",
+				p.Address, p.IfName, p.ConnectionID, p.Flags, 100*p.Loss, p.Latency, p.Jitter, p.LatencyP95)
+			if p.Bandwidth > 0 {
+				fmt.Fprintf(w, "\tbandwidth %.2fMbps", p.Bandwidth)
+			}
+			fmt.Fprintln(w)
+		}
+		for _, s := range g.Services {
+			disabled := ""
+			if s.Disabled {
+				disabled = "(IP conflict)"
+			}
+			fmt.Fprintf(w, "  service:\t%s\t%s\t%s\n", s.Address, disabled, strings.Join(s.Routes, ", "))
+		}
+	}
+}
+
+func printServices(w *tabwriter.Writer, v interface{}) {
+	fmt.Fprintln(w, "NAME")
+	for _, e := range *v.(*[]ctlapi.Service) {
+		fmt.Fprintln(w, e.Name)
+	}
+}
+
+func printPlan(w *tabwriter.Writer, v interface{}) {
+	plan := v.(*ctlapi.Plan)
+	if len(plan.Changes) == 0 {
+		fmt.Fprintln(w, plan.Message, "would not change anything")
+		return
+	}
+	fmt.Fprintln(w, "ACTION\tOBJECT\tNAME\tDETAILS")
+	for _, e := range plan.Changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Action, e.Object, e.Name, e.Details)
+	}
+}
+
+func printBandwidth(w *tabwriter.Writer, v interface{}) {
+	fmt.Fprintln(w, "ADDRESS\tIFNAME\tCONN ID\tBANDWIDTH\tBYTES\tDURATION\tERROR")
+	for _, e := range *v.(*[]ctlapi.BandwidthResult) {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.2f Mbps\t%d\t%dms\t%s\n", e.Address, e.IfName, e.ConnectionID,
+			e.Bandwidth, e.Bytes, e.Duration, e.Error)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/SyntropyNet/syntropy-agent/internal/config"
 	"github.com/SyntropyNet/syntropy-agent/internal/env"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/proxy"
 	"github.com/SyntropyNet/syntropy-agent/pkg/state"
 	"github.com/cosmos/go-bip39"
 	"github.com/decred/base58"
@@ -75,7 +76,10 @@ type CommodityInfo struct {
 }
 
 func getIpfsPayload(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+	client := http.Client{
+		Transport: proxy.Transport(),
+	}
+	resp, err := client.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -191,7 +195,9 @@ func (bc *BlockchainController) connect() error {
 	}
 
 	// Init IPFS
-	bc.ipfsShell = ipfsApi.NewShell(config.GetIpfsUrl())
+	transport := proxy.Transport()
+	transport.DisableKeepAlives = true
+	bc.ipfsShell = ipfsApi.NewShellWithClient(config.GetIpfsUrl(), &http.Client{Transport: transport})
 
 	// Mark running (do I need this state in this controller ?)
 	bc.SetState(running)
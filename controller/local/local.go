@@ -0,0 +1,145 @@
+// local package is a controller for on-prem automation.
+// It is an authenticated HTTP API on a local or private address.
+// Clients POST the same JSON messages the cloud controller sends
+// and receive agent messages as Server-Sent Events or long-poll.
+package local
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/controller"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+const pkgName = "LocalController. "
+
+// Received, but not yet processed messages
+const inboxSize = 16
+
+type LocalController struct {
+	address  string
+	token    string
+	certFile string
+	keyFile  string
+
+	inbox  chan []byte
+	outbox *outbox
+	server *http.Server
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New validates configuration. Listening is started on Open.
+func New(address, token, certFile, keyFile string) (controller.Controller, error) {
+	if token == "" {
+		return nil, errors.New("could not initialise local controller: authentication token is not configured")
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("could not initialise local controller: both TLS certificate and key must be configured")
+	}
+	err := validateAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialise local controller: %s", err)
+	}
+
+	lc := &LocalController{
+		address:  address,
+		token:    token,
+		certFile: certFile,
+		keyFile:  keyFile,
+		inbox:    make(chan []byte, inboxSize),
+		outbox:   newOutbox(),
+	}
+	lc.ctx, lc.cancel = context.WithCancel(context.Background())
+	lc.server = &http.Server{
+		Handler:           lc.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			// Close terminates streaming clients
+			return lc.ctx
+		},
+	}
+
+	return lc, nil
+}
+
+// validateAddress allows listening only on loopback or private addresses
+func validateAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("listen address %s must be an IP address", host)
+	}
+	if !ip.IsLoopback() && !ip.IsPrivate() {
+		return fmt.Errorf("listen address %s is not a loopback or private address", host)
+	}
+	return nil
+}
+
+func (lc *LocalController) Open() error {
+	listener, err := net.Listen("tcp", lc.address)
+	if err != nil {
+		return fmt.Errorf("local controller listen: %s", err)
+	}
+
+	if lc.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(lc.certFile, lc.keyFile)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("local controller certificate: %s", err)
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+		logger.Info().Println(pkgName, "Listening on https://"+lc.address)
+	} else {
+		logger.Info().Println(pkgName, "Listening on http://"+lc.address)
+	}
+
+	go func() {
+		err := lc.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error().Println(pkgName, "HTTP server", err)
+		}
+	}()
+
+	return nil
+}
+
+// Recv returns next message, POSTed by client
+func (lc *LocalController) Recv() ([]byte, error) {
+	select {
+	case <-lc.ctx.Done():
+		return nil, io.EOF
+	case msg := <-lc.inbox:
+		return msg, nil
+	}
+}
+
+// Write buffers agent message for clients
+func (lc *LocalController) Write(b []byte) (int, error) {
+	if lc.ctx.Err() != nil {
+		return 0, io.ErrClosedPipe
+	}
+	return lc.outbox.Write(b)
+}
+
+func (lc *LocalController) Close() error {
+	lc.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return lc.server.Shutdown(ctx)
+}
@@ -0,0 +1,114 @@
+package local
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "secret"
+
+func testRequest(t *testing.T, method, url, body, token string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestLocalController(t *testing.T) {
+	c, err := New("127.0.0.1:0", testToken, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lc := c.(*LocalController)
+	srv := httptest.NewServer(lc.handler())
+	defer srv.Close()
+	defer lc.Close()
+
+	resp := testRequest(t, http.MethodPost, srv.URL+pathMessages, `{"id":"1","type":"GET_INFO"}`, "wrong")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Invalid token accepted: %d", resp.StatusCode)
+	}
+
+	resp = testRequest(t, http.MethodPost, srv.URL+pathMessages, `{"id":"1"}`, testToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Message without type accepted: %d", resp.StatusCode)
+	}
+
+	msg := `{"id":"1","type":"GET_INFO"}`
+	resp = testRequest(t, http.MethodPost, srv.URL+pathMessages, msg, testToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Message post failed: %d", resp.StatusCode)
+	}
+	raw, err := lc.Recv()
+	if err != nil || string(raw) != msg {
+		t.Errorf("Invalid received message %s %s", raw, err)
+	}
+
+	// IDs continue from process start time
+	base := lc.outbox.lastID
+	lc.Write([]byte("{\n  \"id\": \"1\",\n  \"type\": \"GET_INFO\"\n}"))
+	lc.Write([]byte(`{"id":"-","type":"AUTO_PING"}`))
+
+	// Long-poll
+	resp = testRequest(t, http.MethodGet, srv.URL+pathMessages+fmt.Sprintf("?after=%d&timeout=1", base+1), "", testToken)
+	var poll struct {
+		Last     uint64
+		Messages []outMessage
+	}
+	err = json.NewDecoder(resp.Body).Decode(&poll)
+	resp.Body.Close()
+	if err != nil || poll.Last != base+2 || len(poll.Messages) != 1 ||
+		string(poll.Messages[0].Message) != `{"id":"-","type":"AUTO_PING"}` {
+		t.Errorf("Invalid long-poll response %+v %s", poll, err)
+	}
+
+	// Server-Sent Events
+	resp = testRequest(t, http.MethodGet, srv.URL+pathEvents, "", testToken)
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Invalid content type %s", resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+	expect := []string{fmt.Sprintf("id: %d", base+1), "event: message", `data: {"id":"1","type":"GET_INFO"}`, "",
+		fmt.Sprintf("id: %d", base+2), "event: message", `data: {"id":"-","type":"AUTO_PING"}`, ""}
+	for _, e := range expect {
+		line, err := reader.ReadString('\n')
+		if err != nil || strings.TrimSuffix(line, "\n") != e {
+			t.Fatalf("Invalid event line %q, expected %q %s", line, e, err)
+		}
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	for addr, valid := range map[string]bool{
+		"127.0.0.1:7878":   true,
+		"10.1.2.3:80":      true,
+		"[::1]:7878":       true,
+		"0.0.0.0:7878":     false,
+		"8.8.8.8:7878":     false,
+		"localhost:7878":   false,
+		"192.168.1.1":      false,
+		"192.168.1.1:7878": true,
+	} {
+		err := validateAddress(addr)
+		if (err == nil) != valid {
+			t.Errorf("Address %s validation %v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// How many agent messages are kept for clients
+const outboxSize = 1000
+
+type outMessage struct {
+	ID      uint64          `json:"id"`
+	Message json.RawMessage `json:"message"`
+}
+
+// outbox is a bounded buffer of agent messages. Every message gets an increasing ID,
+// so clients may resume (SSE Last-Event-ID or long-poll `after` parameter) without losing messages.
+// The oldest messages are dropped, when buffer is full.
+// IDs start from process start time (in microseconds), so they keep increasing after agent restart
+// and a client, resuming with ID from the previous run, does not miss new messages.
+type outbox struct {
+	sync.Mutex
+	messages []outMessage
+	lastID   uint64
+	// closed and replaced on every new message
+	notify chan struct{}
+}
+
+func newOutbox() *outbox {
+	return &outbox{
+		// Microseconds fit into JSON number (float64) without precision loss
+		lastID: uint64(time.Now().UnixMicro()),
+		notify: make(chan struct{}),
+	}
+}
+
+func (o *outbox) Write(b []byte) (int, error) {
+	// Compact message, so it fits into a single SSE data line
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return 0, err
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	o.lastID++
+	o.messages = append(o.messages, outMessage{ID: o.lastID, Message: buf.Bytes()})
+	if len(o.messages) > outboxSize {
+		o.messages[0] = outMessage{}
+		o.messages = o.messages[1:]
+	}
+
+	close(o.notify)
+	o.notify = make(chan struct{})
+
+	return len(b), nil
+}
+
+// since returns messages with ID greater than after
+// and a channel, which is closed when next message arrives.
+func (o *outbox) since(after uint64) ([]outMessage, <-chan struct{}) {
+	o.Lock()
+	defer o.Unlock()
+
+	if after > o.lastID {
+		// Client resumes with ID from another agent run (e.g. system clock went backwards).
+		// Send all kept messages instead of waiting until IDs pass the old value.
+		after = 0
+	}
+
+	var rv []outMessage
+	for _, m := range o.messages {
+		if m.ID > after {
+			rv = append(rv, m)
+		}
+	}
+	return rv, o.notify
+}
@@ -0,0 +1,33 @@
+package local
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxRestart(t *testing.T) {
+	previous := newOutbox()
+	previous.Write([]byte(`{"id":"1"}`))
+	msgs, _ := previous.since(0)
+	lastSeen := msgs[0].ID
+
+	// Agent restarted. Client resumes with ID from the previous run.
+	time.Sleep(time.Millisecond)
+	o := newOutbox()
+	o.Write([]byte(`{"id":"2"}`))
+	msgs, _ = o.since(lastSeen)
+	if len(msgs) != 1 || msgs[0].ID <= lastSeen {
+		t.Errorf("Message after restart was missed %+v", msgs)
+	}
+
+	// Client ID is from the future (e.g. clock went backwards). All kept messages are sent.
+	msgs, _ = o.since(o.lastID + 1000)
+	if len(msgs) != 1 || string(msgs[0].Message) != `{"id":"2"}` {
+		t.Errorf("Messages for a client of another run %+v", msgs)
+	}
+
+	msgs, _ = o.since(o.lastID)
+	if len(msgs) != 0 {
+		t.Errorf("Already received messages sent again %+v", msgs)
+	}
+}
@@ -0,0 +1,218 @@
+package local
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+const (
+	pathMessages = "/v1/messages"
+	pathEvents   = "/v1/events"
+
+	// CONFIG_INFO of a big network is several megabytes
+	maxMessageSize = 32 << 20
+	// How long POST waits, while agent is busy processing previous messages
+	inboxTimeout = 10 * time.Second
+
+	defaultPollTimeout = 30 * time.Second
+	maxPollTimeout     = 120 * time.Second
+	keepaliveInterval  = 15 * time.Second
+)
+
+func (lc *LocalController) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathMessages, lc.authenticated(lc.messagesHandler))
+	mux.HandleFunc(pathEvents, lc.authenticated(lc.eventsHandler))
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+func (lc *LocalController) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	expected := []byte("Bearer " + lc.token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			logger.Warning().Println(pkgName, "Unauthorized request from", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// afterID parses last message ID, client has already received
+func afterID(r *http.Request) (uint64, error) {
+	str := r.URL.Query().Get("after")
+	if str == "" {
+		// SSE reconnect
+		str = r.Header.Get("Last-Event-ID")
+	}
+	if str == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(str, 10, 64)
+}
+
+func (lc *LocalController) messagesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		lc.postMessage(w, r)
+	case http.MethodGet:
+		lc.pollMessages(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// postMessage passes controller message to agent
+func (lc *LocalController) postMessage(w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var header struct {
+		ID      string `json:"id"`
+		MsgType string `json:"type"`
+	}
+	err = json.Unmarshal(raw, &header)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid message: "+err.Error())
+		return
+	}
+	if header.MsgType == "" {
+		writeError(w, http.StatusBadRequest, "invalid message: type is missing")
+		return
+	}
+
+	select {
+	case lc.inbox <- raw:
+		logger.Debug().Println(pkgName, "Received", header.MsgType, header.ID, "from", r.RemoteAddr)
+		writeJSON(w, http.StatusAccepted, header)
+	case <-time.After(inboxTimeout):
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, "agent is busy")
+	case <-r.Context().Done():
+	}
+}
+
+// pollMessages returns agent messages with ID greater than `after`.
+// If there are none - waits up to `timeout` seconds for new messages.
+func (lc *LocalController) pollMessages(w http.ResponseWriter, r *http.Request) {
+	after, err := afterID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid after parameter")
+		return
+	}
+
+	timeout := defaultPollTimeout
+	if str := r.URL.Query().Get("timeout"); str != "" {
+		seconds, err := strconv.ParseUint(str, 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid timeout parameter")
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > maxPollTimeout {
+			timeout = maxPollTimeout
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var resp struct {
+		Last     uint64       `json:"last"`
+		Messages []outMessage `json:"messages"`
+	}
+	resp.Last = after
+	resp.Messages = []outMessage{}
+
+	msgs, notify := lc.outbox.since(after)
+	for len(msgs) == 0 {
+		select {
+		case <-notify:
+			msgs, notify = lc.outbox.since(after)
+		case <-timer.C:
+			writeJSON(w, http.StatusOK, resp)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	resp.Messages = msgs
+	resp.Last = msgs[len(msgs)-1].ID
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// eventsHandler streams agent messages as Server-Sent Events.
+// Event ID is message ID, so reconnecting clients continue where they stopped.
+func (lc *LocalController) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	after, err := afterID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid last event ID")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	logger.Info().Println(pkgName, "Events client", r.RemoteAddr, "connected")
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		msgs, notify := lc.outbox.since(after)
+		for _, m := range msgs {
+			_, err = fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", m.ID, m.Message)
+			if err != nil {
+				return
+			}
+			after = m.ID
+		}
+		flusher.Flush()
+
+		select {
+		case <-notify:
+		case <-keepalive.C:
+			_, err = io.WriteString(w, ": keepalive\n\n")
+			if err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			logger.Info().Println(pkgName, "Events client", r.RemoteAddr, "disconnected")
+			return
+		}
+	}
+}
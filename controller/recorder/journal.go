@@ -0,0 +1,170 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+// Message directions
+const (
+	DirectionIn  = "in"  // controller -> agent
+	DirectionOut = "out" // agent -> controller
+)
+
+const (
+	// Journal is rotated when it grows bigger than this size
+	maxJournalSize = 10 * 1024 * 1024
+	// Rotated journals are kept as journal.1 ... journal.5 (journal.1 is the newest)
+	maxBackups = 5
+	// Controller messages are quite big (e.g. CONFIG_INFO)
+	maxLineSize = 16 * 1024 * 1024
+)
+
+// Entry is a single journal record. Journal is a JSON-lines file.
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"dir"`
+	Message   json.RawMessage `json:"msg"`
+}
+
+// Journal appends entries to a rotating file
+type Journal struct {
+	sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	maxSize int64
+}
+
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{
+		path:    path,
+		maxSize: maxJournalSize,
+	}
+	err := j.open()
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Journal) open() error {
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("journal open: %s", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("journal stat: %s", err)
+	}
+	j.file = file
+	j.size = info.Size()
+	return nil
+}
+
+// Add records message. Errors are logged only - journal must not break agent.
+// Secrets (e.g. preshared keys) are redacted, thus journal may be shared for troubleshooting.
+// Replayed peers with redacted keys fail to configure.
+func (j *Journal) Add(direction string, msg []byte) {
+	entry := Entry{
+		Time:      time.Now(),
+		Direction: direction,
+		Message:   logger.Redact(msg),
+	}
+	if !json.Valid(msg) {
+		// keep invalid messages as JSON strings
+		entry.Message, _ = json.Marshal(string(entry.Message))
+	}
+
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		logger.Error().Println(pkgName, "journal marshal", err)
+		return
+	}
+	line = append(line, '\n')
+
+	j.Lock()
+	defer j.Unlock()
+
+	if j.file == nil {
+		return
+	}
+	if j.size > 0 && j.size+int64(len(line)) > j.maxSize {
+		j.rotate()
+		if j.file == nil {
+			return
+		}
+	}
+
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	if err != nil {
+		logger.Error().Println(pkgName, "journal write", err)
+	}
+}
+
+// rotate shifts old journals and starts a new one. The oldest journal is dropped.
+func (j *Journal) rotate() {
+	j.file.Close()
+	j.file = nil
+
+	for i := maxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", j.path, i), fmt.Sprintf("%s.%d", j.path, i+1))
+	}
+	err := os.Rename(j.path, j.path+".1")
+	if err != nil {
+		logger.Error().Println(pkgName, "journal rotate", err)
+	}
+
+	err = j.open()
+	if err != nil {
+		logger.Error().Println(pkgName, err)
+	}
+}
+
+func (j *Journal) Close() error {
+	j.Lock()
+	defer j.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// ReadJournal loads all journal entries
+func ReadJournal(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, fmt.Errorf("journal %s line %d: %s", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
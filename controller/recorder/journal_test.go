@@ -0,0 +1,54 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("Journal open failed: %s", err)
+	}
+
+	j.Add(DirectionIn, []byte(`{"type":"CONFIG_INFO"}`))
+	j.Add(DirectionOut, []byte(`not json`))
+	j.Add(DirectionOut, []byte(`{"data":[{"fn":"set_preshared_key","data":{"preshared_key":"c2VjcmV0"}}]}`))
+	j.Add(DirectionIn, []byte(`{"data":{"preshared_key":"c2VjcmV0"}`))
+
+	entries, err := ReadJournal(path)
+	if err != nil {
+		t.Fatalf("Journal read failed: %s", err)
+	}
+	if len(entries) != 4 || entries[0].Direction != DirectionIn ||
+		string(entries[0].Message) != `{"type":"CONFIG_INFO"}` ||
+		string(entries[1].Message) != `"not json"` {
+		t.Fatalf("Journal content mismatch %+v", entries)
+	}
+	for _, e := range entries[2:] {
+		if strings.Contains(string(e.Message), "c2VjcmV0") || !strings.Contains(string(e.Message), "redacted") {
+			t.Errorf("Preshared key was recorded: %s", e.Message)
+		}
+	}
+
+	// Rotation keeps limited count of old journals
+	j.maxSize = 1
+	for i := 0; i < maxBackups+3; i++ {
+		j.Add(DirectionIn, []byte(`{}`))
+	}
+	j.Close()
+
+	if _, err := os.Stat(path + ".5"); err != nil {
+		t.Errorf("Rotated journal missing: %s", err)
+	}
+	if _, err := os.Stat(path + ".6"); !os.IsNotExist(err) {
+		t.Errorf("Too many rotated journals kept")
+	}
+	entries, err = ReadJournal(path)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Rotated journal read failed %d %v", len(entries), err)
+	}
+}
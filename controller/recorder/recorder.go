@@ -0,0 +1,48 @@
+// recorder package wraps any controller and journals all its messages.
+// Journal can later be played back with replay controller to reproduce field issues.
+package recorder
+
+import (
+	"github.com/SyntropyNet/syntropy-agent/controller"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+const pkgName = "Recorder. "
+
+// Recorder is a controller.Controller, that journals messages of wrapped controller
+type Recorder struct {
+	controller.Controller
+	journal *Journal
+}
+
+func New(c controller.Controller, path string) (*Recorder, error) {
+	journal, err := OpenJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info().Println(pkgName, "Recording controller messages to", path)
+
+	return &Recorder{
+		Controller: c,
+		journal:    journal,
+	}, nil
+}
+
+func (r *Recorder) Recv() ([]byte, error) {
+	msg, err := r.Controller.Recv()
+	if err == nil {
+		r.journal.Add(DirectionIn, msg)
+	}
+	return msg, err
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.journal.Add(DirectionOut, b)
+	return r.Controller.Write(b)
+}
+
+func (r *Recorder) Close() error {
+	err := r.Controller.Close()
+	r.journal.Close()
+	return err
+}
@@ -0,0 +1,195 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+// Fields, that differ on every run
+var ignoredFields = map[string]bool{
+	"executed_at": true,
+	"timestamp":   true,
+}
+
+// Periodic statistics messages. Their values differ on every run, so only presence is compared.
+var volatileTypes = map[string]bool{
+	"IFACES_PEERS_BW_DATA": true,
+	"AUTO_PING":            true,
+	"COMMAND_QUEUE_STATS":  true,
+}
+
+// Mismatch is an agent message, which differs from the recorded one
+type Mismatch struct {
+	Type        string          `json:"type"`
+	Differences []string        `json:"differences"`
+	Expected    json.RawMessage `json:"expected"`
+	Actual      json.RawMessage `json:"actual"`
+}
+
+// Report is the replay result
+type Report struct {
+	Matched    int        `json:"matched"`
+	Mismatched []Mismatch `json:"mismatched"`
+	// Agent sent messages, that were not recorded
+	Unexpected []json.RawMessage `json:"unexpected"`
+	// Recorded messages, that agent did not send
+	Missing []json.RawMessage `json:"missing"`
+}
+
+// comparer matches agent messages with recorded ones of the same type, in the recorded order
+type comparer struct {
+	expected map[string][]json.RawMessage
+	order    []string // message types in order of first appearance. Keeps report stable
+	result   Report
+}
+
+func newComparer() *comparer {
+	return &comparer{
+		expected: make(map[string][]json.RawMessage),
+		result: Report{
+			Mismatched: []Mismatch{},
+			Unexpected: []json.RawMessage{},
+			Missing:    []json.RawMessage{},
+		},
+	}
+}
+
+func messageType(msg []byte) string {
+	var header struct {
+		MsgType string `json:"type"`
+	}
+	json.Unmarshal(msg, &header)
+	return header.MsgType
+}
+
+func (c *comparer) expect(msg json.RawMessage) {
+	t := messageType(msg)
+	if _, ok := c.expected[t]; !ok {
+		c.order = append(c.order, t)
+	}
+	c.expected[t] = append(c.expected[t], msg)
+}
+
+func (c *comparer) compare(msg []byte) {
+	t := messageType(msg)
+	// agent may reuse buffer after Write
+	actual := append(json.RawMessage{}, msg...)
+
+	queue := c.expected[t]
+	if len(queue) == 0 {
+		logger.Warning().Println(pkgName, "Unexpected message", t)
+		c.result.Unexpected = append(c.result.Unexpected, actual)
+		return
+	}
+	expected := queue[0]
+	c.expected[t] = queue[1:]
+
+	if volatileTypes[t] {
+		c.result.Matched++
+		return
+	}
+
+	differences, err := diff(expected, actual)
+	if err != nil {
+		differences = []string{err.Error()}
+	}
+	if len(differences) == 0 {
+		c.result.Matched++
+		return
+	}
+
+	logger.Warning().Println(pkgName, "Message", t, "differs from recorded:", differences)
+	c.result.Mismatched = append(c.result.Mismatched, Mismatch{
+		Type:        t,
+		Differences: differences,
+		Expected:    expected,
+		Actual:      actual,
+	})
+}
+
+func (c *comparer) report() *Report {
+	rv := c.result
+	rv.Missing = append([]json.RawMessage{}, c.result.Missing...)
+	for _, t := range c.order {
+		if volatileTypes[t] {
+			// number of periodic messages depends on run duration
+			continue
+		}
+		rv.Missing = append(rv.Missing, c.expected[t]...)
+	}
+	return &rv
+}
+
+// diff returns human readable list of differences between two JSON documents
+func diff(expected, actual []byte) ([]string, error) {
+	var e, a interface{}
+	err := json.Unmarshal(expected, &e)
+	if err != nil {
+		return nil, fmt.Errorf("recorded message: %s", err)
+	}
+	err = json.Unmarshal(actual, &a)
+	if err != nil {
+		return nil, fmt.Errorf("agent message: %s", err)
+	}
+	return diffValues("", e, a), nil
+}
+
+func diffValues(path string, expected, actual interface{}) []string {
+	var rv []string
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := []string{}
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ignoredFields[k] {
+				continue
+			}
+			ev, eok := e[k]
+			av, aok := a[k]
+			switch {
+			case !aok:
+				rv = append(rv, fmt.Sprintf("%s.%s: missing", path, k))
+			case !eok:
+				rv = append(rv, fmt.Sprintf("%s.%s: unexpected %v", path, k, av))
+			default:
+				rv = append(rv, diffValues(path+"."+k, ev, av)...)
+			}
+		}
+		return rv
+
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		if len(e) != len(a) {
+			return []string{fmt.Sprintf("%s: length %d, expected %d", path, len(a), len(e))}
+		}
+		for i := range e {
+			rv = append(rv, diffValues(fmt.Sprintf("%s[%d]", path, i), e[i], a[i])...)
+		}
+		return rv
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		rv = append(rv, fmt.Sprintf("%s: %v, expected %v", path, actual, expected))
+	}
+	return rv
+}
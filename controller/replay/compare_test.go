@@ -0,0 +1,46 @@
+package replay
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestComparer(t *testing.T) {
+	c := newComparer()
+	c.expect(json.RawMessage(`{"id":"1","type":"WG_CONF","executed_at":"a","data":[{"ip":"10.0.0.1"}]}`))
+	c.expect(json.RawMessage(`{"id":"2","type":"WG_CONF","data":[]}`))
+	c.expect(json.RawMessage(`{"id":"-","type":"IFACES_PEERS_BW_DATA","data":{"rx":1}}`))
+	c.expect(json.RawMessage(`{"id":"3","type":"WG_ROUTE_STATUS","data":[]}`))
+
+	// Timestamps are ignored
+	c.compare([]byte(`{"id":"1","type":"WG_CONF","executed_at":"b","data":[{"ip":"10.0.0.1"}]}`))
+	// Values differ
+	c.compare([]byte(`{"id":"2","type":"WG_CONF","data":[{"ip":"10.0.0.2"}]}`))
+	// Statistics are not compared
+	c.compare([]byte(`{"id":"-","type":"IFACES_PEERS_BW_DATA","data":{"rx":2}}`))
+	c.compare([]byte(`{"id":"4","type":"GET_INFO"}`))
+
+	r := c.report()
+	if r.Matched != 2 || len(r.Mismatched) != 1 || len(r.Unexpected) != 1 || len(r.Missing) != 1 {
+		t.Fatalf("Invalid report %+v", r)
+	}
+	if len(r.Mismatched[0].Differences) != 1 || r.Mismatched[0].Differences[0] != ".data: length 1, expected 0" {
+		t.Errorf("Invalid differences %v", r.Mismatched[0].Differences)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	d, err := diff([]byte(`{"a":1,"b":{"c":"x"},"d":true}`), []byte(`{"a":2,"b":{"c":"x","e":1}}`))
+	if err != nil {
+		t.Fatalf("diff failed %s", err)
+	}
+	expected := []string{".a: 2, expected 1", ".b.e: unexpected 1", ".d: missing"}
+	if len(d) != len(expected) {
+		t.Fatalf("diff mismatch %v", d)
+	}
+	for i := range d {
+		if d[i] != expected[i] {
+			t.Errorf("diff mismatch %s vs %s", d[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// replay package plays back a recorded controller messages journal.
+// Inbound messages are fed to agent with original (or accelerated) timing.
+// Agent outbound messages are compared with the recorded ones and differences are reported.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/controller"
+	"github.com/SyntropyNet/syntropy-agent/controller/recorder"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+const pkgName = "ReplayController. "
+
+type ReplayController struct {
+	inbound []recorder.Entry
+	index   int
+	speed   float32
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	reportPath string
+	compare    sync.Mutex
+	comparer   *comparer
+}
+
+// New loads journal. Speed 1 keeps original timing, 0 - no delays.
+func New(path string, speed float32) (controller.Controller, error) {
+	entries, err := recorder.ReadJournal(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialise replay controller: %s", err)
+	}
+
+	rc := &ReplayController{
+		speed:      speed,
+		reportPath: path + ".report.json",
+		comparer:   newComparer(),
+	}
+	rc.ctx, rc.cancel = context.WithCancel(context.Background())
+
+	for _, e := range entries {
+		switch e.Direction {
+		case recorder.DirectionIn:
+			rc.inbound = append(rc.inbound, e)
+		case recorder.DirectionOut:
+			rc.comparer.expect(e.Message)
+		}
+	}
+	logger.Info().Println(pkgName, "Loaded", path, ":", len(rc.inbound), "inbound and",
+		len(entries)-len(rc.inbound), "outbound messages. Speed", speed)
+
+	return rc, nil
+}
+
+func (rc *ReplayController) Open() error {
+	return nil
+}
+
+func (rc *ReplayController) Recv() ([]byte, error) {
+	if rc.index < len(rc.inbound) {
+		entry := rc.inbound[rc.index]
+		if rc.index > 0 && rc.speed > 0 {
+			delay := time.Duration(float64(entry.Time.Sub(rc.inbound[rc.index-1].Time)) / float64(rc.speed))
+			select {
+			case <-rc.ctx.Done():
+				return nil, io.EOF
+			case <-time.After(delay):
+			}
+		}
+		rc.index++
+		logger.Debug().Println(pkgName, "Replaying message", rc.index, "of", len(rc.inbound))
+		return entry.Message, nil
+	}
+
+	// Agent keeps on working (and replying) after the last message.
+	// Final report is written on Close
+	logger.Info().Println(pkgName, "No more messages.")
+	<-rc.ctx.Done()
+	return nil, io.EOF
+}
+
+// Write compares agent message with recorded one
+func (rc *ReplayController) Write(b []byte) (int, error) {
+	rc.compare.Lock()
+	defer rc.compare.Unlock()
+
+	rc.comparer.compare(b)
+	return len(b), nil
+}
+
+// Close writes replay report
+func (rc *ReplayController) Close() error {
+	rc.cancel()
+
+	rc.compare.Lock()
+	report := rc.comparer.report()
+	rc.compare.Unlock()
+
+	logger.Info().Println(pkgName, "Replay report: matched", report.Matched, "mismatched", len(report.Mismatched),
+		"unexpected", len(report.Unexpected), "missing", len(report.Missing))
+
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(rc.reportPath, raw, 0600)
+	if err != nil {
+		logger.Error().Println(pkgName, "report write", err)
+		return err
+	}
+	logger.Info().Println(pkgName, "Replay report saved to", rc.reportPath)
+	return nil
+}
@@ -0,0 +1,169 @@
+package saas
+
+import (
+	"crypto/tls"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/pkg/proxy"
+)
+
+const (
+	// Reconnect exponential backoff limits
+	backoffMin = time.Second
+	backoffMax = 2 * time.Minute
+	// How often more preferred endpoints are probed, when connected to a fallback one
+	failbackPeriod = 5 * time.Minute
+	probeTimeout   = 5 * time.Second
+)
+
+type endpoint struct {
+	host   string
+	weight uint
+	// consecutive connection failures
+	failures uint
+	// endpoint is not tried until this time
+	retryAt time.Time
+}
+
+// endpointList keeps cloud controller endpoints health.
+// Endpoints are sorted by preference, most preferred first.
+type endpointList struct {
+	sync.Mutex
+	endpoints []*endpoint
+	current   *endpoint
+}
+
+func newEndpointList(cfg []config.ControllerEndpoint) *endpointList {
+	el := &endpointList{}
+	for _, e := range cfg {
+		el.endpoints = append(el.endpoints, &endpoint{
+			host:   e.Host,
+			weight: e.Weight,
+		})
+	}
+	return el
+}
+
+// next returns the most preferred healthy endpoint.
+// If all endpoints are in backoff - returns the one, which will be available first,
+// and the delay to wait before connecting.
+func (el *endpointList) next() (*endpoint, time.Duration) {
+	el.Lock()
+	defer el.Unlock()
+
+	now := time.Now()
+	var first *endpoint
+	for _, e := range el.endpoints {
+		if !now.Before(e.retryAt) {
+			return e, 0
+		}
+		if first == nil || e.retryAt.Before(first.retryAt) {
+			first = e
+		}
+	}
+	return first, first.retryAt.Sub(now)
+}
+
+// failed puts endpoint to backoff. Backoff grows exponentially and is randomised,
+// so that reconnecting agents would not DDOS the recovering controller.
+func (el *endpointList) failed(e *endpoint) time.Duration {
+	el.Lock()
+	defer el.Unlock()
+
+	e.failures++
+	backoff := backoffMax
+	if e.failures < 16 {
+		backoff = backoffMin << (e.failures - 1)
+		if backoff > backoffMax {
+			backoff = backoffMax
+		}
+	}
+	return el.delay(e, backoff)
+}
+
+// rejected puts endpoint to the longest backoff.
+// TLS handshake failures need a configuration fix and reconnecting fast would not help.
+func (el *endpointList) rejected(e *endpoint) time.Duration {
+	el.Lock()
+	defer el.Unlock()
+
+	e.failures++
+	return el.delay(e, backoffMax)
+}
+
+// delay randomises backoff and schedules endpoint retry. Caller must hold the lock.
+func (el *endpointList) delay(e *endpoint, backoff time.Duration) time.Duration {
+	// jitter: random value in [backoff/2, backoff)
+	backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)))
+	e.retryAt = time.Now().Add(backoff)
+	if el.current == e {
+		el.current = nil
+	}
+
+	return backoff
+}
+
+// connected marks endpoint healthy and current
+func (el *endpointList) connected(e *endpoint) {
+	el.Lock()
+	defer el.Unlock()
+
+	e.failures = 0
+	e.retryAt = time.Time{}
+	el.current = e
+}
+
+// recovered clears endpoint backoff
+func (el *endpointList) recovered(e *endpoint) {
+	el.Lock()
+	defer el.Unlock()
+
+	e.failures = 0
+	e.retryAt = time.Time{}
+}
+
+// getCurrent returns connected endpoint host, or the most preferred one if not connected
+func (el *endpointList) getCurrent() string {
+	el.Lock()
+	defer el.Unlock()
+
+	if el.current != nil {
+		return el.current.host
+	}
+	return el.endpoints[0].host
+}
+
+// preferred returns endpoints, that are more preferred than the current one
+func (el *endpointList) preferred() []*endpoint {
+	el.Lock()
+	defer el.Unlock()
+
+	if el.current == nil {
+		return nil
+	}
+	for i, e := range el.endpoints {
+		if e == el.current {
+			return append([]*endpoint{}, el.endpoints[:i]...)
+		}
+	}
+	return nil
+}
+
+// probe checks if endpoint serves HTTPS. Any HTTP response means endpoint is alive.
+func (e *endpoint) probe(tlsConfig *tls.Config) error {
+	transport := proxy.Transport()
+	transport.TLSClientConfig = tlsConfig
+	client := http.Client{
+		Timeout:   probeTimeout,
+		Transport: transport,
+	}
+	resp, err := client.Get("https://" + e.host + "/")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,67 @@
+package saas
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
+)
+
+func TestEndpointFailover(t *testing.T) {
+	el := newEndpointList([]config.ControllerEndpoint{
+		{Host: "primary.example.com", Weight: 2},
+		{Host: "backup.example.com", Weight: 1},
+	})
+
+	ep, delay := el.next()
+	if ep.host != "primary.example.com" || delay != 0 {
+		t.Fatalf("Invalid first endpoint %s %s", ep.host, delay)
+	}
+
+	// Primary fails - backup is used without delay
+	backoff := el.failed(ep)
+	if backoff < backoffMin/2 || backoff >= backoffMin {
+		t.Errorf("Invalid first backoff %s", backoff)
+	}
+	ep, delay = el.next()
+	if ep.host != "backup.example.com" || delay != 0 {
+		t.Fatalf("Invalid failover endpoint %s %s", ep.host, delay)
+	}
+	el.connected(ep)
+	if el.getCurrent() != "backup.example.com" {
+		t.Errorf("Invalid current endpoint %s", el.getCurrent())
+	}
+	if preferred := el.preferred(); len(preferred) != 1 || preferred[0].host != "primary.example.com" {
+		t.Errorf("Invalid preferred endpoints %v", preferred)
+	}
+
+	// Both failed - wait for the one, which will be available first
+	el.failed(ep)
+	el.failed(ep)
+	ep, delay = el.next()
+	if ep.host != "primary.example.com" || delay <= 0 || delay > backoffMin {
+		t.Errorf("Invalid endpoint %s %s", ep.host, delay)
+	}
+
+	// Primary is back - failback
+	el.recovered(el.endpoints[0])
+	ep, delay = el.next()
+	if ep.host != "primary.example.com" || delay != 0 {
+		t.Errorf("Failback failed %s %s", ep.host, delay)
+	}
+}
+
+func TestEndpointBackoffLimit(t *testing.T) {
+	el := newEndpointList([]config.ControllerEndpoint{{Host: "controller.example.com", Weight: 1}})
+
+	var backoff time.Duration
+	for i := 0; i < 40; i++ {
+		backoff = el.failed(el.endpoints[0])
+		if backoff >= backoffMax {
+			t.Fatalf("Backoff %s exceeds limit", backoff)
+		}
+	}
+	if backoff < backoffMax/2 {
+		t.Errorf("Backoff %s does not grow", backoff)
+	}
+}
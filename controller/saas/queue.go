@@ -0,0 +1,267 @@
+package saas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+// Outbound message classes, in sending priority order
+const (
+	// Replies to controller. Are kept and retried across reconnects
+	classCritical = iota
+	// Periodic statistics. Only the newest message of a type is kept
+	classTelemetry
+	// Logs. Bounded FIFO, the oldest messages are discarded
+	classBulk
+	classCount
+)
+
+// Message types, that are not critical. All other messages are critical.
+var messageClasses = map[string]int{
+	"IFACES_PEERS_BW_DATA": classTelemetry,
+	"AUTO_PING":            classTelemetry,
+	"COMMAND_QUEUE_STATS":  classTelemetry,
+	"LOGGER":               classBulk,
+}
+
+const (
+	maxCriticalMessages = 500
+	maxBulkMessages     = 50
+)
+
+type queuedMessage struct {
+	msgType string
+	class   int
+	data    []byte
+	// persisted message file (critical messages without secrets only)
+	file string
+}
+
+// sendQueue is a prioritised outbound messages queue.
+// Message is removed from queue only after it was successfully sent.
+type sendQueue struct {
+	sync.Mutex
+	messages  [classCount][]*queuedMessage
+	discarded [classCount]uint64
+	// notifies sender about new messages. Is closed when queue is closed
+	signal chan struct{}
+	closed bool
+	// critical messages are persisted to this directory, if set
+	persistDir string
+	sequence   int64
+}
+
+func newSendQueue(persistDir string) *sendQueue {
+	q := &sendQueue{
+		signal:     make(chan struct{}, 1),
+		persistDir: persistDir,
+		sequence:   time.Now().UnixNano(),
+	}
+	return q
+}
+
+func messageClass(data []byte) (string, int) {
+	var header struct {
+		MsgType string `json:"type"`
+	}
+	json.Unmarshal(data, &header)
+	class, ok := messageClasses[header.MsgType]
+	if !ok {
+		class = classCritical
+	}
+	return header.MsgType, class
+}
+
+// load restores critical messages, persisted by previous agent run
+func (q *sendQueue) load() error {
+	if q.persistDir == "" {
+		return nil
+	}
+	err := os.MkdirAll(q.persistDir, 0700)
+	if err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(q.persistDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	// file names are zero padded sequence numbers
+	sort.Strings(files)
+
+	q.Lock()
+	defer q.Unlock()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			os.Remove(file)
+			continue
+		}
+		msgType, _ := messageClass(data)
+		q.messages[classCritical] = append(q.messages[classCritical],
+			&queuedMessage{msgType: msgType, class: classCritical, data: data, file: file})
+	}
+	q.notify()
+
+	return nil
+}
+
+func (q *sendQueue) persist(msg *queuedMessage) error {
+	q.sequence++
+	file := filepath.Join(q.persistDir, fmt.Sprintf("%020d.json", q.sequence))
+	tmpFile := filepath.Join(q.persistDir, "."+filepath.Base(file))
+	err := os.WriteFile(tmpFile, msg.data, 0600)
+	if err == nil {
+		err = os.Rename(tmpFile, file)
+	}
+	if err != nil {
+		return err
+	}
+	msg.file = file
+	return nil
+}
+
+func (q *sendQueue) notify() {
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// push adds message to queue. Never blocks.
+func (q *sendQueue) push(data []byte) error {
+	msgType, class := messageClass(data)
+	msg := &queuedMessage{
+		msgType: msgType,
+		class:   class,
+		// caller may reuse buffer
+		data: append([]byte{}, data...),
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	if q.closed {
+		return ErrNotRunning
+	}
+
+	var err error
+	switch class {
+	case classCritical:
+		// Messages with secrets (e.g. generated preshared keys) are kept in memory only
+		if q.persistDir != "" && !logger.HasSecrets(msg.data) {
+			// Still keep the message in memory, if persisting failed
+			err = q.persist(msg)
+		}
+		if len(q.messages[class]) >= maxCriticalMessages {
+			q.drop(class, 0)
+		}
+		q.messages[class] = append(q.messages[class], msg)
+
+	case classTelemetry:
+		// Replace the older message of the same type (newer message takes its place)
+		replaced := false
+		for i, m := range q.messages[class] {
+			if m.msgType == msgType {
+				q.messages[class][i] = msg
+				q.discarded[class]++
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			q.messages[class] = append(q.messages[class], msg)
+		}
+
+	case classBulk:
+		if len(q.messages[class]) >= maxBulkMessages {
+			q.drop(class, 0)
+		}
+		q.messages[class] = append(q.messages[class], msg)
+	}
+
+	q.notify()
+	return err
+}
+
+// drop discards message. Caller must hold the lock.
+func (q *sendQueue) drop(class, index int) {
+	msg := q.messages[class][index]
+	if msg.file != "" {
+		os.Remove(msg.file)
+	}
+	q.messages[class] = append(q.messages[class][:index], q.messages[class][index+1:]...)
+	q.discarded[class]++
+}
+
+// peek returns the highest priority message, without removing it from queue
+func (q *sendQueue) peek() *queuedMessage {
+	q.Lock()
+	defer q.Unlock()
+
+	for class := range q.messages {
+		if len(q.messages[class]) > 0 {
+			return q.messages[class][0]
+		}
+	}
+	return nil
+}
+
+// remove deletes successfully sent message.
+// Message may be already gone (discarded or replaced with a newer one)
+func (q *sendQueue) remove(msg *queuedMessage) {
+	q.Lock()
+	defer q.Unlock()
+
+	for i, m := range q.messages[msg.class] {
+		if m == msg {
+			q.messages[msg.class] = append(q.messages[msg.class][:i], q.messages[msg.class][i+1:]...)
+			if msg.file != "" {
+				os.Remove(msg.file)
+			}
+			return
+		}
+	}
+}
+
+// wait blocks until a new message is pushed. Returns false, if queue is closed.
+func (q *sendQueue) wait() bool {
+	_, ok := <-q.signal
+	return ok
+}
+
+// close stops the queue. Persisted messages are left for next agent run
+func (q *sendQueue) close() {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.signal)
+	}
+}
+
+// takeDiscarded returns and resets discarded messages counts
+// Returns empty string if nothing was discarded.
+func (q *sendQueue) takeDiscarded() string {
+	q.Lock()
+	defer q.Unlock()
+
+	names := [classCount]string{"critical", "telemetry", "logs"}
+	var arr []string
+	for class, count := range q.discarded {
+		if count > 0 {
+			arr = append(arr, fmt.Sprintf("%s: %d", names[class], count))
+		}
+		q.discarded[class] = 0
+	}
+	return strings.Join(arr, ", ")
+}
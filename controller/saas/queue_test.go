@@ -0,0 +1,113 @@
+package saas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func popAll(q *sendQueue) []string {
+	var rv []string
+	for msg := q.peek(); msg != nil; msg = q.peek() {
+		rv = append(rv, string(msg.data))
+		q.remove(msg)
+	}
+	return rv
+}
+
+func TestSendQueuePriority(t *testing.T) {
+	q := newSendQueue("")
+
+	q.push([]byte(`{"type":"LOGGER","data":1}`))
+	q.push([]byte(`{"type":"IFACES_PEERS_BW_DATA","data":1}`))
+	q.push([]byte(`{"type":"WG_ROUTE_STATUS","data":1}`))
+	q.push([]byte(`{"type":"IFACES_PEERS_BW_DATA","data":2}`))
+	q.push([]byte(`{"type":"UPDATE_AGENT_CONFIG","data":1}`))
+
+	expected := []string{
+		`{"type":"WG_ROUTE_STATUS","data":1}`,
+		`{"type":"UPDATE_AGENT_CONFIG","data":1}`,
+		`{"type":"IFACES_PEERS_BW_DATA","data":2}`,
+		`{"type":"LOGGER","data":1}`,
+	}
+	result := popAll(q)
+	if len(result) != len(expected) {
+		t.Fatalf("Invalid queue content %v", result)
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("Message %d: %s, expected %s", i, result[i], expected[i])
+		}
+	}
+
+	if discarded := q.takeDiscarded(); discarded != "telemetry: 1" {
+		t.Errorf("Invalid discarded count %s", discarded)
+	}
+	if discarded := q.takeDiscarded(); discarded != "" {
+		t.Errorf("Discarded count not reset %s", discarded)
+	}
+}
+
+func TestSendQueueReplaced(t *testing.T) {
+	q := newSendQueue("")
+
+	q.push([]byte(`{"type":"AUTO_PING","data":1}`))
+	msg := q.peek()
+	// newer statistics arrive while sending
+	q.push([]byte(`{"type":"AUTO_PING","data":2}`))
+	q.remove(msg)
+
+	result := popAll(q)
+	if len(result) != 1 || result[0] != `{"type":"AUTO_PING","data":2}` {
+		t.Errorf("Newer message lost %v", result)
+	}
+}
+
+func TestSendQueueBulkLimit(t *testing.T) {
+	q := newSendQueue("")
+
+	for i := 0; i < maxBulkMessages+5; i++ {
+		q.push([]byte(`{"type":"LOGGER"}`))
+	}
+	if result := popAll(q); len(result) != maxBulkMessages {
+		t.Errorf("Invalid queue length %d", len(result))
+	}
+	if discarded := q.takeDiscarded(); discarded != "logs: 5" {
+		t.Errorf("Invalid discarded count %s", discarded)
+	}
+}
+
+func TestSendQueuePersist(t *testing.T) {
+	dir := t.TempDir()
+
+	q := newSendQueue(dir)
+	if err := q.load(); err != nil {
+		t.Fatal(err)
+	}
+	q.push([]byte(`{"type":"WG_ROUTE_STATUS","data":1}`))
+	q.push([]byte(`{"type":"LOGGER"}`))
+	q.push([]byte(`{"type":"WG_ROUTE_STATUS","data":2}`))
+	q.push([]byte(`{"type":"UPDATE_AGENT_CONFIG","data":[{"data":{"preshared_key":"c2VjcmV0"}}]}`))
+	q.remove(q.peek())
+	q.close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("Invalid persisted files %v", files)
+	}
+
+	// Agent restart
+	q = newSendQueue(dir)
+	if err := q.load(); err != nil {
+		t.Fatal(err)
+	}
+	result := popAll(q)
+	if len(result) != 1 || result[0] != `{"type":"WG_ROUTE_STATUS","data":2}` {
+		t.Errorf("Invalid restored messages %v", result)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Sent messages were not removed %v", entries)
+	}
+}
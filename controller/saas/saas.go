@@ -1,10 +1,10 @@
 package saas
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -14,14 +14,16 @@ import (
 
 	"github.com/SyntropyNet/syntropy-agent/controller"
 	"github.com/SyntropyNet/syntropy-agent/internal/config"
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"github.com/SyntropyNet/syntropy-agent/pkg/proxy"
 	"github.com/SyntropyNet/syntropy-agent/pkg/pubip"
 	"github.com/SyntropyNet/syntropy-agent/pkg/state"
 	"github.com/gorilla/websocket"
 )
 
 const pkgName = "Saas Controller. "
-const reconnectDelay = 10000 // 10 seconds (in milliseconds)
+const queuePath = env.AgentConfigDir + "/outqueue"
 
 var heartbeatAcceptable = 45 * time.Second
 var heartbeatCheckPerion = heartbeatAcceptable / 3
@@ -43,9 +45,9 @@ type CloudController struct {
 	sync.Mutex
 	// I like the idea of being non blocking.
 	// If WSS has lost connection - all other services still are working.
-	// If they try to send on bad connection - Write will exit and allow services to continue working
-	// When connection is restored - services will send the newest data they have.
-	// And nobody cares about old data, that was not sent during the time the connection was lost.
+	// If they try to send on bad connection - Write will queue the message and allow services to continue working
+	// When connection is restored - replies are sent, and only the newest statistics are sent.
+	// Nobody cares about old statistics, that were not sent during the time the connection was lost.
 	state.StateMachine
 	// Cloud controller nees a sepparate looger.
 	// All other packages print logs to stdout (or a file maybe)
@@ -63,28 +65,42 @@ type CloudController struct {
 	lastHeartbeat     time.Time
 	connectionIsAlive bool
 	healthTimer       *time.Timer
+	// periodically checks if more preferred endpoint is back online
+	failbackTimer *time.Timer
+	// cloud controller endpoints and their health
+	endpoints *endpointList
+	// controller certificate verification and client certificate
+	tlsConfig *tls.Config
+	// called every time connection to controller is established
+	onConnect func()
 	// Info fields to send to cloud controller
-	url     string
 	token   string
 	version string
-	// buffered channel in order not to delay sender
-	messageQueue chan []byte
-	// bufferLimit configures when start discarding messages when cannot send to controller
-	queueLimit int
+	// prioritised outbound messages queue in order not to delay sender
+	queue *sendQueue
+	// Reports discarded messages count to controller upon reconnection.
+	// Is used only from sendLoop, and Write never blocks, so no dead-loop here.
+	remoteLog *logger.Logger
 }
 
 // New allocates instance of Software-As-A-Service
 // (aka WSS) controller
 func New() (controller.Controller, error) {
-	// validate URL early. No need to keep busy trying on invalid URLs
-	url := config.GetCloudURL()
-	_, err := net.LookupIP(url)
-	if err != nil {
-		return nil, err
-	}
+	// Controller endpoints are not resolved here. Agent should start even if DNS is not working yet.
+	// Unreachable endpoints are retried with backoff when connecting.
 	if config.GetAgentToken() == "" {
 		return nil, fmt.Errorf("SYNTROPY_AGENT_TOKEN is not set")
 	}
+	if len(config.GetControllerEndpoints()) == 0 {
+		return nil, fmt.Errorf("SYNTROPY_CONTROLLER_URL has no valid endpoints")
+	}
+
+	// Invalid certificates configuration is a fatal error
+	certFile, keyFile := config.GetClientCertificate()
+	tlsConfig, err := newTLSConfig(config.GetControllerCA(), config.GetControllerPins(), certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
 
 	if wssTimeout := config.GetWssTimeout(); wssTimeout > 0 {
 		heartbeatAcceptable = time.Duration(wssTimeout) * time.Second
@@ -93,26 +109,35 @@ func New() (controller.Controller, error) {
 
 	// Note: config package returns already validated values and no need to validate them here
 	cc := CloudController{
-		url:     url,
-		token:   config.GetAgentToken(),
-		version: config.GetVersion(),
+		endpoints: newEndpointList(config.GetControllerEndpoints()),
+		tlsConfig: tlsConfig,
+		token:     config.GetAgentToken(),
+		version:   config.GetVersion(),
 	}
 	cc.SetState(initialised)
 
 	// Prepeare health check timer
 	cc.healthTimer = time.AfterFunc(heartbeatCheckPerion, cc.healthcheck)
 	cc.healthTimer.Stop()
-
-	// allocate buffered channel
-	// need to experiment in different situation with values
-	// Lets start with 20 messages buffer and try not to fill it more than 80%
-	cc.messageQueue = make(chan []byte, 20)
-	cc.queueLimit = 4
+	cc.failbackTimer = time.AfterFunc(failbackPeriod, cc.failback)
+	cc.failbackTimer.Stop()
 
 	// Create new local logger for controller events
 	// I am using configured DebugLevel here, but actually
 	// only Errors and Warnings should be logged on this logger.
 	cc.log = logger.New(nil, config.GetDebugLevel(), os.Stdout)
+	cc.remoteLog = logger.New(&cc, logger.WarningLevel)
+
+	// Undelivered replies may be persisted and sent after restart
+	persistDir := ""
+	if config.QueuePersist() {
+		persistDir = queuePath
+	}
+	cc.queue = newSendQueue(persistDir)
+	err = cc.queue.load()
+	if err != nil {
+		cc.log.Error().Println(pkgName, "persisted messages load error:", err)
+	}
 
 	return &cc, nil
 }
@@ -129,6 +154,9 @@ func (cc *CloudController) Open() error {
 	cc.log.Info().Println(pkgName, "Connecting...")
 	cc.log.Info().Println(pkgName, "WebSocket timeout:", heartbeatAcceptable,
 		"  Check period:", heartbeatCheckPerion)
+	if p := controllerProxy(cc.endpoints.getCurrent()); p != nil {
+		cc.log.Info().Println(pkgName, "Using proxy", p.Redacted())
+	}
 
 	return cc.connect()
 }
@@ -136,8 +164,8 @@ func (cc *CloudController) Open() error {
 func (cc *CloudController) connect() (err error) {
 	cc.SetState(connecting)
 	cc.healthTimer.Stop()
+	cc.failbackTimer.Stop()
 
-	url := url.URL{Scheme: "wss", Host: cc.url, Path: "/"}
 	headers := http.Header(make(map[string][]string))
 
 	// Without these headers connection will be ignored silently
@@ -149,24 +177,48 @@ func (cc *CloudController) connect() (err error) {
 	headers.Set("x-agenttype", "Linux")
 	headers.Set("x-agentversion", cc.version)
 
+	dialer := websocket.Dialer{
+		Proxy:            proxy.ForRequest,
+		HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+		TLSClientConfig:  cc.tlsConfig,
+	}
+
 	for {
+		// Try the most preferred healthy endpoint.
+		// If all endpoints have failed - wait for the first one to come out of backoff
+		ep, delay := cc.endpoints.next()
+		if delay > 0 {
+			cc.log.Warning().Println(pkgName, "Reconnecting in ", delay)
+			time.Sleep(delay)
+		}
+
 		var resp *http.Response
 		var httpCode int
-		cc.ws, resp, err = websocket.DefaultDialer.Dial(url.String(), headers)
+		url := url.URL{Scheme: "wss", Host: ep.host, Path: "/"}
+		cc.ws, resp, err = dialer.Dial(url.String(), headers)
 		if err != nil {
 			if resp != nil {
 				httpCode = resp.StatusCode
 			}
-			cc.log.Error().Printf("%s ConnectionError: %s (HTTP: %d)\n", pkgName, err.Error(), httpCode)
-			// Add some randomised sleep, so if controller was down
-			// the reconnecting agents could DDOS the controller
-			delay := time.Duration(rand.Int31n(reconnectDelay)) * time.Millisecond
-			cc.log.Warning().Println(pkgName, "Reconnecting in ", delay)
-			time.Sleep(delay)
+			if reason := handshakeError(err); reason != "" {
+				// Reconnecting will not help here. Report clearly and retry rarely
+				backoff := cc.endpoints.rejected(ep)
+				cc.log.Error().Printf("%s TLS handshake with %s failed: %s. Check controller certificates configuration. Retrying in %s\n",
+					pkgName, ep.host, reason, backoff)
+				continue
+			}
+			cc.log.Error().Printf("%s ConnectionError %s: %s (HTTP: %d)\n", pkgName, ep.host, err.Error(), httpCode)
+			backoff := cc.endpoints.failed(ep)
+			cc.log.Debug().Println(pkgName, ep.host, "backoff", backoff)
 			continue
 		}
 
-		cc.log.Info().Println(pkgName, "Connected to controller", cc.ws.RemoteAddr())
+		cc.endpoints.connected(ep)
+		cc.log.Info().Println(pkgName, "Connected to controller", ep.host, cc.ws.RemoteAddr())
+		if len(cc.endpoints.preferred()) > 0 {
+			// Connected to a fallback endpoint. Check if preferred ones recover
+			cc.failbackTimer.Reset(failbackPeriod)
+		}
 		// Set ping/pong callbacks for link health monitoring
 		cc.ws.SetPingHandler(cc.pingHandler)
 		cc.ws.SetPongHandler(cc.pongHandler)
@@ -176,6 +228,9 @@ func (cc *CloudController) connect() (err error) {
 		cc.healthTimer.Reset(heartbeatCheckPerion)
 
 		cc.SetState(running)
+		if cc.onConnect != nil {
+			cc.onConnect()
+		}
 		break
 	}
 
@@ -216,6 +271,33 @@ func (cc *CloudController) healthcheck() {
 	cc.healthTimer.Reset(heartbeatCheckPerion)
 }
 
+// OnConnect sets callback, which is called every time connection to controller is established.
+// Must be set before Open.
+func (cc *CloudController) OnConnect(f func()) {
+	cc.onConnect = f
+}
+
+// failback reconnects to a more preferred endpoint, if it is reachable again
+func (cc *CloudController) failback() {
+	if cc.GetState() != running {
+		return
+	}
+
+	for _, ep := range cc.endpoints.preferred() {
+		err := ep.probe(cc.tlsConfig)
+		if err != nil {
+			cc.log.Debug().Println(pkgName, "preferred endpoint", ep.host, "is still unreachable", err)
+			continue
+		}
+		cc.log.Info().Println(pkgName, "Preferred endpoint", ep.host, "is reachable again. Reconnecting.")
+		cc.endpoints.recovered(ep)
+		cc.Reconnect()
+		return
+	}
+	// Schedule next failback check
+	cc.failbackTimer.Reset(failbackPeriod)
+}
+
 // updates last hearbeat time
 func (cc *CloudController) heartbeat() {
 	cc.connectionIsAlive = true
@@ -248,71 +330,62 @@ func (cc *CloudController) sendLoop() {
 	// In this application there are 2 senders:
 	// this function and CloudController.close, thus lock protection is needed
 
-	// Discarded packets count.
 	// Used to reduce debug prints when disconnected from controller
-	var discardCount uint64
-
-	// process all messages until channel is closed
-	for msg := range cc.messageQueue {
-		// retry loop in case of reconnection
-		for retry := true; retry; {
-			// Default is retry one.
-			// Note: do not put this in the for loop 3rd statement
-			// it is processed then after the loop
-			retry = false
-
-			// Respect controller state machine and act accordingly
-			controllerState := cc.GetState()
-			switch controllerState {
-			case stopped:
-				// controller is stopped already. Discard all messages and will exit on closed channel
-				if discardCount == 0 {
-					cc.log.Debug().Println(pkgName, "Controller is stopped. Discarding remaining messages.")
-				}
-				discardCount++
-
-			case initialised:
-				// This state should never happen. Print error and discard message
-				cc.log.Error().Println(pkgName, "Unexpected controller state (initialised) during runtime !")
-
-			case connecting, disconnected:
-				// Controller is reconnecting
-				// If channel is not almost full - try some delay and retry
-				// If channel is almost full - sadly we need to discard some messages
-				// TODO: think about smart messages discarding. Packet investigation or priority
-				if cap(cc.messageQueue)-len(cc.messageQueue) < cc.queueLimit {
-					if discardCount == 0 {
-						cc.log.Warning().Println(pkgName, "send queue almost full. Start discarding messages.")
-					}
-					discardCount++
-				} else {
-					retry = true
-					time.Sleep(50 * time.Millisecond)
-				}
-
-			case running:
-				// Upon reconnection print and reset discarded messages count
-				if discardCount > 0 {
-					cc.log.Warning().Println(pkgName, discardCount, "messages were discarded during offline.")
-					discardCount = 0
-				}
-
-				// Expected state. Send the message
-				cc.Lock()
-				err := cc.ws.WriteMessage(websocket.TextMessage, msg)
-				cc.Unlock()
-				if err != nil {
-					cc.log.Error().Println(pkgName, "Send error: ", err)
-					// Cannot send over wss connection.
-					// Terminate connection and reconnection will be done from Recv()
-					cc.SetState(disconnected)
-					cc.ws.Close()
-				}
-
-			default:
-				// Unsupported state? Print warning and discard message
-				cc.log.Warning().Println(pkgName, "Unsupported controller state", controllerState)
+	offline := false
+
+	for {
+		msg := cc.queue.peek()
+		if msg == nil {
+			// wait for new messages until queue is closed
+			if !cc.queue.wait() {
+				return
+			}
+			continue
+		}
+
+		// Respect controller state machine and act accordingly
+		controllerState := cc.GetState()
+		switch controllerState {
+		case stopped:
+			// controller is stopped already. Persisted messages will be sent after restart
+			cc.log.Debug().Println(pkgName, "Controller is stopped. Leaving remaining messages.")
+			return
+
+		case initialised, connecting, disconnected:
+			// Controller is not connected yet or is reconnecting. Messages are kept in queue.
+			// Queue itself takes care of discarding old statistics and logs.
+			if !offline {
+				cc.log.Warning().Println(pkgName, "Controller is offline. Queueing messages.")
+				offline = true
+			}
+			time.Sleep(50 * time.Millisecond)
+
+		case running:
+			// Upon reconnection report discarded messages count
+			offline = false
+			if discarded := cc.queue.takeDiscarded(); discarded != "" {
+				cc.log.Warning().Println(pkgName, "Discarded messages:", discarded)
+				cc.remoteLog.Warning().Println(pkgName, "Discarded messages:", discarded)
+			}
+
+			// Expected state. Send the message
+			cc.Lock()
+			err := cc.ws.WriteMessage(websocket.TextMessage, msg.data)
+			cc.Unlock()
+			if err != nil {
+				cc.log.Error().Println(pkgName, "Send error: ", err)
+				// Cannot send over wss connection. Message is kept and will be retried.
+				// Terminate connection and reconnection will be done from Recv()
+				cc.SetState(disconnected)
+				cc.ws.Close()
+			} else {
+				cc.queue.remove(msg)
 			}
+
+		default:
+			// Unsupported state? Print warning and discard message
+			cc.log.Warning().Println(pkgName, "Unsupported controller state", controllerState)
+			cc.queue.remove(msg)
 		}
 	}
 }
@@ -356,7 +429,13 @@ func (cc *CloudController) Write(b []byte) (n int, err error) {
 		return 0, ErrNotRunning
 	}
 
-	cc.messageQueue <- b
+	err = cc.queue.push(b)
+	if err == ErrNotRunning {
+		return 0, err
+	} else if err != nil {
+		// message is still queued in memory
+		cc.log.Error().Println(pkgName, "message persist error:", err)
+	}
 	return len(b), nil
 }
 
@@ -368,7 +447,7 @@ func (cc *CloudController) close(terminate bool) error {
 	}
 	if terminate {
 		cc.SetState(stopped)
-		close(cc.messageQueue)
+		cc.queue.close()
 	} else {
 		cc.SetState(disconnected)
 	}
@@ -393,6 +472,7 @@ func (cc *CloudController) close(terminate bool) error {
 // Close closes websocket connection to saas backend
 func (cc *CloudController) Close() error {
 	cc.healthTimer.Stop()
+	cc.failbackTimer.Stop()
 	return cc.close(true)
 }
 
@@ -401,3 +481,31 @@ func (cc *CloudController) Close() error {
 func (cc *CloudController) Reconnect() error {
 	return cc.close(false)
 }
+
+// controllerProxy returns proxy, used to reach controller. nil means direct connection.
+func controllerProxy(host string) *url.URL {
+	p, err := proxy.ForURL(&url.URL{Scheme: "https", Host: host})
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// CheckConnectivity checks if saas backend (or proxy, if used) is reachable (TCP connect)
+// Websocket state is not used, because it reacts to network changes very slowly
+func (cc *CloudController) CheckConnectivity() error {
+	host := cc.endpoints.getCurrent()
+	address := host
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "443")
+	}
+	if p := controllerProxy(host); p != nil {
+		address = proxy.HostPort(p)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
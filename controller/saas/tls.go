@@ -0,0 +1,103 @@
+package saas
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var errPinMismatch = errors.New("controller certificate does not match any pinned key")
+
+// newTLSConfig creates TLS configuration for controller connection.
+// caFile - private CA bundle (system CA store is used if empty)
+// pins - base64 encoded SHA-256 hashes of certificate public key
+// certFile, keyFile - client certificate for mutual TLS. Is used only if both files exist.
+func newTLSConfig(caFile string, pins []string, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("controller CA: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("controller CA: no certificates in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(pins) > 0 {
+		pinned := make(map[string]bool)
+		for _, pin := range pins {
+			pin = strings.TrimPrefix(pin, "sha256/")
+			hash, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("invalid controller certificate pin %s", pin)
+			}
+			pinned[pin] = true
+		}
+		// Pins are checked in addition to usual chain verification
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, cert := range cs.PeerCertificates {
+				if pinned[publicKeyPin(cert)] {
+					return nil
+				}
+			}
+			return errPinMismatch
+		}
+	}
+
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	switch {
+	case certErr == nil && keyErr == nil:
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %s", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case certErr == nil:
+		return nil, fmt.Errorf("client certificate %s is present, but key %s is missing", certFile, keyFile)
+	case keyErr == nil:
+		return nil, fmt.Errorf("client key %s is present, but certificate %s is missing", keyFile, certFile)
+	}
+
+	return cfg, nil
+}
+
+// publicKeyPin returns base64 encoded SHA-256 hash of certificate public key
+func publicKeyPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// handshakeError returns the reason, if connection failed because of TLS handshake.
+// Such errors will not go away on reconnect and need a configuration fix.
+func handshakeError(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, errPinMismatch):
+		return "certificate does not match pinned keys (SYNTROPY_CONTROLLER_PINS)"
+	case errors.As(err, &unknownAuthority):
+		return "certificate signed by unknown authority (SYNTROPY_CONTROLLER_CA)"
+	case errors.As(err, &hostname):
+		return "certificate is not valid for controller host name"
+	case errors.As(err, &invalid):
+		return "invalid certificate: " + invalid.Error()
+	case strings.Contains(err.Error(), "remote error: tls:"):
+		// Controller sent TLS alert. Most probably it did not accept client certificate
+		return "controller rejected connection (client certificate): " + err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package saas
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func dialTLS(addr string, cfg *tls.Config) error {
+	cfg = cfg.Clone()
+	cfg.ServerName = "example.com"
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err == nil {
+		conn.Close()
+	}
+	return err
+}
+
+func TestTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	cert := server.Certificate()
+	addr := server.Listener.Addr().String()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noCert := filepath.Join(dir, "client.crt")
+	noKey := filepath.Join(dir, "client.key")
+
+	// System CA store does not trust test server
+	cfg, err := newTLSConfig("", nil, noCert, noKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dialTLS(addr, cfg)
+	if reason := handshakeError(err); reason == "" {
+		t.Errorf("Unknown authority not detected: %v", err)
+	}
+
+	// Private CA and matching pin
+	cfg, err = newTLSConfig(caFile, []string{"sha256/" + publicKeyPin(cert)}, noCert, noKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dialTLS(addr, cfg)
+	if err != nil {
+		t.Errorf("Pinned connection failed %s", err)
+	}
+
+	// Pin mismatch
+	cfg, err = newTLSConfig(caFile, []string{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}, noCert, noKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dialTLS(addr, cfg)
+	if reason := handshakeError(err); reason == "" {
+		t.Errorf("Pin mismatch not detected: %v", err)
+	}
+
+	// Invalid configurations
+	if _, err = newTLSConfig(caFile, []string{"invalid"}, noCert, noKey); err == nil {
+		t.Errorf("Invalid pin accepted")
+	}
+	if _, err = newTLSConfig(caFile, nil, caFile, noKey); err == nil {
+		t.Errorf("Client certificate without key accepted")
+	}
+}
@@ -0,0 +1,57 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+// outbox writes every outgoing message to a separate file.
+// File name is `<timestamp>-<sequence>-<message type>.json`, so files sort in sending order.
+type outbox struct {
+	sync.Mutex
+	dir      string
+	sequence uint
+}
+
+func newOutbox(dir string) (*outbox, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("outbox %s: %s", dir, err)
+	}
+	return &outbox{dir: dir}, nil
+}
+
+func (o *outbox) Write(b []byte) (int, error) {
+	var header struct {
+		MsgType string `json:"type"`
+	}
+	if json.Unmarshal(b, &header) != nil || header.MsgType == "" {
+		header.MsgType = "UNKNOWN"
+	}
+
+	o.Lock()
+	o.sequence++
+	name := fmt.Sprintf("%s-%06d-%s.json",
+		time.Now().UTC().Format("20060102T150405.000000000Z"), o.sequence, header.MsgType)
+	o.Unlock()
+
+	// Write to temporary (hidden) file and rename it.
+	// This way readers never see partially written messages.
+	tmpPath := filepath.Join(o.dir, "."+name)
+	err := os.WriteFile(tmpPath, b, 0600)
+	if err == nil {
+		err = os.Rename(tmpPath, filepath.Join(o.dir, name))
+	}
+	if err != nil {
+		logger.Error().Println(pkgName, "outbox write", err)
+		return 0, err
+	}
+
+	return len(b), nil
+}
@@ -0,0 +1,66 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutbox(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	o, err := newOutbox(dir)
+	if err != nil {
+		t.Fatalf("Outbox create failed: %s", err)
+	}
+	// Watcher sees only complete (renamed) files, never temporary ones
+	w, err := newWatcher(dir)
+	if err != nil {
+		t.Fatalf("Watcher create failed: %s", err)
+	}
+	defer w.Close()
+
+	messages := []string{
+		`{"id":"1","type":"AUTO_PING"}`,
+		`{"id":"2","type":"IFACES_PEERS_BW_DATA"}`,
+		`not json`,
+		`{"id":"4","type":"AUTO_PING"}`,
+	}
+	types := []string{"AUTO_PING", "IFACES_PEERS_BW_DATA", "UNKNOWN", "AUTO_PING"}
+	for _, msg := range messages {
+		n, err := o.Write([]byte(msg))
+		if err != nil || n != len(msg) {
+			t.Fatalf("Outbox write failed %d %v", n, err)
+		}
+	}
+
+	for i := range messages {
+		name := nextFile(t, w)
+		if !strings.HasSuffix(name, "-"+types[i]+".json") {
+			t.Errorf("Unexpected file %s, expected %s", name, types[i])
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != len(messages) {
+		t.Fatalf("Unexpected outbox files %v", names)
+	}
+	// ReadDir sorts by name, thus files must sort in sending order
+	for i, name := range names {
+		if !watchedFile(name) || !strings.HasSuffix(name, fmt.Sprintf("-%06d-%s.json", i+1, types[i])) {
+			t.Errorf("Unexpected file %s, expected %s", name, types[i])
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != messages[i] {
+			t.Errorf("Outbox file %s content mismatch: %s %v", name, data, err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/SyntropyNet/syntropy-agent/controller"
+	"github.com/SyntropyNet/syntropy-agent/internal/config"
 	"github.com/SyntropyNet/syntropy-agent/internal/env"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 )
@@ -18,15 +19,22 @@ const pkgName = "ScriptController. "
 
 // Script controller just reads files from
 // `/etc/syntropy-agent/script` directory and setups accordinly
+// In watch mode it continues receiving new or changed JSON files from the same directory
+// and writes agent messages to outbox directory
 type ScriptController struct {
 	list    []string
 	index   int
 	timeout time.Duration
 	ctx     context.Context
 	cancel  context.CancelFunc
+	watcher *watcher
+	outbox  *outbox
 }
 
-const scriptPath = env.AgentConfigDir + "/script"
+const (
+	scriptPath = env.AgentConfigDir + "/script"
+	outboxPath = env.AgentConfigDir + "/outbox"
+)
 
 // NewAgent allocates instance of agent struct
 // Parses shell environment and setups internal variables
@@ -49,6 +57,19 @@ func New() (controller.Controller, error) {
 		cc.list = strings.Split(string(script), "\n")
 	}
 
+	if config.ScriptWatch() {
+		cc.outbox, err = newOutbox(outboxPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialise script controller: %s", err.Error())
+		}
+		// Start watching before initial script is processed, so no files are missed
+		cc.watcher, err = newWatcher(scriptPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialise script controller: %s", err.Error())
+		}
+		logger.Info().Println(pkgName, "Watching", scriptPath, "for new messages.")
+	}
+
 	return &cc, nil
 }
 
@@ -92,6 +113,10 @@ func (cc *ScriptController) Recv() ([]byte, error) {
 		return msg, nil
 	}
 
+	if cc.watcher != nil {
+		return cc.recvWatched()
+	}
+
 	// When no more configuration scripts are left - just block the Recv
 	// and keep agent waiting
 	logger.Debug().Println(pkgName, "No more messages.")
@@ -100,8 +125,35 @@ func (cc *ScriptController) Recv() ([]byte, error) {
 	return nil, io.EOF
 }
 
-// Write sends nowhere
+// recvWatched waits for new or changed files in script directory
+func (cc *ScriptController) recvWatched() ([]byte, error) {
+	for {
+		select {
+		case <-cc.ctx.Done():
+			logger.Debug().Println(pkgName, "EOF")
+			return nil, io.EOF
+		case fname, ok := <-cc.watcher.files:
+			if !ok {
+				// inotify failed. Keep agent waiting, same as without watch mode
+				<-cc.ctx.Done()
+				return nil, io.EOF
+			}
+			msg, err := ioutil.ReadFile(scriptPath + "/" + fname)
+			if err != nil {
+				logger.Error().Printf("%s File %s: %s", pkgName, fname, err.Error())
+				continue
+			}
+			logger.Debug().Printf("%s Receiving \"%s\"\n", pkgName, fname)
+			return msg, nil
+		}
+	}
+}
+
+// Write sends nowhere, unless outbox is used (watch mode)
 func (cc *ScriptController) Write(b []byte) (n int, err error) {
+	if cc.outbox != nil {
+		return cc.outbox.Write(b)
+	}
 	return len(b), nil
 }
 
@@ -109,5 +161,8 @@ func (cc *ScriptController) Write(b []byte) (n int, err error) {
 func (cc *ScriptController) Close() error {
 	logger.Info().Println(pkgName, "Closing.")
 	cc.cancel()
+	if cc.watcher != nil {
+		cc.watcher.Close()
+	}
 	return nil
 }
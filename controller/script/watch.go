@@ -0,0 +1,101 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"golang.org/x/sys/unix"
+)
+
+// watcher reports names of new or changed JSON files in a directory (using inotify)
+type watcher struct {
+	file  *os.File
+	files chan string
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newWatcher(dir string) (*watcher, error) {
+	// Non-blocking descriptor is served by Go runtime poller,
+	// so pending Read is interrupted by Close
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %s", err)
+	}
+	// Files, written in place, are complete on close.
+	// Files, written elsewhere and moved in (atomic write), are complete on move.
+	_, err = unix.InotifyAddWatch(fd, dir, unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("inotify watch %s: %s", dir, err)
+	}
+
+	w := &watcher{
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		files: make(chan string, 16),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+
+	return w, nil
+}
+
+func (w *watcher) run() {
+	defer close(w.files)
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				logger.Error().Println(pkgName, "inotify read", err)
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start := offset + unix.SizeofInotifyEvent
+			offset = start + int(event.Len)
+			if offset > n {
+				break
+			}
+
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				logger.Warning().Println(pkgName, "inotify queue overflow. Some files were missed.")
+				continue
+			}
+			if event.Mask&unix.IN_ISDIR != 0 {
+				continue
+			}
+			name := strings.TrimRight(string(buf[start:offset]), "\x00")
+			if !watchedFile(name) {
+				continue
+			}
+			select {
+			case w.files <- name:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// watchedFile filters out non JSON files and hidden (e.g. editor temporary) files
+func watchedFile(name string) bool {
+	return name != "" && name[0] != '.' && filepath.Ext(name) == ".json"
+}
+
+func (w *watcher) Close() (err error) {
+	w.once.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+	})
+	return err
+}
@@ -0,0 +1,74 @@
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchedFile(t *testing.T) {
+	tests := map[string]bool{
+		"config.json":       true,
+		"0001-WG_CONF.json": true,
+		".config.json":      false,
+		"config.json.swp":   false,
+		"config.txt":        false,
+		"json":              false,
+		"":                  false,
+	}
+	for name, watched := range tests {
+		if watchedFile(name) != watched {
+			t.Errorf("watchedFile(%q) != %v", name, watched)
+		}
+	}
+}
+
+// nextFile waits for watcher reported file
+func nextFile(t *testing.T, w *watcher) string {
+	t.Helper()
+	select {
+	case name := <-w.files:
+		return name
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watcher did not report file")
+		return ""
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	w, err := newWatcher(dir)
+	if err != nil {
+		t.Fatalf("Watcher create failed: %s", err)
+	}
+	defer w.Close()
+
+	// Ignored files are not reported
+	os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("{}"), 0600)
+	os.WriteFile(filepath.Join(dir, ".hidden.json"), []byte("{}"), 0600)
+	os.Mkdir(filepath.Join(dir, "subdir.json"), 0700)
+
+	// New file and changed file are reported
+	path := filepath.Join(dir, "message.json")
+	os.WriteFile(path, []byte(`{"type":"WG_CONF"}`), 0600)
+	if name := nextFile(t, w); name != "message.json" {
+		t.Errorf("Unexpected new file %s", name)
+	}
+	os.WriteFile(path, []byte(`{"type":"CONFIG_INFO"}`), 0600)
+	if name := nextFile(t, w); name != "message.json" {
+		t.Errorf("Unexpected changed file %s", name)
+	}
+
+	// Moved in file is reported
+	os.WriteFile(filepath.Join(dir, ".moved.json"), []byte("{}"), 0600)
+	os.Rename(filepath.Join(dir, ".moved.json"), filepath.Join(dir, "moved.json"))
+	if name := nextFile(t, w); name != "moved.json" {
+		t.Errorf("Unexpected moved file %s", name)
+	}
+
+	// Close stops watcher
+	w.Close()
+	for range w.files {
+	}
+}
@@ -5,40 +5,44 @@ import (
 	"strings"
 )
 
-func initAgentName() {
+func (c *configCache) initAgentName() {
 	var err error
-	cache.agentName = os.Getenv("SYNTROPY_AGENT_NAME")
+	c.agentName = getenv("SYNTROPY_AGENT_NAME")
 
-	if cache.agentName != "" {
+	if c.agentName != "" {
 		return
 	}
 
 	// Fallback to hostname, if shell variable `SYNTROPY_AGENT_NAME` is missing
-	cache.agentName, err = os.Hostname()
+	c.agentName, err = os.Hostname()
 	if err != nil {
 		// Should hever happen, but its a good practice to handle all errors
-		cache.agentName = "UnknownSyntropyAgent"
+		c.agentName = "UnknownSyntropyAgent"
 	}
 }
 
-func initAgentTags() {
-	tags := strings.Split(os.Getenv("SYNTROPY_TAGS"), ",")
+func (c *configCache) initAgentTags() {
+	tags := strings.Split(getenv("SYNTROPY_TAGS"), ",")
 	for _, v := range tags {
 		if len(v) > 0 {
-			cache.agentTags = append(cache.agentTags, v)
+			c.agentTags = append(c.agentTags, v)
 		}
 	}
 }
 
-func initControllerType() {
-	switch strings.ToLower(os.Getenv("SYNTROPY_CONTROLLER_TYPE")) {
+func (c *configCache) initControllerType() {
+	switch strings.ToLower(getenv("SYNTROPY_CONTROLLER_TYPE")) {
 	case "saas":
-		cache.controllerType = ControllerSaas
+		c.controllerType = ControllerSaas
 	case "script":
-		cache.controllerType = ControllerScript
+		c.controllerType = ControllerScript
 	case "blockchain":
-		cache.controllerType = ControllerBlockchain
+		c.controllerType = ControllerBlockchain
+	case "replay":
+		c.controllerType = ControllerReplay
+	case "local":
+		c.controllerType = ControllerLocal
 	default:
-		cache.controllerType = ControllerSaas
+		c.controllerType = ControllerSaas
 	}
 }
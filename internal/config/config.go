@@ -1,5 +1,7 @@
 package config
 
+import "sync"
+
 const pkgName = "SyntropyAgentConfig. "
 
 type Location struct {
@@ -7,6 +9,12 @@ type Location struct {
 	Longitude float32
 }
 
+// ControllerEndpoint is a cloud controller address. Endpoints with higher weight are preferred.
+type ControllerEndpoint struct {
+	Host   string
+	Weight uint
+}
+
 type AllowedIPEntry struct {
 	Name   string
 	Subnet string
@@ -16,14 +24,27 @@ type AllowedIPEntry struct {
 // some of them are exported shell variables, some are parsed from OS settings
 // Some may be generated.
 // Cache them and use from here
+// Settings, that may change at runtime (SET_SETTINGS, configuration file reload),
+// are read and written under the lock. Others are written only once on Init.
 type configCache struct {
+	sync.RWMutex
+
 	apiKey         string // aka AGENT_TOKEN
 	cloudURL       string
+	endpoints      []ControllerEndpoint
 	deviceID       string
 	ownerAddress   string // aka OWNER_ADDRESS
 	ipfsURL        string
 	controllerType int
+	scriptWatch    bool
+	recordJournal  bool
+	journalFile    string
+	replaySpeed    float32
+	queuePersist   bool
+	proxy          string
+	noProxy        string
 	exporterPort   uint16
+	bwprobePort    uint16
 
 	agentName      string
 	agentProvider  uint
@@ -36,15 +57,36 @@ type configCache struct {
 	}
 	mtu                 uint
 	createIptablesRules bool
+	packetFilter        int
+	wireguardMode       int
 
 	debugLevel           int
 	location             Location
 	containerType        string
 	kubernetesNamespaces []string
 	cleanupOnExit        bool
+	stateSnapshot        bool
+	dryRun               bool
+	applyRollback        bool
+	routeMultipath       bool
 	vpnClient            bool
 
-	allowedIPs []AllowedIPEntry
+	allowedIPs            []AllowedIPEntry
+	hostServicesDiscovery bool
+
+	localController struct {
+		address  string
+		token    string
+		certFile string
+		keyFile  string
+	}
+
+	controllerTLS struct {
+		caFile   string
+		pins     []string
+		certFile string
+		keyFile  string
+	}
 
 	rerouteThresholds struct {
 		diff  float32
@@ -58,6 +100,7 @@ type configCache struct {
 	}
 	routeDelThreshold uint
 	routeStrategy     int
+	multipathBand     uint
 }
 
 var cache configCache
@@ -9,7 +9,7 @@ import (
 	"github.com/SyntropyNet/syntropy-agent/pkg/pubip"
 )
 
-func initDeviceID() {
+func (c *configCache) initDeviceID() {
 	productUUID := func() (string, error) {
 		data, err := ioutil.ReadFile("/sys/class/dmi/id/product_uuid")
 		if err != nil {
@@ -67,5 +67,5 @@ func initDeviceID() {
 	  Noted that the changes is only case change UPPER<->lower case.
 	  Lowercasing Device IDs solves this problem for deployment.
 	**/
-	cache.deviceID = strings.ToLower(devID)
+	c.deviceID = strings.ToLower(devID)
 }
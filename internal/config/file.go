@@ -0,0 +1,262 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// Optional YAML configuration file. Environment variables override file settings.
+const defaultConfigFile = env.AgentConfigDir + "/config.yaml"
+
+// All settings, that may be configured in file.
+// File key is lowercase variable name without `SYNTROPY_` prefix (e.g. `log_level`).
+var fileSettings = []string{
+	"SYNTROPY_AGENT_TOKEN", "SYNTROPY_API_KEY", "SYNTROPY_AGENT_NAME", "SYNTROPY_PROVIDER",
+	"SYNTROPY_TAGS", "SYNTROPY_SERVICES_STATUS", "SYNTROPY_LAT", "SYNTROPY_LON",
+	"SYNTROPY_CONTROLLER_TYPE", "SYNTROPY_CONTROLLER_URL", "SYNTROPY_CONTROLLER_CA",
+	"SYNTROPY_CONTROLLER_PINS", "SYNTROPY_CLIENT_CERT", "SYNTROPY_CLIENT_KEY",
+	"SYNTROPY_IPFS_URL", "SYNTROPY_OWNER_ADDRESS", "SYNTROPY_PROXY", "SYNTROPY_NO_PROXY",
+	"SYNTROPY_LOCAL_ADDRESS", "SYNTROPY_LOCAL_TOKEN", "SYNTROPY_LOCAL_CERT", "SYNTROPY_LOCAL_KEY",
+	"SYNTROPY_SCRIPT_WATCH", "SYNTROPY_RECORD", "SYNTROPY_JOURNAL_FILE", "SYNTROPY_REPLAY_SPEED",
+	"SYNTROPY_QUEUE_PERSIST", "SYNTROPY_WSS_TIMEOUT", "SYNTROPY_LOG_LEVEL",
+	"SYNTROPY_NETWORK_API", "SYNTROPY_NAMESPACE", "SYNTROPY_ALLOWED_IPS",
+	"SYNTROPY_HOST_SERVICES_DISCOVERY", "SYNTROPY_MTU", "SYNTROPY_PORT_RANGE", "VPN_CLIENT",
+	"SYNTROPY_CREATE_IPTABLES_RULES", "SYNTROPY_PACKET_FILTER", "SYNTROPY_WIREGUARD",
+	"SYNTROPY_CLEANUP_ON_EXIT",
+	"SYNTROPY_STATE_SNAPSHOT", "SYNTROPY_DRY_RUN", "SYNTROPY_APPLY_ROLLBACK",
+	"SYNTROPY_EXPORTER_PORT", "SYNTROPY_BWPROBE_PORT", "SYNTROPY_PEERCHECK_TIME",
+	"SYNTROPY_PEERCHECK_WINDOW", "SYNTROPY_ROUTEDEL_THRESHOLD", "SYNTROPY_ROUTE_STRATEGY",
+	"SYNTROPY_ROUTE_MULTIPATH", "SYNTROPY_MULTIPATH_BAND",
+}
+
+// Settings, that are applied without agent restart.
+// Components read them on every use, or are reconfigured by agent after reload.
+// parse reads setting into a configuration, apply copies it to the active one.
+var liveSettings = map[string]struct {
+	parse func(c *configCache)
+	apply func(dst, src *configCache)
+}{
+	"SYNTROPY_LOG_LEVEL": {
+		(*configCache).initDebugLevel,
+		func(dst, src *configCache) { dst.debugLevel = src.debugLevel },
+	},
+	"SYNTROPY_TAGS": {
+		(*configCache).initAgentTags,
+		func(dst, src *configCache) { dst.agentTags = src.agentTags },
+	},
+	"SYNTROPY_SERVICES_STATUS": {
+		func(c *configCache) { initBool(&c.servicesStatus, "SYNTROPY_SERVICES_STATUS", false) },
+		func(dst, src *configCache) { dst.servicesStatus = src.servicesStatus },
+	},
+	"SYNTROPY_LAT": {
+		(*configCache).initLocation,
+		func(dst, src *configCache) { dst.location.Latitude = src.location.Latitude },
+	},
+	"SYNTROPY_LON": {
+		(*configCache).initLocation,
+		func(dst, src *configCache) { dst.location.Longitude = src.location.Longitude },
+	},
+	"SYNTROPY_ALLOWED_IPS": {
+		(*configCache).initAllowedIPs,
+		func(dst, src *configCache) { dst.allowedIPs = src.allowedIPs },
+	},
+	"SYNTROPY_HOST_SERVICES_DISCOVERY": {
+		func(c *configCache) { initBool(&c.hostServicesDiscovery, "SYNTROPY_HOST_SERVICES_DISCOVERY", false) },
+		func(dst, src *configCache) { dst.hostServicesDiscovery = src.hostServicesDiscovery },
+	},
+	"SYNTROPY_PEERCHECK_TIME": {
+		(*configCache).initPeerCheckTime,
+		func(dst, src *configCache) { dst.times.peerMonitor = src.times.peerMonitor },
+	},
+	"SYNTROPY_PEERCHECK_WINDOW": {
+		(*configCache).initPeerCheckWindow,
+		func(dst, src *configCache) { dst.times.rerouteWindow = src.times.rerouteWindow },
+	},
+	"SYNTROPY_ROUTE_STRATEGY": {
+		(*configCache).initRouteStrategy,
+		func(dst, src *configCache) { dst.routeStrategy = src.routeStrategy },
+	},
+	"SYNTROPY_ROUTEDEL_THRESHOLD": {
+		func(c *configCache) { initUint(&c.routeDelThreshold, "SYNTROPY_ROUTEDEL_THRESHOLD", 0) },
+		func(dst, src *configCache) { dst.routeDelThreshold = src.routeDelThreshold },
+	},
+	"SYNTROPY_MULTIPATH_BAND": {
+		func(c *configCache) { initUint(&c.multipathBand, "SYNTROPY_MULTIPATH_BAND", 20) },
+		func(dst, src *configCache) { dst.multipathBand = src.multipathBand },
+	},
+	"SYNTROPY_EXPORTER_PORT": {
+		(*configCache).initExporterPort,
+		func(dst, src *configCache) { dst.exporterPort = src.exporterPort },
+	},
+}
+
+var file struct {
+	sync.Mutex
+	path   string
+	values map[string]string
+}
+
+// loadFile reads configuration file on startup.
+// Invalid file is ignored and only environment variables are used.
+func loadFile() {
+	file.path = os.Getenv("SYNTROPY_CONFIG_FILE")
+	if file.path == "" {
+		file.path = defaultConfigFile
+	}
+
+	values, err := readFile(file.path)
+	if err != nil {
+		logger.Error().Println(pkgName, "Configuration file ignored:", err)
+		values = make(map[string]string)
+	}
+	file.values = values
+}
+
+// getenv returns environment variable value.
+// If variable is not set - value from configuration file is used.
+func getenv(name string) string {
+	if val := os.Getenv(name); val != "" {
+		return val
+	}
+	return file.values[name]
+}
+
+func fileKey(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, "SYNTROPY_"))
+}
+
+// fileValue converts YAML value to environment variable format:
+// lists are comma separated, complex values (e.g. allowed IPs) are JSON encoded
+func fileValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case bool, int, uint, int64, uint64, float32, float64:
+		return fmt.Sprint(val), nil
+	case []interface{}:
+		items := []string{}
+		for _, item := range val {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				raw, err := json.Marshal(val)
+				return string(raw), err
+			}
+			str, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, str)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		raw, err := json.Marshal(val)
+		return string(raw), err
+	}
+}
+
+// readFile parses configuration file. Missing file is not an error.
+func readFile(path string) (map[string]string, error) {
+	values := make(map[string]string)
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	err = yaml.Unmarshal(raw, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	known := make(map[string]string)
+	for _, name := range fileSettings {
+		known[fileKey(name)] = name
+	}
+
+	for k, v := range doc {
+		name, ok := known[strings.ToLower(k)]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown setting %s", path, k)
+		}
+		values[name], err = fileValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %s", path, k, err)
+		}
+	}
+
+	return values, nil
+}
+
+// ReloadResult describes settings, changed in configuration file
+type ReloadResult struct {
+	// Settings applied at runtime
+	Applied []string
+	// Changed settings, that take effect only after agent restart
+	Restart []string
+	// Changed settings, that are overridden by environment variables
+	Overridden []string
+}
+
+// Reload rereads configuration file and applies settings, that may be changed at runtime.
+// Configuration is not changed, if file is invalid.
+func Reload() (*ReloadResult, error) {
+	file.Lock()
+	defer file.Unlock()
+
+	values, err := readFile(file.path)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &ReloadResult{}
+	var changed []string
+	for _, name := range fileSettings {
+		if values[name] == file.values[name] {
+			continue
+		}
+		if os.Getenv(name) != "" {
+			rv.Overridden = append(rv.Overridden, name)
+			continue
+		}
+		changed = append(changed, name)
+	}
+	file.values = values
+
+	// Only changed live settings are parsed. Full init would also redo
+	// device ID lookup, controller endpoints parsing, etc.
+	var next configCache
+	for _, name := range changed {
+		if setting, ok := liveSettings[name]; ok {
+			setting.parse(&next)
+			cache.Lock()
+			setting.apply(&cache, &next)
+			cache.Unlock()
+			rv.Applied = append(rv.Applied, name)
+		} else {
+			rv.Restart = append(rv.Restart, name)
+		}
+	}
+	sort.Strings(rv.Applied)
+	sort.Strings(rv.Restart)
+
+	return rv, nil
+}
+
+// ConfigFile returns configuration file path
+func ConfigFile() string {
+	return file.path
+}
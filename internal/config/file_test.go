@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
+)
+
+func TestConfigFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("SYNTROPY_CONFIG_FILE", path)
+	t.Setenv("SYNTROPY_AGENT_NAME", "from-env")
+
+	err := os.WriteFile(path, []byte(`
+agent_name: from-file
+tags: [db, eu]
+log_level: error
+mtu: 1400
+allowed_ips:
+  - 10.0.0.0/8: lan
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Init()
+
+	if GetAgentName() != "from-env" {
+		t.Errorf("Environment must override file. Got %s", GetAgentName())
+	}
+	if !reflect.DeepEqual(GetAgentTags(), []string{"db", "eu"}) {
+		t.Errorf("Invalid tags %v", GetAgentTags())
+	}
+	if GetDebugLevel() != logger.ErrorLevel || GetInterfaceMTU() != 1400 {
+		t.Errorf("Invalid file settings %d %d", GetDebugLevel(), GetInterfaceMTU())
+	}
+	if ips := GetHostAllowedIPs(); len(ips) != 1 || ips[0].Subnet != "10.0.0.0/8" || ips[0].Name != "lan" {
+		t.Errorf("Invalid allowed IPs %v", ips)
+	}
+
+	err = os.WriteFile(path, []byte(`
+agent_name: other
+tags: [db]
+log_level: debug
+mtu: 1300
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := &ReloadResult{
+		Applied:    []string{"SYNTROPY_ALLOWED_IPS", "SYNTROPY_LOG_LEVEL", "SYNTROPY_TAGS"},
+		Restart:    []string{"SYNTROPY_MTU"},
+		Overridden: []string{"SYNTROPY_AGENT_NAME"},
+	}
+	if !reflect.DeepEqual(res, expect) {
+		t.Errorf("Invalid reload result %+v", res)
+	}
+	if !reflect.DeepEqual(GetAgentTags(), []string{"db"}) || GetDebugLevel() != logger.DebugLevel ||
+		len(GetHostAllowedIPs()) != 0 || GetInterfaceMTU() != 1400 {
+		t.Errorf("Invalid reloaded configuration")
+	}
+
+	os.WriteFile(path, []byte("unknown_setting: 1\n"), 0600)
+	_, err = Reload()
+	if err == nil || GetDebugLevel() != logger.DebugLevel {
+		t.Errorf("Invalid file must not change configuration")
+	}
+}
+
+func TestConfigFileReloadConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("SYNTROPY_CONFIG_FILE", path)
+	Init()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			os.WriteFile(path, []byte(fmt.Sprintf("tags: [t%d]\npeercheck_time: %d\n", i, i%60+1)), 0600)
+			if _, err := Reload(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			if !reflect.DeepEqual(GetAgentTags(), []string{"t99"}) || PeerCheckTime() != 40*time.Second {
+				t.Errorf("Invalid reloaded configuration %v %s", GetAgentTags(), PeerCheckTime())
+			}
+			return
+		default:
+			GetAgentTags()
+			PeerCheckTime()
+		}
+	}
+}
@@ -1,12 +1,11 @@
 package config
 
 import (
-	"os"
 	"strconv"
 )
 
 func initUint(variable *uint, name string, defaultValue uint) {
-	str := os.Getenv(name)
+	str := getenv(name)
 	val, err := strconv.Atoi(str)
 	if len(str) == 0 || err != nil {
 		*variable = defaultValue
@@ -16,7 +15,7 @@ func initUint(variable *uint, name string, defaultValue uint) {
 }
 
 func initBool(variable *bool, name string, defaultValue bool) {
-	str := os.Getenv(name)
+	str := getenv(name)
 	val, err := strconv.ParseBool(str)
 	if len(str) == 0 || err != nil {
 		*variable = defaultValue
@@ -26,7 +25,7 @@ func initBool(variable *bool, name string, defaultValue bool) {
 }
 
 func initString(variable *string, name string, defaultValue string) {
-	str := os.Getenv(name)
+	str := getenv(name)
 	if len(str) == 0 {
 		*variable = defaultValue
 		return
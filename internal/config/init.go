@@ -1,117 +1,198 @@
 package config
 
 import (
-	"os"
 	"strconv"
 	"strings"
 
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
 	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 )
 
 const maxPort = 65535
 
 func Init() {
+	loadFile()
+	cache.init()
+}
+
+func (c *configCache) init() {
 	var tmpval uint
 
-	initString(&cache.apiKey, "SYNTROPY_AGENT_TOKEN", "")
-	if cache.apiKey == "" {
+	initString(&c.apiKey, "SYNTROPY_AGENT_TOKEN", "")
+	if c.apiKey == "" {
 		// Fallback. This was used on older agent versions
-		initString(&cache.apiKey, "SYNTROPY_API_KEY", "")
+		initString(&c.apiKey, "SYNTROPY_API_KEY", "")
 	}
-	initControllerType()
-	initString(&cache.cloudURL, "SYNTROPY_CONTROLLER_URL",
-		"controller-prod-platform-agents.syntropystack.com")
-
-	initString(&cache.ipfsURL, "SYNTROPY_IPFS_URL", "localhost:5001")
-	initString(&cache.ownerAddress, "SYNTROPY_OWNER_ADDRESS", "")
+	c.initControllerType()
+	c.initControllerEndpoints()
 
-	initDebugLevel()
+	initString(&c.ipfsURL, "SYNTROPY_IPFS_URL", "localhost:5001")
+	initString(&c.ownerAddress, "SYNTROPY_OWNER_ADDRESS", "")
 
-	initUint(&cache.mtu, "SYNTROPY_MTU", 0)
-	initAgentName()
-	initUint(&cache.agentProvider, "SYNTROPY_PROVIDER", 0)
-	initAgentTags()
-	initBool(&cache.servicesStatus, "SYNTROPY_SERVICES_STATUS", false)
-	initPortsRange()
-	cache.containerType = strings.ToLower(os.Getenv("SYNTROPY_NETWORK_API"))
+	initUint(&c.mtu, "SYNTROPY_MTU", 0)
+	c.initAgentName()
+	initUint(&c.agentProvider, "SYNTROPY_PROVIDER", 0)
+	c.initPortsRange()
+	c.containerType = strings.ToLower(getenv("SYNTROPY_NETWORK_API"))
 
 	var k8sNamespaces string
 	initString(&k8sNamespaces, "SYNTROPY_NAMESPACE", "")
-	cache.kubernetesNamespaces = strings.Split(k8sNamespaces, ",")
-
-	initAllowedIPs()
-	initLocation()
-	initBool(&cache.vpnClient, "VPN_CLIENT", false)
-	initIptables()
-	initBool(&cache.cleanupOnExit, "SYNTROPY_CLEANUP_ON_EXIT", false)
-
-	initUint(&tmpval, "SYNTROPY_EXPORTER_PORT", 0)
+	c.kubernetesNamespaces = strings.Split(k8sNamespaces, ",")
+
+	initBool(&c.vpnClient, "VPN_CLIENT", false)
+	c.initIptables()
+	c.initPacketFilter()
+	c.initWireguardMode()
+	initBool(&c.cleanupOnExit, "SYNTROPY_CLEANUP_ON_EXIT", false)
+	initBool(&c.stateSnapshot, "SYNTROPY_STATE_SNAPSHOT", true)
+	initBool(&c.dryRun, "SYNTROPY_DRY_RUN", false)
+	initBool(&c.applyRollback, "SYNTROPY_APPLY_ROLLBACK", true)
+	initBool(&c.scriptWatch, "SYNTROPY_SCRIPT_WATCH", false)
+	initBool(&c.recordJournal, "SYNTROPY_RECORD", false)
+	initString(&c.journalFile, "SYNTROPY_JOURNAL_FILE", env.AgentConfigDir+"/journal.jsonl")
+	c.initReplaySpeed()
+	initBool(&c.queuePersist, "SYNTROPY_QUEUE_PERSIST", false)
+	initString(&c.proxy, "SYNTROPY_PROXY", "")
+	initString(&c.noProxy, "SYNTROPY_NO_PROXY", "")
+	c.initControllerTLS()
+	initString(&c.localController.address, "SYNTROPY_LOCAL_ADDRESS", "127.0.0.1:7878")
+	initString(&c.localController.token, "SYNTROPY_LOCAL_TOKEN", "")
+	initString(&c.localController.certFile, "SYNTROPY_LOCAL_CERT", "")
+	initString(&c.localController.keyFile, "SYNTROPY_LOCAL_KEY", "")
+
+	initUint(&tmpval, "SYNTROPY_BWPROBE_PORT", 0)
 	if tmpval <= maxPort {
-		cache.exporterPort = uint16(tmpval)
+		c.bwprobePort = uint16(tmpval)
 	}
 
-	initUint(&cache.times.peerMonitor, "SYNTROPY_PEERCHECK_TIME", 5)
-	if cache.times.peerMonitor < 1 {
-		cache.times.peerMonitor = 1
-	} else if cache.times.peerMonitor > 60 {
-		cache.times.peerMonitor = 60
-	}
-	initUint(&cache.times.rerouteWindow, "SYNTROPY_PEERCHECK_WINDOW", 24)
-	if cache.times.rerouteWindow < 1 {
-		cache.times.rerouteWindow = 1
-	}
-	initUint(&cache.routeDelThreshold, "SYNTROPY_ROUTEDEL_THRESHOLD", 0)
+	initBool(&c.routeMultipath, "SYNTROPY_ROUTE_MULTIPATH", false)
 
-	initUint(&cache.times.websocketTimeout, "SYNTROPY_WSS_TIMEOUT", 0)
+	initUint(&c.times.websocketTimeout, "SYNTROPY_WSS_TIMEOUT", 0)
 
-	initDeviceID()
+	c.initDeviceID()
 
 	// reroute thresholds used to compare better latency.
 	// Default values: diff >= 10ms and at least 10% better
-	cache.rerouteThresholds.diff = 10
-	cache.rerouteThresholds.ratio = 1.1
-	initRouteStrategy()
+	c.rerouteThresholds.diff = 10
+	c.rerouteThresholds.ratio = 1.1
+
+	// Settings, that may be reloaded, are parsed the same way on reload
+	for _, setting := range liveSettings {
+		setting.parse(c)
+	}
 }
 
 func Close() {
 	// Anything needed to be closed or destroyed at the end of program, goes here
 }
 
-func initLocation() {
-	val, err := strconv.ParseFloat(os.Getenv("SYNTROPY_LAT"), 32)
+func (c *configCache) initLocation() {
+	val, err := strconv.ParseFloat(getenv("SYNTROPY_LAT"), 32)
 	if err == nil {
-		cache.location.Latitude = float32(val)
+		c.location.Latitude = float32(val)
 	}
-	val, err = strconv.ParseFloat(os.Getenv("SYNTROPY_LON"), 32)
+	val, err = strconv.ParseFloat(getenv("SYNTROPY_LON"), 32)
 	if err == nil {
-		cache.location.Longitude = float32(val)
+		c.location.Longitude = float32(val)
+	}
+}
+
+func (c *configCache) initPeerCheckTime() {
+	initUint(&c.times.peerMonitor, "SYNTROPY_PEERCHECK_TIME", 5)
+	if c.times.peerMonitor < 1 {
+		c.times.peerMonitor = 1
+	} else if c.times.peerMonitor > maxPeerCheckTime {
+		c.times.peerMonitor = maxPeerCheckTime
+	}
+}
+
+func (c *configCache) initPeerCheckWindow() {
+	initUint(&c.times.rerouteWindow, "SYNTROPY_PEERCHECK_WINDOW", 24)
+	if c.times.rerouteWindow < 1 {
+		c.times.rerouteWindow = 1
+	}
+}
+
+func (c *configCache) initExporterPort() {
+	var port uint
+	initUint(&port, "SYNTROPY_EXPORTER_PORT", 0)
+	if port <= maxPort {
+		c.exporterPort = uint16(port)
 	}
 }
 
-func initDebugLevel() {
-	switch strings.ToUpper(os.Getenv("SYNTROPY_LOG_LEVEL")) {
+func (c *configCache) initReplaySpeed() {
+	c.replaySpeed = 1
+	val, err := strconv.ParseFloat(getenv("SYNTROPY_REPLAY_SPEED"), 32)
+	if err == nil && val >= 0 {
+		c.replaySpeed = float32(val)
+	}
+}
+
+func (c *configCache) initDebugLevel() {
+	level, ok := parseDebugLevel(getenv("SYNTROPY_LOG_LEVEL"))
+	if !ok {
+		level = logger.MessageLevel
+	}
+	c.debugLevel = level
+}
+
+func parseDebugLevel(str string) (int, bool) {
+	switch strings.ToUpper(str) {
 	case "DEBUG":
-		cache.debugLevel = logger.DebugLevel
+		return logger.DebugLevel, true
 	case "MESSAGE", "MSG":
-		cache.debugLevel = logger.MessageLevel
+		return logger.MessageLevel, true
 	case "INFO":
-		cache.debugLevel = logger.InfoLevel
+		return logger.InfoLevel, true
 	case "WARNING":
-		cache.debugLevel = logger.WarningLevel
+		return logger.WarningLevel, true
 	case "ERROR":
-		cache.debugLevel = logger.ErrorLevel
+		return logger.ErrorLevel, true
 	default:
-		cache.debugLevel = logger.MessageLevel
+		return 0, false
+	}
+}
+
+func (c *configCache) initPacketFilter() {
+	switch strings.ToLower(getenv("SYNTROPY_PACKET_FILTER")) {
+	case "iptables":
+		c.packetFilter = PacketFilterIptables
+	case "nftables":
+		c.packetFilter = PacketFilterNftables
+	default:
+		c.packetFilter = PacketFilterAuto
+	}
+}
+
+func (c *configCache) initWireguardMode() {
+	switch strings.ToLower(getenv("SYNTROPY_WIREGUARD")) {
+	case "kernel":
+		c.wireguardMode = WireguardKernel
+	case "userspace":
+		c.wireguardMode = WireguardUserspace
+	default:
+		c.wireguardMode = WireguardAuto
+	}
+}
+
+func (c *configCache) initRouteStrategy() {
+	strategy, ok := parseRouteStrategy(getenv("SYNTROPY_ROUTE_STRATEGY"))
+	if !ok {
+		strategy = RouteStrategySpeed
 	}
+	c.routeStrategy = strategy
 }
 
-func initRouteStrategy() {
-	switch strings.ToUpper(os.Getenv("SYNTROPY_ROUTE_STRATEGY")) {
+func parseRouteStrategy(str string) (int, bool) {
+	switch strings.ToUpper(str) {
 	case "SPEED":
-		cache.routeStrategy = RouteStrategySpeed
+		return RouteStrategySpeed, true
 	case "DR":
-		cache.routeStrategy = RouteStrategyDirectRoute
+		return RouteStrategyDirectRoute, true
+	case "COST":
+		return RouteStrategyCost, true
 	default:
-		cache.routeStrategy = RouteStrategySpeed
+		return 0, false
 	}
 }
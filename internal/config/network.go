@@ -3,16 +3,80 @@ package config
 import (
 	"encoding/json"
 	"net"
-	"os"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/SyntropyNet/syntropy-agent/internal/env"
+	"github.com/SyntropyNet/syntropy-agent/internal/logger"
 )
 
-func initPortsRange() {
-	cache.portsRange.start = 0
-	cache.portsRange.end = 0
+const defaultCloudURL = "controller-prod-platform-agents.syntropystack.com"
+
+// Controller endpoints is a comma separated list of host[:port][=weight] entries.
+// Endpoints are preferred by weight (default 1) and then by order.
+// If variable is set, but none of entries is valid, endpoints list is empty and agent does not start.
+func (c *configCache) initControllerEndpoints() {
+	c.endpoints = []ControllerEndpoint{}
+	c.cloudURL = ""
+
+	value := getenv("SYNTROPY_CONTROLLER_URL")
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		ep := ControllerEndpoint{
+			Host:   entry,
+			Weight: 1,
+		}
+		if idx := strings.LastIndex(entry, "="); idx >= 0 {
+			ep.Host = strings.TrimSpace(entry[:idx])
+			weight, err := strconv.Atoi(strings.TrimSpace(entry[idx+1:]))
+			if err != nil || weight <= 0 || ep.Host == "" {
+				logger.Error().Println(pkgName, "Invalid SYNTROPY_CONTROLLER_URL entry ignored:", entry)
+				continue
+			}
+			ep.Weight = uint(weight)
+		}
+		c.endpoints = append(c.endpoints, ep)
+	}
+
+	if len(c.endpoints) == 0 {
+		if strings.TrimSpace(value) != "" {
+			// Do not silently fallback to production controller
+			logger.Error().Println(pkgName, "SYNTROPY_CONTROLLER_URL has no valid entries")
+			return
+		}
+		c.endpoints = append(c.endpoints, ControllerEndpoint{Host: defaultCloudURL, Weight: 1})
+	}
+
+	// stable sort keeps configured order for the same weight
+	sort.SliceStable(c.endpoints, func(i, j int) bool {
+		return c.endpoints[i].Weight > c.endpoints[j].Weight
+	})
+	c.cloudURL = c.endpoints[0].Host
+}
+
+func (c *configCache) initControllerTLS() {
+	initString(&c.controllerTLS.caFile, "SYNTROPY_CONTROLLER_CA", "")
+	initString(&c.controllerTLS.certFile, "SYNTROPY_CLIENT_CERT", env.AgentConfigDir+"/client.crt")
+	initString(&c.controllerTLS.keyFile, "SYNTROPY_CLIENT_KEY", env.AgentConfigDir+"/client.key")
+
+	c.controllerTLS.pins = []string{}
+	for _, pin := range strings.Split(getenv("SYNTROPY_CONTROLLER_PINS"), ",") {
+		pin = strings.TrimSpace(pin)
+		if pin != "" {
+			c.controllerTLS.pins = append(c.controllerTLS.pins, pin)
+		}
+	}
+}
+
+func (c *configCache) initPortsRange() {
+	c.portsRange.start = 0
+	c.portsRange.end = 0
 
-	strport := strings.Split(os.Getenv("SYNTROPY_PORT_RANGE"), "-")
+	strport := strings.Split(getenv("SYNTROPY_PORT_RANGE"), "-")
 	if len(strport) != 2 {
 		return
 	}
@@ -26,17 +90,17 @@ func initPortsRange() {
 
 	// expect users to set range correctly, but still validate
 	if p2 > p1 {
-		cache.portsRange.start = uint16(p1)
-		cache.portsRange.end = uint16(p2)
+		c.portsRange.start = uint16(p1)
+		c.portsRange.end = uint16(p2)
 	} else {
-		cache.portsRange.start = uint16(p2)
-		cache.portsRange.end = uint16(p1)
+		c.portsRange.start = uint16(p2)
+		c.portsRange.end = uint16(p1)
 	}
 }
 
-func initAllowedIPs() {
-	cache.allowedIPs = []AllowedIPEntry{}
-	str := os.Getenv("SYNTROPY_ALLOWED_IPS")
+func (c *configCache) initAllowedIPs() {
+	c.allowedIPs = []AllowedIPEntry{}
+	str := getenv("SYNTROPY_ALLOWED_IPS")
 
 	var objMap []map[string]string
 	err := json.Unmarshal([]byte(str), &objMap)
@@ -52,7 +116,7 @@ func initAllowedIPs() {
 				continue
 			}
 
-			cache.allowedIPs = append(cache.allowedIPs, AllowedIPEntry{
+			c.allowedIPs = append(c.allowedIPs, AllowedIPEntry{
 				Name:   v,
 				Subnet: k,
 			})
@@ -60,10 +124,10 @@ func initAllowedIPs() {
 	}
 }
 
-func initIptables() {
-	cache.createIptablesRules = true
+func (c *configCache) initIptables() {
+	c.createIptablesRules = true
 
-	if strings.ToLower(os.Getenv("SYNTROPY_CREATE_IPTABLES_RULES")) == "disabled" {
-		cache.createIptablesRules = false
+	if strings.ToLower(getenv("SYNTROPY_CREATE_IPTABLES_RULES")) == "disabled" {
+		c.createIptablesRules = false
 	}
 }
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestControllerEndpoints(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []ControllerEndpoint
+	}{
+		{"", []ControllerEndpoint{{defaultCloudURL, 1}}},
+		{"a.example.com, b.example.com:443=5, =3, c.example.com=x",
+			[]ControllerEndpoint{{"b.example.com:443", 5}, {"a.example.com", 1}}},
+		// Invalid entries must not fallback to production controller
+		{"a.example.com=0, =2", []ControllerEndpoint{}},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("SYNTROPY_CONTROLLER_URL", tt.value)
+		cache.initControllerEndpoints()
+		if !reflect.DeepEqual(GetControllerEndpoints(), tt.expected) {
+			t.Errorf("%q: endpoints %v, expected %v", tt.value, GetControllerEndpoints(), tt.expected)
+		}
+	}
+}
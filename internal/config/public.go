@@ -6,6 +6,8 @@ const (
 	ControllerSaas = iota
 	ControllerScript
 	ControllerBlockchain
+	ControllerReplay
+	ControllerLocal
 	ControllerUnknown
 )
 
@@ -20,6 +22,26 @@ const (
 	RouteStrategySpeed = iota
 	// SYNTROPY_ROUTE_STRATEGY=dr
 	RouteStrategyDirectRoute
+	// SYNTROPY_ROUTE_STRATEGY=cost
+	RouteStrategyCost
+)
+
+const (
+	// SYNTROPY_PACKET_FILTER=auto
+	PacketFilterAuto = iota
+	// SYNTROPY_PACKET_FILTER=iptables
+	PacketFilterIptables
+	// SYNTROPY_PACKET_FILTER=nftables
+	PacketFilterNftables
+)
+
+const (
+	// SYNTROPY_WIREGUARD=auto
+	WireguardAuto = iota
+	// SYNTROPY_WIREGUARD=kernel
+	WireguardKernel
+	// SYNTROPY_WIREGUARD=userspace
+	WireguardUserspace
 )
 
 func GetControllerType() int {
@@ -34,12 +56,18 @@ func GetControllerName(ctype int) string {
 		return "Script"
 	case ControllerBlockchain:
 		return "Blockchain"
+	case ControllerReplay:
+		return "Replay"
+	case ControllerLocal:
+		return "Local HTTP"
 	default:
 		return "Unknown"
 	}
 }
 
 func GetDebugLevel() int {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.debugLevel
 }
 
@@ -47,10 +75,16 @@ func GetAgentToken() string {
 	return cache.apiKey
 }
 
+// GetCloudURL returns the most preferred cloud controller address
 func GetCloudURL() string {
 	return cache.cloudURL
 }
 
+// GetControllerEndpoints returns all cloud controller addresses, most preferred first
+func GetControllerEndpoints() []ControllerEndpoint {
+	return cache.endpoints
+}
+
 func GetOwnerAddress() string {
 	return cache.ownerAddress
 }
@@ -68,10 +102,14 @@ func GetAgentProvider() uint {
 }
 
 func GetServicesStatus() bool {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.servicesStatus
 }
 
 func GetAgentTags() []string {
+	cache.RLock()
+	defer cache.RUnlock()
 	if len(cache.agentTags) > 0 {
 		return cache.agentTags
 	} else {
@@ -87,6 +125,14 @@ func GetInterfaceMTU() uint {
 	return cache.mtu
 }
 
+func GetPacketFilter() int {
+	return cache.packetFilter
+}
+
+func GetWireguardMode() int {
+	return cache.wireguardMode
+}
+
 func CreateIptablesRules() bool {
 	return cache.createIptablesRules
 }
@@ -104,59 +150,188 @@ func GetNamespace() []string {
 }
 
 func GetLocationLatitude() float32 {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.location.Latitude
 }
 
 func GetLocationLongitude() float32 {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.location.Longitude
 }
 
 func ForceCleanupOnExit() {
+	cache.Lock()
+	defer cache.Unlock()
 	cache.cleanupOnExit = true
 }
 
 func CleanupOnExit() bool {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.cleanupOnExit
 }
 
+func StateSnapshotEnabled() bool {
+	return cache.stateSnapshot
+}
+
+// DryRun is true when configuration messages are only planned and never applied
+func DryRun() bool {
+	return cache.dryRun
+}
+
+// ScriptWatch is true when script controller watches for new configuration files
+func ScriptWatch() bool {
+	return cache.scriptWatch
+}
+
+// RecordJournal is true when controller messages are recorded to journal file
+func RecordJournal() bool {
+	return cache.recordJournal
+}
+
+// JournalFile is controller messages journal. It is written when recording
+// and is read by replay controller
+func JournalFile() string {
+	return cache.journalFile
+}
+
+// ReplaySpeed is replay controller timing acceleration. 0 means no delays.
+func ReplaySpeed() float32 {
+	return cache.replaySpeed
+}
+
+// QueuePersist is true when undelivered cloud controller replies are persisted to disk
+func QueuePersist() bool {
+	return cache.queuePersist
+}
+
+// GetProxy returns explicitly configured proxy URL. Empty means use proxy environment variables.
+func GetProxy() string {
+	return cache.proxy
+}
+
+// GetControllerCA returns private CA bundle file, used to verify controller certificate.
+// Empty means system CA store is used.
+func GetControllerCA() string {
+	return cache.controllerTLS.caFile
+}
+
+// GetControllerPins returns pinned controller certificate public key hashes
+func GetControllerPins() []string {
+	return cache.controllerTLS.pins
+}
+
+// GetClientCertificate returns agent client certificate and key files (used for mTLS, if present)
+func GetClientCertificate() (string, string) {
+	return cache.controllerTLS.certFile, cache.controllerTLS.keyFile
+}
+
+// GetLocalControllerAddress returns address, local HTTP controller listens on
+func GetLocalControllerAddress() string {
+	return cache.localController.address
+}
+
+// GetLocalControllerToken returns bearer token, local HTTP controller clients must authenticate with
+func GetLocalControllerToken() string {
+	return cache.localController.token
+}
+
+// GetLocalControllerTLS returns local HTTP controller certificate and key files.
+// Empty values mean plain HTTP.
+func GetLocalControllerTLS() (string, string) {
+	return cache.localController.certFile, cache.localController.keyFile
+}
+
+// GetNoProxy returns hosts, that are accessed without proxy. Empty means use NO_PROXY variable.
+func GetNoProxy() string {
+	return cache.noProxy
+}
+
+// ApplyRollback is true when failed configuration apply must be rolled back
+func ApplyRollback() bool {
+	return cache.applyRollback
+}
+
 func GetHostAllowedIPs() []AllowedIPEntry {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.allowedIPs
 }
 
-func IsVPNClient() bool {
-	return cache.vpnClient
+func HostServicesDiscovery() bool {
+	cache.RLock()
+	defer cache.RUnlock()
+	return cache.hostServicesDiscovery
 }
 
-func SetRerouteThresholds(diff, ratio float32) {
-	cache.rerouteThresholds.diff = diff
-	cache.rerouteThresholds.ratio = ratio
+func IsVPNClient() bool {
+	return cache.vpnClient
 }
 
 func RerouteThresholds() (float32, float32) {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.rerouteThresholds.diff, cache.rerouteThresholds.ratio
 }
 
 func MetricsExporterEnabled() bool {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.exporterPort > 0
 }
 
 func MetricsExporterPort() uint16 {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.exporterPort
 }
 
+func BandwidthProbeEnabled() bool {
+	return cache.bwprobePort > 0
+}
+
+// BandwidthProbePort is used both: by probe server and for probing remote peers
+func BandwidthProbePort() uint16 {
+	return cache.bwprobePort
+}
+
 func PeerCheckTime() time.Duration {
+	cache.RLock()
+	defer cache.RUnlock()
 	return time.Second * time.Duration(cache.times.peerMonitor)
 }
 
 func PeerCheckWindow() uint {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.times.rerouteWindow
 }
 
 func GetRouteDeleteThreshold() uint {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.routeDelThreshold
 }
 
+// RouteMultipath is true when services are routed via all healthy paths (ECMP)
+func RouteMultipath() bool {
+	return cache.routeMultipath
+}
+
+// GetMultipathBand returns how many percent worse than the best path
+// a path may be and still be used in multipath route
+func GetMultipathBand() uint {
+	cache.RLock()
+	defer cache.RUnlock()
+	return cache.multipathBand
+}
+
 func GetRouteStrategy() int {
+	cache.RLock()
+	defer cache.RUnlock()
 	return cache.routeStrategy
 }
 
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Runtime settings setters. Are used by controller (SET_SETTINGS).
+// Values are validated and configuration is not changed on error.
+// Caller is responsible for pushing new values to components, that cache them.
+
+const (
+	maxPeerCheckTime   = 60
+	maxPeerCheckWindow = 1000
+)
+
+// RouteStrategyName returns route strategy name, as used in SYNTROPY_ROUTE_STRATEGY
+func RouteStrategyName(strategy int) string {
+	switch strategy {
+	case RouteStrategyDirectRoute:
+		return "dr"
+	case RouteStrategyCost:
+		return "cost"
+	default:
+		return "speed"
+	}
+}
+
+func SetRouteStrategy(name string) error {
+	strategy, ok := parseRouteStrategy(name)
+	if !ok {
+		return fmt.Errorf("unknown route strategy %s", name)
+	}
+	cache.Lock()
+	cache.routeStrategy = strategy
+	cache.Unlock()
+	return nil
+}
+
+// SetPeerCheckTime sets peers ping period in seconds
+func SetPeerCheckTime(seconds uint) error {
+	if seconds < 1 || seconds > maxPeerCheckTime {
+		return fmt.Errorf("peer check time %d is out of range 1-%d", seconds, maxPeerCheckTime)
+	}
+	cache.Lock()
+	cache.times.peerMonitor = seconds
+	cache.Unlock()
+	return nil
+}
+
+// SetRerouteThresholds sets minimal latency difference (ms) and ratio, required to change route.
+// Ratio below 1 would switch to a worse route.
+func SetRerouteThresholds(diff, ratio float32) error {
+	if !(diff >= 0) {
+		return fmt.Errorf("reroute diff threshold %v must not be negative", diff)
+	}
+	if !(ratio >= 1) {
+		return fmt.Errorf("reroute ratio threshold %v must not be less than 1", ratio)
+	}
+	cache.Lock()
+	cache.rerouteThresholds.diff = diff
+	cache.rerouteThresholds.ratio = ratio
+	cache.Unlock()
+	return nil
+}
+
+// SetPeerCheckWindow sets moving average window size
+func SetPeerCheckWindow(count uint) error {
+	if count < 1 || count > maxPeerCheckWindow {
+		return fmt.Errorf("peer check window %d is out of range 1-%d", count, maxPeerCheckWindow)
+	}
+	cache.Lock()
+	cache.times.rerouteWindow = count
+	cache.Unlock()
+	return nil
+}
+
+// SetRouteDeleteThreshold sets packet loss (in percents) threshold. 0 - never delete routes.
+func SetRouteDeleteThreshold(percent uint) error {
+	if percent > 100 {
+		return fmt.Errorf("route delete threshold %d is out of range 0-100", percent)
+	}
+	cache.Lock()
+	cache.routeDelThreshold = percent
+	cache.Unlock()
+	return nil
+}
+
+func SetDebugLevel(name string) error {
+	level, ok := parseDebugLevel(name)
+	if !ok {
+		return fmt.Errorf("unknown log level %s", name)
+	}
+	cache.Lock()
+	cache.debugLevel = level
+	cache.Unlock()
+	return nil
+}
+
+// SetMetricsExporterPort sets exporter port. 0 - disables exporter.
+func SetMetricsExporterPort(port uint) error {
+	if port > maxPort {
+		return fmt.Errorf("exporter port %d is invalid", port)
+	}
+	cache.Lock()
+	cache.exporterPort = uint16(port)
+	cache.Unlock()
+	return nil
+}
+
+func SetServicesStatus(enabled bool) {
+	cache.Lock()
+	cache.servicesStatus = enabled
+	cache.Unlock()
+}
+
+func SetAgentTags(tags []string) error {
+	rv := []string{}
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || strings.Contains(t, ",") {
+			return fmt.Errorf("invalid tag '%s'", t)
+		}
+		rv = append(rv, t)
+	}
+	cache.Lock()
+	cache.agentTags = rv
+	cache.Unlock()
+	return nil
+}
+
+func SetLocation(latitude, longitude float32) error {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("invalid location %f, %f", latitude, longitude)
+	}
+	cache.Lock()
+	cache.location = Location{Latitude: latitude, Longitude: longitude}
+	cache.Unlock()
+	return nil
+}
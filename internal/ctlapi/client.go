@@ -0,0 +1,70 @@
+package ctlapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Client talks to agent's local control socket
+type Client struct {
+	http *http.Client
+}
+
+func NewClient(socket string) *Client {
+	return &Client{
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// SetTimeout changes request timeout (e.g. for long running requests)
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.http.Timeout = timeout
+}
+
+// Get requests endpoint and decodes response to v
+func (c *Client) Get(endpoint string, v interface{}) error {
+	// Host part is ignored, because unix socket is always dialed
+	resp, err := c.http.Get("http://agent" + endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return decodeResponse(resp, v)
+}
+
+// Post sends body to endpoint and decodes response to v
+func (c *Client) Post(endpoint string, body io.Reader, v interface{}) error {
+	resp, err := c.http.Post("http://agent"+endpoint, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return decodeResponse(resp, v)
+}
+
+func decodeResponse(resp *http.Response, v interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		var e ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("%s", e.Error)
+		}
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
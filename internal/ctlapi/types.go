@@ -0,0 +1,121 @@
+// ctlapi describes local control socket API
+// It is shared by agent (server side) and `syntropyctl` (client side)
+package ctlapi
+
+const (
+	PathInterfaces = "/interfaces"
+	PathPeers      = "/peers"
+	PathRoutes     = "/routes"
+	PathServices   = "/services"
+	PathPlan       = "/plan"
+	PathBandwidth  = "/bandwidth"
+)
+
+// Plan change actions
+const (
+	PlanAdd    = "add"
+	PlanRemove = "remove"
+	PlanChange = "change"
+)
+
+type Interface struct {
+	IfName     string `json:"ifname"`
+	PublicKey  string `json:"public_key"`
+	IP         string `json:"internal_ip"`
+	Port       int    `json:"listen_port"`
+	PeersCount int    `json:"peers_count"`
+}
+
+type Peer struct {
+	IfName        string   `json:"ifname"`
+	PublicKey     string   `json:"public_key"`
+	ConnectionID  int      `json:"connection_id"`
+	GroupID       int      `json:"connection_group_id"`
+	AgentID       int      `json:"agent_id"`
+	Endpoint      string   `json:"endpoint,omitempty"`
+	Gateway       string   `json:"gateway,omitempty"`
+	AllowedIPs    []string `json:"allowed_ips"`
+	LastHandshake string   `json:"last_handshake,omitempty"`
+	RxBytes       int64    `json:"rx_bytes"`
+	TxBytes       int64    `json:"tx_bytes"`
+}
+
+// RoutePeer is a peer monitored by PeerMonitor in a route group
+type RoutePeer struct {
+	Address      string  `json:"address"`
+	IfName       string  `json:"ifname"`
+	PublicKey    string  `json:"public_key"`
+	ConnectionID int     `json:"connection_id"`
+	Flags        string  `json:"flags,omitempty"`
+	Latency      float32 `json:"latency_ms"`
+	Loss         float32 `json:"packet_loss"`
+	Jitter       float32 `json:"jitter_ms"`
+	LatencyP95   float32 `json:"latency_p95_ms"`
+	Bandwidth    float32 `json:"bandwidth_mbps,omitempty"`
+}
+
+// RouteService is a service route, managed by ServiceMonitor in a route group
+type RouteService struct {
+	Address  string   `json:"address"`
+	Disabled bool     `json:"disabled,omitempty"`
+	Active   string   `json:"active,omitempty"`
+	Routes   []string `json:"routes"`
+}
+
+// SelectedPath is the currently selected best path of a route group
+type SelectedPath struct {
+	Gateway      string `json:"gateway,omitempty"`
+	ConnectionID int    `json:"connection_id"`
+	Reason       string `json:"reason,omitempty"`
+	Details      string `json:"details,omitempty"`
+}
+
+type RouteGroup struct {
+	GroupID  int            `json:"connection_group_id"`
+	Selected *SelectedPath  `json:"selected,omitempty"`
+	Peers    []RoutePeer    `json:"peers"`
+	Services []RouteService `json:"services"`
+}
+
+// Service is an agent background service
+type Service struct {
+	Name string `json:"name"`
+}
+
+// PlanEntry is a single change, that would be done when applying configuration message
+type PlanEntry struct {
+	Action  string `json:"action"`
+	Object  string `json:"object"`
+	Name    string `json:"name"`
+	Details string `json:"details,omitempty"`
+}
+
+// Plan is a dry-run result of CONFIG_INFO or WG_CONF message
+type Plan struct {
+	Message string      `json:"message"`
+	Changes []PlanEntry `json:"changes"`
+}
+
+// BandwidthRequest selects paths for bandwidth probe.
+// Paths are wireguard peers, selected by tunnel address or by connection ID.
+type BandwidthRequest struct {
+	Addresses     []string `json:"addresses,omitempty"`
+	ConnectionIDs []int    `json:"connection_ids,omitempty"`
+	Duration      int      `json:"duration,omitempty"` // seconds
+}
+
+// BandwidthResult is a single path bandwidth probe result
+type BandwidthResult struct {
+	Address      string  `json:"address"`
+	IfName       string  `json:"ifname,omitempty"`
+	ConnectionID int     `json:"connection_id,omitempty"`
+	Bandwidth    float32 `json:"bandwidth_mbps"`
+	Bytes        int64   `json:"bytes"`
+	Duration     int64   `json:"duration_ms"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// ErrorResponse is returned by control socket on failed requests
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
@@ -23,4 +23,7 @@ const (
 
 	// Locking agent to prevent several instances running
 	LockFile = "/var/lock/syntropy"
+	// Local control socket. Used by `syntropyctl` to inspect a running agent
+	// Is kept next to the lock file (/var/lock/syntropy.sock)
+	ControlSocket = LockFile + ".sock"
 )
@@ -4,9 +4,19 @@ import (
 	"io"
 	"log"
 	"os"
+	"sync"
+	"sync/atomic"
 )
 
-var global *Logger
+// global *Logger. Is replaced on log level change, while other goroutines are logging.
+var global atomic.Value
+
+// global logger outputs are kept for log level change
+var globalOutputs struct {
+	sync.Mutex
+	controller io.Writer
+	writers    []io.Writer
+}
 
 func init() {
 	// Start with error+warning level to stderr
@@ -14,29 +24,46 @@ func init() {
 }
 
 func SetupGlobalLoger(controller io.Writer, level int, writers ...io.Writer) {
-	global = New(controller, level, writers...)
+	globalOutputs.Lock()
+	defer globalOutputs.Unlock()
+
+	globalOutputs.controller = controller
+	globalOutputs.writers = writers
+	global.Store(New(controller, level, writers...))
+}
+
+// SetLevel changes global logger level. Outputs are kept the same.
+func SetLevel(level int) {
+	globalOutputs.Lock()
+	defer globalOutputs.Unlock()
+
+	global.Store(New(globalOutputs.controller, level, globalOutputs.writers...))
+}
+
+func globalLogger() *Logger {
+	return global.Load().(*Logger)
 }
 
 func Debug() *log.Logger {
-	return global.loggers[DebugLevel]
+	return globalLogger().loggers[DebugLevel]
 }
 
 func Message() *log.Logger {
-	return global.loggers[MessageLevel]
+	return globalLogger().loggers[MessageLevel]
 }
 
 func Info() *log.Logger {
-	return global.loggers[InfoLevel]
+	return globalLogger().loggers[InfoLevel]
 }
 
 func Warning() *log.Logger {
-	return global.loggers[WarningLevel]
+	return globalLogger().loggers[WarningLevel]
 }
 
 func Error() *log.Logger {
-	return global.loggers[ErrorLevel]
+	return globalLogger().loggers[ErrorLevel]
 }
 
 func Exec() *log.Logger {
-	return global.loggers[ExecutableLevel]
+	return globalLogger().loggers[ExecutableLevel]
 }
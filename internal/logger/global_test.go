@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestSetLevelConcurrent(t *testing.T) {
+	var out bytes.Buffer
+	SetupGlobalLoger(nil, InfoLevel, &out)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			SetLevel(ErrorLevel - i%2)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		Debug().Writer()
+		Info().Writer()
+	}
+	wg.Wait()
+
+	out.Reset()
+	SetLevel(ErrorLevel)
+	Warning().Println("hidden")
+	Error().Println("shown")
+	if bytes.Contains(out.Bytes(), []byte("hidden")) || !bytes.Contains(out.Bytes(), []byte("shown")) {
+		t.Errorf("Invalid log level after change: %s", out.String())
+	}
+}
@@ -66,6 +66,11 @@ func logLevelString(level int) string {
 	}
 }
 
+// LevelName returns log level name (e.g. DEBUG, INFO)
+func LevelName(level int) string {
+	return logLevelString(level)
+}
+
 func logLevelPrefix(level int) string {
 	switch level {
 	case DebugLevel:
@@ -115,11 +120,11 @@ func New(controller io.Writer, level int, w ...io.Writer) *Logger {
 	for i := 0; i < logLevelsCount; i++ {
 		if i >= level {
 			if controller != nil {
-				lgr.loggers[i] = log.New(makeWriters(append(w,
-					&controllerLogger{wr: controller, level: logLevelString(i)})...),
+				lgr.loggers[i] = log.New(&redactWriter{wr: makeWriters(append(w,
+					&controllerLogger{wr: controller, level: logLevelString(i)})...)},
 					logLevelPrefix(i), log.Ldate|log.Ltime)
 			} else {
-				lgr.loggers[i] = log.New(makeWriters(w...), logLevelPrefix(i), log.Ldate|log.Ltime)
+				lgr.loggers[i] = log.New(&redactWriter{wr: makeWriters(w...)}, logLevelPrefix(i), log.Ldate|log.Ltime)
 			}
 		} else {
 			lgr.loggers[i] = log.New(nullWriter, "", log.Ldate|log.Ltime)
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// Secrets in logged messages (e.g. preshared keys in controller messages dumps)
+// are replaced, before they reach any log output (including remote controller logging).
+var (
+	redactFields  = [][]byte{[]byte(`"preshared_key"`)}
+	redactPattern = regexp.MustCompile(`("preshared_key"\s*:\s*")[^"]*(")`)
+	redactValue   = []byte("${1}<redacted>${2}")
+)
+
+// HasSecrets is true, if message contains secrets (e.g. preshared keys)
+func HasSecrets(p []byte) bool {
+	for _, field := range redactFields {
+		if bytes.Contains(p, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns message with secrets replaced. Message without secrets is returned as is.
+func Redact(p []byte) []byte {
+	if !HasSecrets(p) {
+		return p
+	}
+	return redactPattern.ReplaceAll(p, redactValue)
+}
+
+type redactWriter struct {
+	wr io.Writer
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	if HasSecrets(p) {
+		_, err := rw.wr.Write(Redact(p))
+		return len(p), err
+	}
+	return rw.wr.Write(p)
+}
@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	var out bytes.Buffer
+	lgr := New(nil, DebugLevel, &out)
+
+	lgr.Message().Println("Received:",
+		`{"args":{"public_key":"pub=","preshared_key":"c2VjcmV0"}},{"preshared_key": "b3RoZXI="}`)
+	if strings.Contains(out.String(), "c2VjcmV0") || strings.Contains(out.String(), "b3RoZXI=") {
+		t.Errorf("Preshared key was logged: %s", out.String())
+	}
+	if !strings.Contains(out.String(), `"public_key":"pub="`) ||
+		strings.Count(out.String(), "<redacted>") != 2 {
+		t.Errorf("Invalid redacted output: %s", out.String())
+	}
+
+	out.Reset()
+	lgr.Info().Println("nothing to hide")
+	if !strings.HasSuffix(out.String(), "nothing to hide\n") {
+		t.Errorf("Invalid output: %s", out.String())
+	}
+}
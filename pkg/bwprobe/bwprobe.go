@@ -0,0 +1,194 @@
+// bwprobe package measures achievable TCP throughput between two hosts.
+//
+// Protocol is deliberately trivial:
+//   - client connects and streams payload for the probe duration, then half-closes the connection
+//   - server counts received bytes and replies with Result (JSON)
+//
+// Bandwidth is measured on server (receiving) side, so sender buffering does not inflate it.
+// Server runs one probe at a time. Concurrent probes would share the link and spoil results.
+package bwprobe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxDuration limits a single probe duration
+	MaxDuration = 30 * time.Second
+	// DefaultDuration is used when probe duration is not set
+	DefaultDuration = 5 * time.Second
+
+	bufferSize = 64 * 1024
+	// extra time for connection setup and result reporting
+	slack = 5 * time.Second
+)
+
+var ErrBusy = errors.New("probe server is busy")
+
+// Result of a single probe
+type Result struct {
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Bandwidth returns measured throughput in megabits per second
+func (r *Result) Bandwidth() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) * 8 / r.Duration.Seconds() / 1e6
+}
+
+// Server accepts probes on a single listener
+type Server struct {
+	sync.Mutex
+	listener net.Listener
+	busy     bool
+}
+
+// Listen creates probe server, bound to addr
+func Listen(addr netip.AddrPort) (*Server, error) {
+	l, err := net.Listen("tcp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	return &Server{listener: l}, nil
+}
+
+// Addr returns server listen address
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve accepts probes until server is closed
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		s.Lock()
+		busy := s.busy
+		s.busy = true
+		s.Unlock()
+		if busy {
+			conn.Close()
+			continue
+		}
+
+		go func() {
+			s.serveConn(conn)
+			s.Lock()
+			s.busy = false
+			s.Unlock()
+		}()
+	}
+}
+
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) serveConn(conn net.Conn) error {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(MaxDuration + slack))
+
+	buf := make([]byte, bufferSize)
+	res := Result{}
+	var started time.Time
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			if started.IsZero() {
+				started = time.Now()
+			}
+			res.Bytes += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !started.IsZero() {
+		res.Duration = time.Since(started)
+	}
+
+	return json.NewEncoder(conn).Encode(&res)
+}
+
+func probeDuration(duration time.Duration) time.Duration {
+	if duration <= 0 {
+		return DefaultDuration
+	}
+	if duration > MaxDuration {
+		return MaxDuration
+	}
+	return duration
+}
+
+// Timeout returns the longest time a single probe may take (including connection setup and result)
+func Timeout(duration time.Duration) time.Duration {
+	return probeDuration(duration) + 2*slack
+}
+
+// Probe streams data to remote probe server for duration and returns server measured result.
+// If local address is valid, connection is bound to it (e.g. wireguard tunnel address).
+func Probe(ctx context.Context, local netip.Addr, remote netip.AddrPort, duration time.Duration) (*Result, error) {
+	duration = probeDuration(duration)
+
+	dialer := net.Dialer{Timeout: slack}
+	if local.IsValid() {
+		dialer.LocalAddr = &net.TCPAddr{IP: local.AsSlice()}
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", remote.String())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(duration + slack))
+
+	// Stop streaming on timeout or cancel
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	buf := make([]byte, bufferSize)
+	for ctx.Err() == nil {
+		_, err = conn.Write(buf)
+		if err != nil {
+			// Busy server closes connection immediately
+			return nil, fmt.Errorf("probe %s: %s", remote, err)
+		}
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return nil, ctx.Err()
+	}
+
+	err = conn.(*net.TCPConn).CloseWrite()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	err = json.NewDecoder(conn).Decode(res)
+	if err == io.EOF {
+		return nil, ErrBusy
+	}
+	if err != nil {
+		return nil, fmt.Errorf("probe %s result: %s", remote, err)
+	}
+	return res, nil
+}
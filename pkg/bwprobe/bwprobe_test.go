@@ -0,0 +1,45 @@
+package bwprobe
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	s, err := Listen(netip.MustParseAddrPort("127.0.0.1:0"))
+	if err != nil {
+		t.Fatalf("Listen failed: %s", err)
+	}
+	defer s.Close()
+	go s.Serve()
+
+	remote := netip.MustParseAddrPort(s.Addr().String())
+	res, err := Probe(context.Background(), netip.MustParseAddr("127.0.0.1"), remote, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Probe failed: %s", err)
+	}
+	if res.Bytes == 0 || res.Duration <= 0 || res.Bandwidth() <= 0 {
+		t.Errorf("Invalid probe result %+v", res)
+	}
+
+	// Cancelled probe
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = Probe(ctx, netip.Addr{}, remote, time.Second); err == nil {
+		t.Errorf("Cancelled probe succeeded")
+	}
+}
+
+func TestResultBandwidth(t *testing.T) {
+	res := Result{Bytes: 125000000, Duration: 2 * time.Second}
+	if res.Bandwidth() != 500 {
+		t.Errorf("Bandwidth calculation failed %f", res.Bandwidth())
+	}
+
+	res.Duration = 0
+	if res.Bandwidth() != 0 {
+		t.Errorf("Zero duration bandwidth %f", res.Bandwidth())
+	}
+}
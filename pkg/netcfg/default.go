@@ -22,19 +22,34 @@ func ifnameFromIndex(idx int) (string, error) {
 	return l.Attrs().Name, nil
 }
 
-// IsDefaultRoute returns true if addr == 0.0.0.0/0
+// IsDefaultRoute returns true if addr == 0.0.0.0/0 (or ::/0)
 func IsDefaultRoute(addr *netip.Prefix) bool {
 	return addr.Addr().IsUnspecified() && addr.Bits() == 0
 }
 
+// DefaultRoute returns IPv4 default gateway and interface
 func DefaultRoute() (netip.Addr, string, error) {
+	return defaultRoute(unix.AF_INET)
+}
+
+// DefaultRoute6 returns IPv6 default gateway and interface
+func DefaultRoute6() (netip.Addr, string, error) {
+	return defaultRoute(unix.AF_INET6)
+}
+
+func defaultRoute(family int) (netip.Addr, string, error) {
 	var defaultRoute *netlink.Route
 	var ifname string
 	var err error
 
-	routes, err := netlink.RouteList(nil, unix.AF_INET)
+	unspecified := netip.IPv4Unspecified()
+	if family == unix.AF_INET6 {
+		unspecified = netip.IPv6Unspecified()
+	}
+
+	routes, err := netlink.RouteList(nil, family)
 	if err != nil {
-		return netip.IPv4Unspecified(), "", err
+		return unspecified, "", err
 	}
 
 	for idx, r := range routes {
@@ -54,16 +69,16 @@ func DefaultRoute() (netip.Addr, string, error) {
 	}
 
 	if defaultRoute == nil {
-		return netip.IPv4Unspecified(), "", ErrNotFound
+		return unspecified, "", ErrNotFound
 	}
 
 	ifname, err = ifnameFromIndex(defaultRoute.LinkIndex)
 	if err != nil {
-		return netip.IPv4Unspecified(), "", err
+		return unspecified, "", err
 	}
 	addr, ok := netip.AddrFromSlice(defaultRoute.Gw)
 	if !ok {
-		return netip.IPv4Unspecified(), "", fmt.Errorf("Failed parsing IP address %s", defaultRoute.Gw)
+		return unspecified, "", fmt.Errorf("Failed parsing IP address %s", defaultRoute.Gw)
 	}
 
 	return addr, ifname, nil
@@ -0,0 +1,79 @@
+package netcfg
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/vishvananda/netlink"
+)
+
+// NextHop is a single path of multipath (ECMP) route
+type NextHop struct {
+	Ifname string
+	Gw     *netip.Addr
+	Weight int // 1..256. Traffic is shared proportionally to weights
+}
+
+// RouteReplaceMultipath adds or replaces route to ip with a multipath route via all hops
+func RouteReplaceMultipath(ip *netip.Prefix, hops []NextHop) error {
+	if ip == nil {
+		return fmt.Errorf("no valid IP adress")
+	}
+	if len(hops) == 0 {
+		return fmt.Errorf("no next hops for %s", ip)
+	}
+
+	route := netlink.Route{
+		Dst: &net.IPNet{
+			IP:   ip.Addr().AsSlice(),
+			Mask: net.CIDRMask(ip.Bits(), ip.Addr().BitLen()),
+		},
+	}
+	for _, hop := range hops {
+		iface, err := netlink.LinkByName(hop.Ifname)
+		if err != nil {
+			return fmt.Errorf("failed to lookup interface %s", hop.Ifname)
+		}
+		if hop.Weight < 1 || hop.Weight > 256 {
+			return fmt.Errorf("invalid next hop %s weight %d", hop.Ifname, hop.Weight)
+		}
+		nh := &netlink.NexthopInfo{
+			LinkIndex: iface.Attrs().Index,
+			// kernel weight is hops+1
+			Hops: hop.Weight - 1,
+		}
+		if hop.Gw != nil {
+			nh.Gw = hop.Gw.AsSlice()
+		}
+		route.MultiPath = append(route.MultiPath, nh)
+	}
+
+	err := netlink.RouteReplace(&route)
+	if err != nil {
+		return fmt.Errorf("multipath route replace %s: %s", ip, err.Error())
+	}
+
+	return nil
+}
+
+// RouteDelMultipath deletes multipath route to ip.
+// Multipath routes do not belong to a single interface, thus RouteDel cannot find them.
+func RouteDelMultipath(ip *netip.Prefix) error {
+	if ip == nil {
+		return fmt.Errorf("no valid IP adress")
+	}
+
+	route := netlink.Route{
+		Dst: &net.IPNet{
+			IP:   ip.Addr().AsSlice(),
+			Mask: net.CIDRMask(ip.Bits(), ip.Addr().BitLen()),
+		},
+	}
+	err := netlink.RouteDel(&route)
+	if err != nil {
+		return fmt.Errorf("multipath route %s del: %s", ip, err.Error())
+	}
+
+	return nil
+}
@@ -132,7 +132,12 @@ func RouteSearch(ip *netip.Prefix) (found bool, ifname string) {
 		// So need only to compare destination and gateway
 		if r.Dst.IP.Equal(ip.Addr().AsSlice()) {
 			found = true
-			link, err := netlink.LinkByIndex(r.LinkIndex)
+			linkIndex := r.LinkIndex
+			if linkIndex == 0 && len(r.MultiPath) > 0 {
+				// multipath route. Report the first path interface
+				linkIndex = r.MultiPath[0].LinkIndex
+			}
+			link, err := netlink.LinkByIndex(linkIndex)
 			if err == nil {
 				ifname = link.Attrs().Name
 			}
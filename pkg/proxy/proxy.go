@@ -0,0 +1,129 @@
+// proxy package selects outbound proxy for connections to internet services.
+// Standard HTTPS_PROXY, HTTP_PROXY, ALL_PROXY and NO_PROXY environment variables are honoured,
+// unless proxy is explicitly configured with Setup.
+// Supported proxies: HTTP CONNECT (with basic auth) and SOCKS5.
+// Loopback addresses are never proxied.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var (
+	mutex     sync.Mutex
+	proxyFunc func(*url.URL) (*url.URL, error)
+)
+
+func getEnvAny(names ...string) string {
+	for _, n := range names {
+		if val := os.Getenv(n); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+// Parse validates proxy URL. Plain host:port is treated as HTTP proxy.
+func Parse(proxyURL string) (*url.URL, error) {
+	if !strings.Contains(proxyURL, "://") {
+		proxyURL = "http://" + proxyURL
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %s", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy host is missing")
+	}
+	return u, nil
+}
+
+func fromEnvironment() *httpproxy.Config {
+	cfg := httpproxy.FromEnvironment()
+	allProxy := getEnvAny("ALL_PROXY", "all_proxy")
+	if cfg.HTTPProxy == "" {
+		cfg.HTTPProxy = allProxy
+	}
+	if cfg.HTTPSProxy == "" {
+		cfg.HTTPSProxy = allProxy
+	}
+	return cfg
+}
+
+// Setup configures explicit proxy, used for all connections.
+// Empty proxyURL means use proxy environment variables.
+// noProxy is a comma separated list of hosts, domains and networks, that are accessed directly.
+func Setup(proxyURL, noProxy string) error {
+	cfg := fromEnvironment()
+
+	if proxyURL != "" {
+		u, err := Parse(proxyURL)
+		if err != nil {
+			return err
+		}
+		cfg.HTTPProxy = u.String()
+		cfg.HTTPSProxy = u.String()
+	}
+	if noProxy != "" {
+		cfg.NoProxy = noProxy
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	proxyFunc = cfg.ProxyFunc()
+
+	return nil
+}
+
+// ForURL returns proxy for target URL. nil means direct connection.
+func ForURL(target *url.URL) (*url.URL, error) {
+	mutex.Lock()
+	if proxyFunc == nil {
+		proxyFunc = fromEnvironment().ProxyFunc()
+	}
+	f := proxyFunc
+	mutex.Unlock()
+
+	return f(target)
+}
+
+// ForRequest is a Proxy function for http.Transport and websocket.Dialer
+func ForRequest(req *http.Request) (*url.URL, error) {
+	return ForURL(req.URL)
+}
+
+// Transport returns default http transport, that uses configured proxy
+func Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = ForRequest
+	return t
+}
+
+// HostPort returns proxy address with default port, if port is not set
+func HostPort(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch proxyURL.Scheme {
+		case "socks5":
+			port = "1080"
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
@@ -8,11 +8,14 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/SyntropyNet/syntropy-agent/pkg/proxy"
 )
 
 func PublicIP() (net.IP, error) {
 	client := http.Client{
-		Timeout: 5 * time.Second,
+		Timeout:   5 * time.Second,
+		Transport: proxy.Transport(),
 	}
 	resp, err := client.Get("https://ip.syntropystack.com:443")
 	if err != nil {